@@ -4,12 +4,14 @@ import (
 	"flag"
 	"kelisim-chat/internal/config"
 	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/prompts"
 	"kelisim-chat/internal/router"
 	"kelisim-chat/internal/services"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -50,6 +52,24 @@ func main() {
 		log.Fatal("Failed to create storage directory:", err)
 	}
 
+	// 加载提示词模板
+	if err := prompts.Init(config.AppConfig.LLM.PromptsPath); err != nil {
+		logrus.WithError(err).Warn("Some prompt templates failed to load")
+	}
+
+	// 定期清理过期的分片上传会话
+	services.StartUploadCleaner(time.Hour)
+
+	// 定期刷新有新消息的聊天室总结
+	if interval := config.AppConfig.LLM.SummaryRefreshInterval; interval > 0 {
+		services.StartSummaryRefresher(time.Duration(interval)*time.Minute, config.AppConfig.LLM.SummaryMinNewMessages)
+	}
+
+	// 定期为新消息和文件生成检索向量
+	if interval := config.AppConfig.Embedding.IndexInterval; interval > 0 {
+		services.StartEmbeddingIndexer(time.Duration(interval) * time.Minute)
+	}
+
 	// 初始化 FCM 服务 (V1 API)
 	if err := services.InitFCMService(); err != nil {
 		logrus.Warn("Failed to initialize FCM service:", err)
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
@@ -14,6 +15,9 @@ type Config struct {
 	JWT                   JWTConfig
 	Storage               StorageConfig
 	LLM                   LLMConfig
+	Scanner               ScannerConfig
+	Moderation            ModerationConfig
+	Embedding             EmbeddingConfig
 	FCMServerKey          string // Legacy API (deprecated)
 	FCMServiceAccountPath string // V1 API (recommended)
 }
@@ -37,18 +41,73 @@ type JWTConfig struct {
 }
 
 type StorageConfig struct {
-	Path        string
-	BaseURL     string
-	MaxFileSize int64
+	Path             string
+	BaseURL          string
+	MaxFileSize      int64
+	MaxUploadSize    int64  // 分片上传的最大文件大小
+	ChunkSize        int64  // 单个分片的最大大小
+	UploadSessionTTL int    // 分片上传会话有效期（小时）
+	TempPath         string // 分片上传临时目录
+	OrgQuota         int64  // 每个组织的存储配额（字节，0 表示不限制）
+	ChatQuota        int64  // 每个聊天室的存储配额（字节，0 表示不限制）
+}
+
+type ScannerConfig struct {
+	Driver         string // none 或 clamav
+	ClamAVAddress  string // tcp://host:port 或 unix:///path/to/clamd.ctl
+	Timeout        int    // 单个文件扫描超时（秒）
+	QuarantinePath string // 隔离目录（不在静态文件目录下）
+	PendingPath    string // 待扫描文件目录（不在静态文件目录下，扫描通过后移入存储目录）
+	RetryInterval  int    // 重新扫描失败或积压文件的间隔（分钟，0 表示不重试）
+}
+
+type EmbeddingConfig struct {
+	Provider      string // local（默认，本地确定性哈希向量，无需外部服务）、openai 或 ollama
+	APIKey        string // openai 使用
+	APIBase       string // 为空时 openai 使用 https://api.openai.com/v1，ollama 使用 OLLAMA_API_BASE
+	Model         string // 为空时 openai 使用 text-embedding-3-small，ollama 使用 nomic-embed-text
+	Dimensions    int    // local 的向量维度
+	Timeout       int    // 请求超时（秒）
+	IndexInterval int    // 后台索引新消息和文件的间隔（分钟），0 表示不索引
+}
+
+type ModerationConfig struct {
+	Enabled bool // 是否对参与者发送的文本消息进行风险分类
+	UseLLM  bool // 规则之外是否再用 LLM 分类（每条消息一次调用）
 }
 
 type LLMConfig struct {
-	APIKey      string
-	APIBase     string
-	Model       string
-	MaxTokens   int
-	Temperature float64
-	Timeout     int
+	Provider string            // openai（OpenAI 兼容接口，默认 DeepSeek）、ollama 或 fake
+	OpenAI   LLMProviderConfig // DEEPSEEK_*
+	Ollama   LLMProviderConfig // OLLAMA_*
+	Fake     LLMProviderConfig // 返回固定格式的回答，用于测试和本地开发
+
+	Fallbacks        []string // 主提供方失败后按顺序尝试，格式 provider 或 provider:model
+	MaxRetries       int      // 每个提供方的重试次数
+	RetryBaseDelay   int      // 首次重试的退避时间（毫秒），之后指数增长
+	RetryMaxDelay    int      // 单次退避时间上限（毫秒），Retry-After 超过该值时直接切换到备用提供方
+	BreakerThreshold int      // 连续失败多少次后熔断，0 表示不熔断
+	BreakerCooldown  int      // 熔断持续时间（秒）
+
+	SummaryRefreshInterval int // 后台刷新聊天室总结的间隔（分钟），0 表示不刷新
+	SummaryMinNewMessages  int // 新消息达到多少条才在后台刷新总结
+
+	PromptsPath string // 提示词模板目录，其中的 <name>.v<N>.tmpl 覆盖或新增内置模板
+
+	PIIRedact string // 发送给 LLM 前脱敏的个人信息类型：phone、iin、email、card、iban，逗号分隔；all 或 none
+
+	AIRateLimit  int // 参与者每分钟最多向 AI 助手提问的次数
+	AIDailyQuota int // 参与者每人每天最多向 AI 助手提问的次数，0 表示不限
+}
+
+type LLMProviderConfig struct {
+	APIKey        string
+	APIBase       string
+	Model         string
+	MaxTokens     int
+	ContextWindow int // 模型上下文窗口（token），减去 MaxTokens 后用于提示词
+	Temperature   float64
+	Timeout       int
 }
 
 var AppConfig *Config
@@ -76,17 +135,77 @@ func Load() error {
 			Algo:   getEnv("JWT_ALGO", "HS256"),
 		},
 		Storage: StorageConfig{
-			Path:        getEnv("STORAGE_PATH", "./storage/chat-files"),
-			BaseURL:     getEnv("STORAGE_BASE_URL", "http://localhost:8080/storage/chat-files"),
-			MaxFileSize: getEnvAsInt64("MAX_FILE_SIZE", 10485760), // 10MB
+			Path:             getEnv("STORAGE_PATH", "./storage/chat-files"),
+			BaseURL:          getEnv("STORAGE_BASE_URL", "http://localhost:8080/storage/chat-files"),
+			MaxFileSize:      getEnvAsInt64("MAX_FILE_SIZE", 10485760),    // 10MB
+			MaxUploadSize:    getEnvAsInt64("MAX_UPLOAD_SIZE", 524288000), // 500MB
+			ChunkSize:        getEnvAsInt64("UPLOAD_CHUNK_SIZE", 5242880), // 5MB
+			UploadSessionTTL: getEnvAsInt("UPLOAD_SESSION_TTL", 24),
+			TempPath:         getEnv("UPLOAD_TEMP_PATH", "./storage/uploads"),
+			OrgQuota:         getEnvAsInt64("STORAGE_ORG_QUOTA", 10737418240), // 10GB
+			ChatQuota:        getEnvAsInt64("STORAGE_CHAT_QUOTA", 2147483648), // 2GB
 		},
 		LLM: LLMConfig{
-			APIKey:      getEnv("DEEPSEEK_API_KEY", ""),
-			APIBase:     getEnv("DEEPSEEK_API_BASE", "https://api.deepseek.com/v1"),
-			Model:       getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
-			MaxTokens:   getEnvAsInt("DEEPSEEK_MAX_TOKENS", 2000),
-			Temperature: getEnvAsFloat64("DEEPSEEK_TEMPERATURE", 0.7),
-			Timeout:     getEnvAsInt("DEEPSEEK_TIMEOUT", 30),
+			Provider: getEnv("LLM_PROVIDER", "openai"),
+			OpenAI: LLMProviderConfig{
+				APIKey:        getEnv("DEEPSEEK_API_KEY", ""),
+				APIBase:       getEnv("DEEPSEEK_API_BASE", "https://api.deepseek.com/v1"),
+				Model:         getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
+				MaxTokens:     getEnvAsInt("DEEPSEEK_MAX_TOKENS", 2000),
+				ContextWindow: getEnvAsInt("DEEPSEEK_CONTEXT_WINDOW", 65536),
+				Temperature:   getEnvAsFloat64("DEEPSEEK_TEMPERATURE", 0.7),
+				Timeout:       getEnvAsInt("DEEPSEEK_TIMEOUT", 30),
+			},
+			Ollama: LLMProviderConfig{
+				APIBase:       getEnv("OLLAMA_API_BASE", "http://127.0.0.1:11434"),
+				Model:         getEnv("OLLAMA_MODEL", "qwen2.5:7b"),
+				MaxTokens:     getEnvAsInt("OLLAMA_MAX_TOKENS", 2000),
+				ContextWindow: getEnvAsInt("OLLAMA_CONTEXT_WINDOW", 8192),
+				Temperature:   getEnvAsFloat64("OLLAMA_TEMPERATURE", 0.7),
+				Timeout:       getEnvAsInt("OLLAMA_TIMEOUT", 120),
+			},
+			Fake: LLMProviderConfig{
+				Model:         "fake",
+				MaxTokens:     2000,
+				ContextWindow: 8192,
+			},
+			Fallbacks:        getEnvAsList("LLM_FALLBACKS"),
+			MaxRetries:       getEnvAsInt("LLM_MAX_RETRIES", 2),
+			RetryBaseDelay:   getEnvAsInt("LLM_RETRY_BASE_DELAY", 500),
+			RetryMaxDelay:    getEnvAsInt("LLM_RETRY_MAX_DELAY", 10000),
+			BreakerThreshold: getEnvAsInt("LLM_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:  getEnvAsInt("LLM_BREAKER_COOLDOWN", 30),
+
+			SummaryRefreshInterval: getEnvAsInt("SUMMARY_REFRESH_INTERVAL", 10),
+			SummaryMinNewMessages:  getEnvAsInt("SUMMARY_MIN_NEW_MESSAGES", 20),
+
+			PromptsPath: getEnv("PROMPTS_PATH", "./prompts"),
+
+			PIIRedact: getEnv("PII_REDACT", "all"),
+
+			AIRateLimit:  getEnvAsInt("AI_RATE_LIMIT", 20),
+			AIDailyQuota: getEnvAsInt("AI_DAILY_QUOTA", 20),
+		},
+		Scanner: ScannerConfig{
+			Driver:         getEnv("SCANNER_DRIVER", "none"),
+			ClamAVAddress:  getEnv("CLAMAV_ADDRESS", "tcp://127.0.0.1:3310"),
+			Timeout:        getEnvAsInt("CLAMAV_TIMEOUT", 60),
+			QuarantinePath: getEnv("QUARANTINE_PATH", "./storage/quarantine"),
+			PendingPath:    getEnv("PENDING_SCAN_PATH", "./storage/pending"),
+			RetryInterval:  getEnvAsInt("SCAN_RETRY_INTERVAL", 10),
+		},
+		Embedding: EmbeddingConfig{
+			Provider:      getEnv("EMBEDDING_PROVIDER", "local"),
+			APIKey:        getEnv("EMBEDDING_API_KEY", ""),
+			APIBase:       getEnv("EMBEDDING_API_BASE", ""),
+			Model:         getEnv("EMBEDDING_MODEL", ""),
+			Dimensions:    getEnvAsInt("EMBEDDING_DIMENSIONS", 512),
+			Timeout:       getEnvAsInt("EMBEDDING_TIMEOUT", 60),
+			IndexInterval: getEnvAsInt("EMBEDDING_INDEX_INTERVAL", 5),
+		},
+		Moderation: ModerationConfig{
+			Enabled: getEnvAsBool("MODERATION_ENABLED", true),
+			UseLLM:  getEnvAsBool("MODERATION_LLM", false),
 		},
 		FCMServerKey:          getEnv("FCM_SERVER_KEY", ""),
 		FCMServiceAccountPath: getEnv("FCM_SERVICE_ACCOUNT_PATH", ""),
@@ -125,6 +244,25 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func getEnvAsFloat64(key string, defaultValue float64) float64 {
 	if value := os.Getenv(key); value != "" {
 		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
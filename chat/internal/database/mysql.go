@@ -46,6 +46,18 @@ func autoMigrate() error {
 		&models.MessageStatus{},
 		&models.ChatFile{},
 		&models.User{},
+		&models.UploadSession{},
+		&models.FileBlob{},
+		&models.AIInteraction{},
+		&models.ChatCaseExtraction{},
+		&models.ChatSummary{},
+		&models.MessageTranslation{},
+		&models.MessageFlag{},
+		&models.MessageEmbedding{},
+		&models.OperatorChatRead{},
+		&models.AIQuotaUsage{},
+		&models.StorageQuotaLock{},
+		&models.StorageReservation{},
 	)
 }
 
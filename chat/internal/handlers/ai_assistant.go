@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"kelisim-chat/internal/middleware"
+	"kelisim-chat/internal/models"
 	"kelisim-chat/internal/services"
 	"kelisim-chat/internal/websocket"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,21 +19,33 @@ import (
 
 // AIAssistantHandler AI助手处理器
 type AIAssistantHandler struct {
-	llmService          *services.LLMService
-	messageService      *services.MessageService
-	chatService         *services.ChatService
-	notificationService *services.NotificationService
-	hub                 *websocket.Hub
+	llmService            *services.LLMService
+	aiInteractionService  *services.AIInteractionService
+	caseExtractionService *services.CaseExtractionService
+	chatSummaryService    *services.ChatSummaryService
+	embeddingService      *services.EmbeddingService
+	messageService        *services.MessageService
+	fileService           *services.FileService
+	chatService           *services.ChatService
+	notificationService   *services.NotificationService
+	hub                   *websocket.Hub
 }
 
 // NewAIAssistantHandler 创建AI助手处理器
 func NewAIAssistantHandler(hub *websocket.Hub) *AIAssistantHandler {
+	llmService := services.NewLLMService()
+
 	return &AIAssistantHandler{
-		llmService:          services.NewLLMService(),
-		messageService:      services.NewMessageService(),
-		chatService:         services.NewChatService(),
-		notificationService: services.NewNotificationService(),
-		hub:                 hub,
+		llmService:            llmService,
+		aiInteractionService:  services.NewAIInteractionService(),
+		caseExtractionService: services.NewCaseExtractionService(),
+		chatSummaryService:    services.NewChatSummaryService(llmService),
+		embeddingService:      services.NewEmbeddingService(services.NewEmbeddingProvider()),
+		messageService:        services.NewMessageService(),
+		fileService:           services.NewFileService(),
+		chatService:           services.NewChatService(),
+		notificationService:   services.NewNotificationService(),
+		hub:                   hub,
 	}
 }
 
@@ -36,16 +53,27 @@ func NewAIAssistantHandler(hub *websocket.Hub) *AIAssistantHandler {
 type AskRequest struct {
 	Question       string `json:"question" binding:"required"`
 	IncludeContext bool   `json:"include_context"` // 是否包含最近的聊天上下文
-	ContextCount   int    `json:"context_count"`   // 上下文消息数量，默认10
+	ContextCount   int    `json:"context_count"`   // 上下文消息数量，默认100，最多500
 }
 
 // SummarizeRequest 总结请求
 type SummarizeRequest struct {
-	MessageCount int    `json:"message_count"` // 要总结的消息数量，0表示全部
-	Language     string `json:"language"`      // 总结语言
+	MessageCount int    `json:"message_count"` // 要总结的最近消息数量，0表示全部（Operator 使用滚动总结）
+	Language     string `json:"language"`      // 总结语言，默认使用检测到的聊天语言
 }
 
-// AskAI AI助手提问
+// maxContextMessages 提问上下文最多读取的消息数，超出上下文窗口的部分会被总结
+const maxContextMessages = 500
+
+// 文件分析限制：每次最多分析的文件数和文本片段数
+const (
+	maxAnalyzedFiles  = 10
+	maxAnalyzedChunks = 12
+)
+
+// AskAI AI助手提问 - 参与者
+// 聊天室需要开启 ai_enabled；回答保存为 ai_assistant 消息并注明提问者，所有参与者可见
+// 路由上挂载了分钟级限流和每日配额中间件
 func (h *AIAssistantHandler) AskAI(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
@@ -66,63 +94,40 @@ func (h *AIAssistantHandler) AskAI(c *gin.Context) {
 		return
 	}
 
+	// 检查聊天室是否开启了 AI 助手
+	if !h.chatService.IsAIEnabled(uint(chatID)) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "AI assistant is not enabled for this chat",
+			"code":  "ai_disabled",
+		})
+		return
+	}
+
 	var req AskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 设置默认上下文数量
-	if req.ContextCount == 0 {
-		req.ContextCount = 100
+	// 系统提示词（participant_ask 模板，按用户语言）
+	systemPrompt, ok := h.renderPrompt(c, nil, "participant_ask", uint(chatID), viewerLanguage(c, "lang"))
+	if !ok {
+		return
 	}
 
 	// 构建对话上下文
-	var contextMessages []services.ChatMessage
-
-	if req.IncludeContext {
-		// 获取最近的聊天消息作为上下文
-		recentMessages, err := h.messageService.GetChatMessages(uint(chatID), req.ContextCount, 0)
-		if err == nil && len(recentMessages) > 0 {
-			// 反转消息顺序（从旧到新）
-			for i := len(recentMessages) - 1; i >= 0; i-- {
-				msg := recentMessages[i]
-				if msg.Type == "text" && msg.Content != nil && *msg.Content != "" {
-					role := "user"
-					// 如果消息是系统消息或AI助手消息，标记为assistant
-					if msg.Type == "system" || msg.SenderID == nil {
-						role = "assistant"
-					}
-					contextMessages = append(contextMessages, services.ChatMessage{
-						Role:    role,
-						Content: *msg.Content,
-					})
-				}
-			}
-		}
-	}
-
-	// 系统提示词
-	systemPrompt := "You are a helpful AI assistant for a legal consultation platform. You help users with their questions and provide relevant information. Be professional, concise, and helpful."
+	contextResult := h.buildAskContext(c.Request.Context(), uint(chatID), &req, systemPrompt)
 
 	// 调用LLM获取回答
-	answer, err := h.llmService.AskQuestion(req.Question, systemPrompt, contextMessages)
+	answer, err := h.llmService.AskQuestion(c.Request.Context(), req.Question, systemPrompt, contextResult.Messages)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to get AI response")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI response"})
+		respondAIError(c, err, "Failed to get AI response")
 		return
 	}
 
-	// 将AI回答保存为消息
-	aiMessage, err := h.messageService.SendMessage(
-		uint(chatID),
-		nil,            // AI助手没有sender_id
-		"ai_assistant", // AI助手消息类型
-		&answer,
-		nil,
-		nil,
-		nil,
-	)
+	// 将AI回答保存为消息（AI助手没有sender_id，问题记录在提问者名下）
+	aiMessage, err := h.messageService.SendAIAnswer(uint(chatID), userID, req.Question, answer)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to save AI message")
 		// 即使保存失败，也返回AI回答
@@ -158,6 +163,7 @@ func (h *AIAssistantHandler) AskAI(c *gin.Context) {
 		"message": "AI response generated successfully",
 		"data":    aiMessage,
 		"answer":  answer,
+		"context": contextResponse(contextResult),
 	})
 }
 
@@ -182,53 +188,34 @@ func (h *AIAssistantHandler) OperatorAskAI(c *gin.Context) {
 		return
 	}
 
-	// 设置默认上下文数量
-	if req.ContextCount == 0 {
-		req.ContextCount = 100
-	}
+	call := startAICall(operatorID, uint(chatID), "ask")
+	ctx := call.track(c.Request.Context())
 
-	// 构建对话上下文
-	var contextMessages []services.ChatMessage
-
-	if req.IncludeContext {
-		// 获取最近的聊天消息作为上下文
-		recentMessages, err := h.messageService.GetChatMessages(uint(chatID), req.ContextCount, 0)
-		if err == nil && len(recentMessages) > 0 {
-			// 反转消息顺序（从旧到新）
-			for i := len(recentMessages) - 1; i >= 0; i-- {
-				msg := recentMessages[i]
-				if msg.Type == "text" && msg.Content != nil && *msg.Content != "" {
-					role := "user"
-					// 如果消息是系统消息，标记为assistant
-					if msg.Type == "system" || msg.SenderID == nil {
-						role = "assistant"
-					}
-					contextMessages = append(contextMessages, services.ChatMessage{
-						Role:    role,
-						Content: *msg.Content,
-					})
-				}
-			}
-		}
+	systemPrompt, ok := h.renderPrompt(c, call, "operator_ask", uint(chatID), "")
+	if !ok {
+		return
 	}
 
-	// 系统提示词
-	systemPrompt := "You are a helpful AI assistant for operators managing a legal consultation platform. You help operators understand conversations and provide insights. Be professional, concise, and helpful."
+	contextResult := h.buildAskContext(ctx, uint(chatID), &req, systemPrompt)
+	call.setPrompt(req.Question, contextResult.Messages)
 
 	// 调用LLM获取回答
-	answer, err := h.llmService.AskQuestion(req.Question, systemPrompt, contextMessages)
+	answer, err := h.llmService.AskQuestion(ctx, req.Question, systemPrompt, contextResult.Messages)
+	interactionID := h.finishAICall(call, answer, err)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to get AI response for operator")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI response"})
+		respondAIError(c, err, "Failed to get AI response")
 		return
 	}
 
-	// 直接返回 AI 分析结果，不保存到数据库
+	// 直接返回 AI 分析结果，不保存为聊天消息（调用记录保存在 ai_interactions）
 	c.JSON(http.StatusOK, gin.H{
-		"answer":      answer,
-		"timestamp":   time.Now(),
-		"operator_id": operatorID,
-		"context_used": req.IncludeContext,
+		"answer":         answer,
+		"timestamp":      time.Now(),
+		"operator_id":    operatorID,
+		"context_used":   req.IncludeContext,
+		"context":        contextResponse(contextResult),
+		"interaction_id": interactionID,
 	})
 }
 
@@ -247,23 +234,16 @@ func (h *AIAssistantHandler) OperatorSummarize(c *gin.Context) {
 		return
 	}
 
-	var req SummarizeRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		// 如果没有提供请求体，使用默认值
-		req.MessageCount = 50
-		req.Language = "en"
-	}
+	req := h.bindSummarizeRequest(c, uint(chatID))
 
-	// 设置默认值
+	// 总结全部消息时使用保存的滚动总结，只需总结新消息
 	if req.MessageCount == 0 {
-		req.MessageCount = 50
-	}
-	if req.Language == "" {
-		req.Language = "en"
+		h.rollingSummarize(c, operatorID, uint(chatID), req.Language)
+		return
 	}
 
-	// 获取聊天消息
-	messages, err := h.messageService.GetChatMessages(uint(chatID), req.MessageCount, 0)
+	// 获取聊天消息并构建对话历史
+	messages, conversationMessages, err := h.operatorSummaryConversation(uint(chatID), req)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to get chat messages")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat messages"})
@@ -277,49 +257,155 @@ func (h *AIAssistantHandler) OperatorSummarize(c *gin.Context) {
 		return
 	}
 
-	// 构建对话历史
-	var conversationMessages []services.ChatMessage
-	for i := len(messages) - 1; i >= 0; i-- {
-		msg := messages[i]
-		if msg.Type == "text" && msg.Content != nil && *msg.Content != "" {
-			role := "user"
-			if msg.Type == "system" || msg.SenderID == nil {
-				role = "assistant"
-			}
-
-			// 构建消息内容（包含发送者信息）
-			content := *msg.Content
-			if msg.Sender != nil {
-				senderName := msg.Sender.GetFullName()
-				if senderName != "" {
-					content = senderName + ": " + content
-				}
-			}
+	call := startAICall(operatorID, uint(chatID), "summarize")
+	call.setPrompt(req.Language, conversationMessages)
 
-			conversationMessages = append(conversationMessages, services.ChatMessage{
-				Role:    role,
-				Content: content,
-			})
-		}
+	prompt, ok := h.renderPrompt(c, call, "summarize", uint(chatID), req.Language)
+	if !ok {
+		return
 	}
 
 	// 调用LLM生成总结
-	summary, err := h.llmService.SummarizeConversation(conversationMessages, req.Language)
+	summary, err := h.llmService.SummarizeConversation(call.track(c.Request.Context()), conversationMessages, prompt)
+	interactionID := h.finishAICall(call, summary, err)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to generate summary")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate summary"})
+		respondAIError(c, err, "Failed to generate summary")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"summary":       summary,
-		"message_count": len(messages),
-		"language":      req.Language,
-		"summarized_at": time.Now(),
-		"operator_id":   operatorID,
+		"summary":        summary,
+		"message_count":  len(messages),
+		"language":       req.Language,
+		"summarized_at":  time.Now(),
+		"operator_id":    operatorID,
+		"interaction_id": interactionID,
 	})
 }
 
+// rollingSummarize 返回覆盖全部消息的滚动总结，有新消息时先把新消息合并进总结
+func (h *AIAssistantHandler) rollingSummarize(c *gin.Context, operatorID uint, chatID uint, language string) {
+	call := startAICall(operatorID, chatID, "summarize")
+	call.setPrompt(language, nil)
+
+	prompt, ok := h.renderPrompt(c, call, "summarize", chatID, language)
+	if !ok {
+		return
+	}
+
+	summary, added, err := h.chatSummaryService.Refresh(call.track(c.Request.Context()), chatID, language, prompt)
+
+	// 没有新消息时没有调用模型，不记录
+	var interactionID uint
+	if added > 0 || err != nil {
+		var text string
+		if summary != nil {
+			text = summary.Summary
+		}
+		interactionID = h.finishAICall(call, text, err)
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to refresh chat summary")
+		respondAIError(c, err, "Failed to generate summary")
+		return
+	}
+
+	if summary.MessageCount == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"summary": "No messages to summarize",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary":         summary.Summary,
+		"message_count":   summary.MessageCount,
+		"new_messages":    added,
+		"last_message_id": summary.LastMessageID,
+		"language":        language,
+		"summarized_at":   summary.UpdatedAt,
+		"operator_id":     operatorID,
+		"interaction_id":  interactionID,
+	})
+}
+
+// buildAskContext 构建提问使用的聊天上下文，按模型上下文窗口裁剪，放不下的较早消息会被总结为一条
+func (h *AIAssistantHandler) buildAskContext(ctx context.Context, chatID uint, req *AskRequest, systemPrompt string) *services.ContextResult {
+	if !req.IncludeContext {
+		return &services.ContextResult{}
+	}
+
+	// 设置默认上下文数量
+	if req.ContextCount <= 0 {
+		req.ContextCount = 100
+	}
+	if req.ContextCount > maxContextMessages {
+		req.ContextCount = maxContextMessages
+	}
+
+	// 获取最近的聊天消息作为上下文
+	recentMessages, err := h.messageService.GetRecentMessages(chatID, req.ContextCount)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to get chat messages for AI context")
+		return &services.ContextResult{}
+	}
+
+	history := services.FormatTranscript(recentMessages)
+
+	budget := h.llmService.ContextBudget(
+		services.ChatMessage{Role: "system", Content: systemPrompt},
+		services.ChatMessage{Role: "user", Content: req.Question},
+	)
+	return h.llmService.BuildContext(ctx, history, budget)
+}
+
+// bindSummarizeRequest 解析总结请求，未提供请求体时使用默认值
+func (h *AIAssistantHandler) bindSummarizeRequest(c *gin.Context, chatID uint) SummarizeRequest {
+	var req SummarizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		// 如果没有提供请求体，使用默认值（全部消息）
+		req.MessageCount = 0
+		req.Language = ""
+	}
+
+	// 设置默认值
+	if req.Language == "" {
+		req.Language = h.chatLanguage(chatID)
+	}
+
+	return req
+}
+
+// chatLanguage 聊天室的默认 AI 语言：检测到的聊天语言，尚未检测时立即检测，都没有结果时使用英语
+func (h *AIAssistantHandler) chatLanguage(chatID uint) string {
+	if language := h.chatService.GetLanguage(chatID); language != "" {
+		return language
+	}
+	if language, err := h.chatService.DetectLanguage(chatID); err == nil && language != "" {
+		return language
+	}
+	return "en"
+}
+
+// operatorSummaryConversation 获取需要总结的消息并构建对话历史
+func (h *AIAssistantHandler) operatorSummaryConversation(chatID uint, req SummarizeRequest) ([]models.Message, []services.ChatMessage, error) {
+	// 流式总结不使用滚动总结，默认总结最近 50 条
+	if req.MessageCount == 0 {
+		req.MessageCount = 50
+	}
+
+	messages, err := h.messageService.GetRecentMessages(chatID, req.MessageCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 构建对话历史
+	conversationMessages := services.FormatTranscript(messages)
+
+	return messages, conversationMessages, nil
+}
+
 // OperatorAnalyzeFiles 分析聊天文件 - Operator专用
 func (h *AIAssistantHandler) OperatorAnalyzeFiles(c *gin.Context) {
 	operatorID, exists := middleware.GetOperatorIDFromContext(c)
@@ -350,19 +436,138 @@ func (h *AIAssistantHandler) OperatorAnalyzeFiles(c *gin.Context) {
 		req.Question = "Please analyze and summarize the files in this chat."
 	}
 
-	// TODO: 实现文件分析逻辑
-	// 1. 获取指定的文件信息
-	// 2. 读取文件内容（PDF、图片OCR等）
-	// 3. 将文件内容发送给 LLM 分析
-	// 4. 返回分析结果
+	if len(req.FileIDs) > maxAnalyzedFiles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d files can be analyzed at once", maxAnalyzedFiles)})
+		return
+	}
+
+	// 获取文件：未指定时分析最近的文档
+	var files []models.ChatFile
+	if len(req.FileIDs) == 0 {
+		files, err = h.fileService.GetRecentDocuments(uint(chatID), maxAnalyzedFiles)
+	} else {
+		files, err = h.fileService.GetChatFilesByIDs(uint(chatID), req.FileIDs)
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get chat files")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat files"})
+		return
+	}
+
+	// 所有文件都必须属于该聊天室
+	if len(req.FileIDs) > 0 {
+		found := make(map[uint]bool, len(files))
+		for _, file := range files {
+			found[file.ID] = true
+		}
+		var invalidIDs []uint
+		for _, id := range req.FileIDs {
+			if !found[id] {
+				invalidIDs = append(invalidIDs, id)
+			}
+		}
+		if len(invalidIDs) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":            "Some files do not belong to this chat",
+				"invalid_file_ids": invalidIDs,
+			})
+			return
+		}
+	}
+
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No files to analyze"})
+		return
+	}
+
+	// 提取文本并按 MaxTokens 切分
+	budget := h.llmService.MaxTokens()
+	var chunks []services.DocumentChunk
+	analyzedFiles := make([]gin.H, 0, len(files))
+	failedFiles := make([]gin.H, 0)
+	skippedFiles := make([]gin.H, 0)
+	for _, file := range files {
+		text, err := h.fileService.ExtractText(&file)
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to extract text from file %d", file.ID)
+			failedFiles = append(failedFiles, gin.H{
+				"id":        file.ID,
+				"file_name": file.FileName,
+				"reason":    err.Error(),
+			})
+			continue
+		}
+
+		// 已达到分片上限的文件完全没有发送给模型
+		remaining := maxAnalyzedChunks - len(chunks)
+		if remaining <= 0 {
+			skippedFiles = append(skippedFiles, gin.H{
+				"id":        file.ID,
+				"file_name": file.FileName,
+				"reason":    fmt.Sprintf("chunk limit of %d reached", maxAnalyzedChunks),
+			})
+			continue
+		}
+
+		// Parts 使用截断前的分片数，提示中的 "part i of n" 能看出文件被截断
+		parts := services.ChunkText(text, budget)
+		totalParts := len(parts)
+		truncated := false
+		if totalParts > remaining {
+			parts = parts[:remaining]
+			truncated = true
+		}
+		for i, part := range parts {
+			chunks = append(chunks, services.DocumentChunk{
+				FileID:   file.ID,
+				FileName: file.FileName,
+				Part:     i + 1,
+				Parts:    totalParts,
+				Text:     part,
+			})
+		}
+
+		analyzedFiles = append(analyzedFiles, gin.H{
+			"id":        file.ID,
+			"file_name": file.FileName,
+			"chunks":    len(parts),
+			"truncated": truncated,
+		})
+	}
+
+	if len(chunks) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":        "None of the files could be parsed",
+			"failed_files": failedFiles,
+		})
+		return
+	}
+
+	call := startAICall(operatorID, uint(chatID), "analyze_files")
+	chunkMessages := make([]services.ChatMessage, 0, len(chunks))
+	for _, chunk := range chunks {
+		chunkMessages = append(chunkMessages, services.ChatMessage{Role: "user", Content: chunk.Text})
+	}
+	call.setPrompt(req.Question, chunkMessages)
+
+	analysis, err := h.llmService.AnalyzeDocuments(call.track(c.Request.Context()), req.Question, chunks, budget)
+	interactionID := h.finishAICall(call, analysis, err)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to analyze files")
+		respondAIError(c, err, "Failed to analyze files")
+		return
+	}
 
-	// 临时实现：返回占位符
 	c.JSON(http.StatusOK, gin.H{
-		"analysis":    "File analysis feature coming soon. This will analyze PDFs, images, and documents in the chat.",
-		"chat_id":     chatID,
-		"operator_id": operatorID,
-		"timestamp":   time.Now(),
-		"note":        "This feature requires additional implementation for file parsing",
+		"analysis":       analysis,
+		"question":       req.Question,
+		"files":          analyzedFiles,
+		"failed_files":   failedFiles,
+		"skipped_files":  skippedFiles,
+		"chat_id":        chatID,
+		"operator_id":    operatorID,
+		"timestamp":      time.Now(),
+		"interaction_id": interactionID,
 	})
 }
 
@@ -392,7 +597,6 @@ func (h *AIAssistantHandler) SummarizeChat(c *gin.Context) {
 	if err := c.ShouldBindJSON(&req); err != nil {
 		// 如果没有提供请求体，使用默认值
 		req.MessageCount = 50
-		req.Language = "en"
 	}
 
 	// 设置默认值
@@ -400,11 +604,11 @@ func (h *AIAssistantHandler) SummarizeChat(c *gin.Context) {
 		req.MessageCount = 50
 	}
 	if req.Language == "" {
-		req.Language = "en"
+		req.Language = h.chatLanguage(uint(chatID))
 	}
 
 	// 获取聊天消息
-	messages, err := h.messageService.GetChatMessages(uint(chatID), req.MessageCount, 0)
+	messages, err := h.messageService.GetRecentMessages(uint(chatID), req.MessageCount)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to get chat messages")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat messages"})
@@ -419,36 +623,18 @@ func (h *AIAssistantHandler) SummarizeChat(c *gin.Context) {
 	}
 
 	// 构建对话历史
-	var conversationMessages []services.ChatMessage
-	for i := len(messages) - 1; i >= 0; i-- {
-		msg := messages[i]
-		if msg.Type == "text" && msg.Content != nil && *msg.Content != "" {
-			role := "user"
-			if msg.Type == "system" || msg.SenderID == nil {
-				role = "assistant"
-			}
+	conversationMessages := services.FormatTranscript(messages)
 
-			// 构建消息内容（包含发送者信息）
-			content := *msg.Content
-			if msg.Sender != nil {
-				senderName := msg.Sender.GetFullName()
-				if senderName != "" {
-					content = senderName + ": " + content
-				}
-			}
-
-			conversationMessages = append(conversationMessages, services.ChatMessage{
-				Role:    role,
-				Content: content,
-			})
-		}
+	// 调用LLM生成总结
+	prompt, ok := h.renderPrompt(c, nil, "summarize", uint(chatID), req.Language)
+	if !ok {
+		return
 	}
 
-	// 调用LLM生成总结
-	summary, err := h.llmService.SummarizeConversation(conversationMessages, req.Language)
+	summary, err := h.llmService.SummarizeConversation(c.Request.Context(), conversationMessages, prompt)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to generate summary")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate summary"})
+		respondAIError(c, err, "Failed to generate summary")
 		return
 	}
 
@@ -459,3 +645,48 @@ func (h *AIAssistantHandler) SummarizeChat(c *gin.Context) {
 		"summarized_at": messages[0].CreatedAt,
 	})
 }
+
+// contextResponse 上下文使用情况
+func contextResponse(result *services.ContextResult) gin.H {
+	return gin.H{
+		"included_messages":   result.Included,
+		"summarized_messages": result.Summarized,
+		"omitted_messages":    result.Omitted,
+		"tokens":              result.Tokens,
+	}
+}
+
+// aiErrorResponse 将 LLM 错误转换为状态码和响应体，区分限流、超时和服务商错误
+func aiErrorResponse(err error, message string) (int, gin.H) {
+	switch {
+	case errors.Is(err, services.ErrLLMRateLimited):
+		resp := gin.H{
+			"error": "AI provider is rate limiting requests, please retry later",
+			"code":  "ai_rate_limited",
+		}
+		var llmErr *services.LLMError
+		if errors.As(err, &llmErr) && llmErr.RetryAfter > 0 {
+			resp["retry_after"] = int(math.Ceil(llmErr.RetryAfter.Seconds()))
+		}
+		return http.StatusTooManyRequests, resp
+	case errors.Is(err, services.ErrLLMTimeout):
+		return http.StatusGatewayTimeout, gin.H{"error": "AI provider timed out", "code": "ai_timeout"}
+	case errors.Is(err, services.ErrLLMUnavailable):
+		return http.StatusServiceUnavailable, gin.H{"error": "AI provider is temporarily unavailable", "code": "ai_unavailable"}
+	case errors.Is(err, services.ErrLLMProvider):
+		return http.StatusBadGateway, gin.H{"error": message, "code": "ai_provider_error"}
+	case errors.Is(err, services.ErrInvalidJSON):
+		return http.StatusBadGateway, gin.H{"error": "AI returned an invalid response", "code": "ai_invalid_response"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": message}
+	}
+}
+
+// respondAIError 返回 LLM 错误响应
+func respondAIError(c *gin.Context, err error, message string) {
+	status, resp := aiErrorResponse(err, message)
+	if retryAfter, ok := resp["retry_after"]; ok {
+		c.Header("Retry-After", fmt.Sprint(retryAfter))
+	}
+	c.JSON(status, resp)
+}
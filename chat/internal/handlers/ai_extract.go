@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"kelisim-chat/internal/middleware"
+	"kelisim-chat/internal/models"
+	"kelisim-chat/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ExtractCaseRequest 案件信息提取请求
+type ExtractCaseRequest struct {
+	Refresh bool `json:"refresh"` // 没有新消息时也重新提取
+}
+
+// OperatorExtractCase 从聊天记录中提取当事人、日期、金额、问题分类和待确认问题 - Operator专用
+// 结果按聊天室保存；没有新消息时直接返回已保存的结果，除非 refresh 为 true
+func (h *AIAssistantHandler) OperatorExtractCase(c *gin.Context) {
+	operatorID, exists := middleware.GetOperatorIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Operator not authenticated"})
+		return
+	}
+
+	chatIDStr := c.Param("id")
+	chatID, err := strconv.ParseUint(chatIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	var req ExtractCaseRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	messages, err := h.messageService.GetRecentMessages(uint(chatID), maxContextMessages)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get chat messages")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat messages"})
+		return
+	}
+
+	transcript := services.FormatTranscript(messages)
+	if len(transcript) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No messages to extract from"})
+		return
+	}
+	lastMessageID := messages[len(messages)-1].ID
+
+	// 没有新消息时复用已保存的结果
+	if !req.Refresh {
+		stored, err := h.caseExtractionService.GetByChatID(uint(chatID))
+		if err == nil && stored.LastMessageID == lastMessageID {
+			c.JSON(http.StatusOK, caseExtractionResponse(stored, true, true))
+			return
+		}
+	}
+
+	call := startAICall(operatorID, uint(chatID), "extract_case")
+	ctx := call.track(c.Request.Context())
+
+	instructions, ok := h.renderPrompt(c, call, "extract_case", uint(chatID), "")
+	if !ok {
+		return
+	}
+
+	// 聊天记录过长时按上下文窗口裁剪，较早的消息会被总结
+	contextResult := h.llmService.BuildContext(ctx, transcript, h.llmService.ContextBudget(services.CaseExtractionPrompt(instructions)))
+	call.setPrompt("", contextResult.Messages)
+
+	extraction, raw, err := h.llmService.ExtractCase(ctx, instructions, contextResult.Messages)
+	interactionID := h.finishAICall(call, raw, err)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to extract case information")
+		respondAIError(c, err, "Failed to extract case information")
+		return
+	}
+
+	// 保存校验后的结果（空列表为 []），而不是模型的原始输出
+	data, _ := json.Marshal(extraction)
+
+	record := &models.ChatCaseExtraction{
+		ChatID:        uint(chatID),
+		IssueCategory: extraction.IssueCategory,
+		Data:          string(data),
+		LastMessageID: lastMessageID,
+		MessageCount:  len(messages),
+		OperatorID:    operatorID,
+		InteractionID: interactionID,
+	}
+	if err := h.caseExtractionService.Save(record); err != nil {
+		logrus.WithError(err).Error("Failed to save case extraction")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save case extraction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, caseExtractionResponse(record, true, false))
+}
+
+// GetCaseExtraction 获取聊天室已保存的案件信息 - Operator专用
+func (h *AIAssistantHandler) GetCaseExtraction(c *gin.Context) {
+	chatIDStr := c.Param("id")
+	chatID, err := strconv.ParseUint(chatIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	record, err := h.caseExtractionService.GetByChatID(uint(chatID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Case information has not been extracted yet"})
+			return
+		}
+		logrus.WithError(err).Error("Failed to get case extraction")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get case extraction"})
+		return
+	}
+
+	// 之后有新消息时提示需要刷新
+	upToDate := true
+	latest, err := h.messageService.GetRecentMessages(uint(chatID), 1)
+	if err == nil && len(latest) > 0 && latest[0].ID != record.LastMessageID {
+		upToDate = false
+	}
+
+	c.JSON(http.StatusOK, caseExtractionResponse(record, upToDate, true))
+}
+
+// caseExtractionResponse 案件信息响应
+func caseExtractionResponse(record *models.ChatCaseExtraction, upToDate bool, cached bool) gin.H {
+	return gin.H{
+		"chat_id":         record.ChatID,
+		"extraction":      json.RawMessage(record.Data),
+		"issue_category":  record.IssueCategory,
+		"last_message_id": record.LastMessageID,
+		"message_count":   record.MessageCount,
+		"operator_id":     record.OperatorID,
+		"interaction_id":  record.InteractionID,
+		"extracted_at":    record.UpdatedAt,
+		"up_to_date":      upToDate,
+		"cached":          cached,
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"kelisim-chat/internal/models"
+	"kelisim-chat/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// aiCall 一次 Operator AI 调用，结束时写入 ai_interactions
+type aiCall struct {
+	operatorID uint
+	chatID     uint
+	endpoint   string
+	promptHash string
+	template   string
+	start      time.Time
+	usage      *services.LLMUsage
+}
+
+// startAICall 开始记录 AI 调用
+func startAICall(operatorID uint, chatID uint, endpoint string) *aiCall {
+	return &aiCall{
+		operatorID: operatorID,
+		chatID:     chatID,
+		endpoint:   endpoint,
+		start:      time.Now(),
+		usage:      &services.LLMUsage{},
+	}
+}
+
+// setPrompt 根据最终发送给模型的内容计算 prompt_hash
+func (call *aiCall) setPrompt(question string, messages []services.ChatMessage) {
+	parts := []string{call.endpoint, question}
+	for _, message := range messages {
+		parts = append(parts, message.Role, message.Content)
+	}
+	call.promptHash = services.HashPrompt(parts...)
+}
+
+// setTemplate 记录使用的提示词模板版本
+func (call *aiCall) setTemplate(templateID string) {
+	call.template = templateID
+}
+
+// track 返回统计本次调用 token 用量的 context
+func (call *aiCall) track(ctx context.Context) context.Context {
+	return services.WithUsage(ctx, call.usage)
+}
+
+// finishAICall 保存 AI 调用记录并返回记录ID，保存失败只记录日志并返回 0
+func (h *AIAssistantHandler) finishAICall(call *aiCall, answer string, callErr error) uint {
+	usage := call.usage.Snapshot()
+
+	interaction := &models.AIInteraction{
+		OperatorID:       call.operatorID,
+		ChatID:           call.chatID,
+		Endpoint:         call.endpoint,
+		PromptHash:       call.promptHash,
+		PromptTemplate:   call.template,
+		Provider:         usage.Provider,
+		Model:            usage.Model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		LatencyMs:        time.Since(call.start).Milliseconds(),
+		Status:           "success",
+	}
+	if callErr != nil {
+		message := callErr.Error()
+		if len(message) > 500 {
+			message = message[:500]
+		}
+		interaction.Status = "error"
+		interaction.Error = &message
+	} else {
+		interaction.Answer = &answer
+	}
+
+	if err := h.aiInteractionService.Record(interaction); err != nil {
+		logrus.WithError(err).Error("Failed to record AI interaction")
+		return 0
+	}
+	return interaction.ID
+}
+
+// GetAIHistory 获取 AI 调用历史 (Operator 专用)
+// 支持 ?chat_id= 和 ?operator_id= 过滤
+func (h *AIAssistantHandler) GetAIHistory(c *gin.Context) {
+	chatID, err := parseOptionalID(c.Query("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+	operatorID, err := parseOptionalID(c.Query("operator_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid operator ID"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	interactions, total, err := h.aiInteractionService.GetHistory(chatID, operatorID, limit, offset)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get AI history")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   interactions,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetAIUsage 按 Operator 和日期统计 AI 用量 (Operator 专用)
+// ?from=2025-11-01&to=2025-11-30（包含两端，默认最近 30 天），?operator_id= 可选
+func (h *AIAssistantHandler) GetAIUsage(c *gin.Context) {
+	now := time.Now()
+	from := now.AddDate(0, 0, -29)
+	to := now
+
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+
+	operatorID, err := parseOptionalID(c.Query("operator_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid operator ID"})
+		return
+	}
+
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.Local)
+	if toDay.Before(fromDay) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+
+	filter := services.AIUsageFilter{
+		From:       fromDay,
+		To:         toDay.AddDate(0, 0, 1),
+		OperatorID: operatorID,
+	}
+
+	total, err := h.aiInteractionService.GetTotalUsage(filter)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get AI usage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI usage"})
+		return
+	}
+	operators, err := h.aiInteractionService.GetOperatorUsage(filter)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get AI usage per operator")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI usage"})
+		return
+	}
+	daily, err := h.aiInteractionService.GetDailyUsage(filter)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get daily AI usage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":      fromDay.Format("2006-01-02"),
+		"to":        toDay.Format("2006-01-02"),
+		"total":     total,
+		"operators": operators,
+		"daily":     daily,
+	})
+}
+
+// parseOptionalID 解析可选的 ID 查询参数，为空时返回 0
+func parseOptionalID(value string) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	return uint(id), err
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// publishableAIEndpoints 可以发布到聊天室的 AI 调用（回复建议通过 suggest-replies/send 发送）
+var publishableAIEndpoints = map[string]bool{
+	"ask":              true,
+	"ask_stream":       true,
+	"summarize":        true,
+	"summarize_stream": true,
+	"analyze_files":    true,
+}
+
+// PublishAIAnswerRequest 发布 AI 回答请求
+type PublishAIAnswerRequest struct {
+	InteractionID uint   `json:"interaction_id" binding:"required"` // ai/ask 等接口返回的 interaction_id
+	Content       string `json:"content"`                           // 审阅修改后的回答，省略时发布原始回答
+}
+
+// PublishAIAnswer 将 Operator 审阅过的 AI 回答作为 ai_assistant 消息发布到聊天室 - Operator专用
+// 消息记录发布的 Operator，通知和广播与普通 Operator 消息一致
+func (h *MessageHandler) PublishAIAnswer(c *gin.Context) {
+	chatIDStr := c.Param("id")
+	chatID, err := strconv.ParseUint(chatIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	var req PublishAIAnswerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	interaction, err := h.aiInteractionService.GetByID(req.InteractionID)
+	if err != nil || interaction.ChatID != uint(chatID) || !publishableAIEndpoints[interaction.Endpoint] ||
+		interaction.Status != "success" || interaction.Answer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "AI answer not found"})
+		return
+	}
+
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		content = *interaction.Answer
+	}
+
+	body, _ := json.Marshal(SendMessageRequest{
+		Type:    "ai_assistant",
+		Content: content,
+	})
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+
+	h.SendMessage(c)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"kelisim-chat/internal/middleware"
+	"kelisim-chat/internal/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// SearchRequest 跨聊天检索请求
+type SearchRequest struct {
+	Question     string `json:"question" binding:"required"`
+	TopK         int    `json:"top_k"`         // 检索的片段数，默认 8，最多 30
+	ChatID       uint   `json:"chat_id"`       // 只检索指定聊天室，0 表示全部
+	From         string `json:"from"`          // YYYY-MM-DD，包含
+	To           string `json:"to"`            // YYYY-MM-DD，包含
+	Language     string `json:"language"`      // 回答语言，默认与问题相同
+	RetrieveOnly bool   `json:"retrieve_only"` // 只返回片段，不调用模型
+}
+
+// SearchCitation 回答中引用的片段
+type SearchCitation struct {
+	Index int `json:"index"` // 回答中的 [n]
+	services.SearchHit
+}
+
+// OperatorSearch 跨所有聊天检索相关的消息和文件片段，并根据片段回答问题 - Operator专用
+// 回答中的 [n] 对应 snippets 的第 n 条，citations 列出被引用的片段及其聊天室和消息ID
+func (h *AIAssistantHandler) OperatorSearch(c *gin.Context) {
+	operatorID, exists := middleware.GetOperatorIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Operator not authenticated"})
+		return
+	}
+
+	var req SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.TopK <= 0 {
+		req.TopK = 8
+	}
+	if req.TopK > 30 {
+		req.TopK = 30
+	}
+
+	opts := services.SearchOptions{TopK: req.TopK, ChatID: req.ChatID}
+	if req.From != "" {
+		from, err := time.ParseInLocation("2006-01-02", req.From, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		opts.From = from
+	}
+	if req.To != "" {
+		to, err := time.ParseInLocation("2006-01-02", req.To, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		opts.To = to.AddDate(0, 0, 1)
+	}
+
+	hits, err := h.embeddingService.Search(c.Request.Context(), req.Question, opts)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to search messages")
+		respondAIError(c, err, "Failed to search messages")
+		return
+	}
+
+	response := gin.H{
+		"question": req.Question,
+		"snippets": hits,
+		"model":    h.embeddingService.Model(),
+	}
+	if req.RetrieveOnly || len(hits) == 0 {
+		if len(hits) == 0 {
+			response["answer"] = "No relevant messages found"
+		}
+		response["citations"] = []SearchCitation{}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	call := startAICall(operatorID, req.ChatID, "search")
+	ctx := call.track(c.Request.Context())
+
+	systemPrompt, ok := h.renderPrompt(c, call, "search_answer", 0, req.Language)
+	if !ok {
+		return
+	}
+
+	contextMessages := []services.ChatMessage{services.SnippetContext(hits)}
+	call.setPrompt(req.Question, contextMessages)
+
+	answer, err := h.llmService.AskQuestion(ctx, req.Question, systemPrompt, contextMessages)
+	interactionID := h.finishAICall(call, answer, err)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to answer search question")
+		respondAIError(c, err, "Failed to get AI response")
+		return
+	}
+
+	citations := []SearchCitation{}
+	for _, n := range services.CitedSnippets(answer, len(hits)) {
+		citations = append(citations, SearchCitation{Index: n, SearchHit: hits[n-1]})
+	}
+
+	response["answer"] = answer
+	response["citations"] = citations
+	response["timestamp"] = time.Now()
+	response["operator_id"] = operatorID
+	response["interaction_id"] = interactionID
+	c.JSON(http.StatusOK, response)
+}
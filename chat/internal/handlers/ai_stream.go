@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"kelisim-chat/internal/middleware"
+	"kelisim-chat/internal/services"
+	"kelisim-chat/internal/websocket"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// aiStreamTimeout WebSocket 推送模式下单次流式输出的最长时间
+const aiStreamTimeout = 5 * time.Minute
+
+// aiStreamFunc 执行一次流式 LLM 调用
+type aiStreamFunc func(ctx context.Context, onDelta services.DeltaHandler) (string, error)
+
+// OperatorAskAIStream 流式 AI 提问 - Operator专用
+func (h *AIAssistantHandler) OperatorAskAIStream(c *gin.Context) {
+	operatorID, exists := middleware.GetOperatorIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Operator not authenticated"})
+		return
+	}
+
+	chatIDStr := c.Param("id")
+	chatID, err := strconv.ParseUint(chatIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	var req AskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	call := startAICall(operatorID, uint(chatID), "ask_stream")
+
+	systemPrompt, ok := h.renderPrompt(c, call, "operator_ask", uint(chatID), "")
+	if !ok {
+		return
+	}
+
+	contextResult := h.buildAskContext(call.track(c.Request.Context()), uint(chatID), &req, systemPrompt)
+	call.setPrompt(req.Question, contextResult.Messages)
+
+	h.streamAIResponse(c, call, "answer", gin.H{
+		"context_used": req.IncludeContext,
+		"context":      contextResponse(contextResult),
+	}, func(ctx context.Context, onDelta services.DeltaHandler) (string, error) {
+		return h.llmService.AskQuestionStream(ctx, req.Question, systemPrompt, contextResult.Messages, onDelta)
+	})
+}
+
+// OperatorSummarizeStream 流式总结聊天记录 - Operator专用
+func (h *AIAssistantHandler) OperatorSummarizeStream(c *gin.Context) {
+	operatorID, exists := middleware.GetOperatorIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Operator not authenticated"})
+		return
+	}
+
+	chatIDStr := c.Param("id")
+	chatID, err := strconv.ParseUint(chatIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	req := h.bindSummarizeRequest(c, uint(chatID))
+
+	messages, conversationMessages, err := h.operatorSummaryConversation(uint(chatID), req)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get chat messages")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat messages"})
+		return
+	}
+
+	if len(messages) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"summary": "No messages to summarize",
+		})
+		return
+	}
+
+	call := startAICall(operatorID, uint(chatID), "summarize_stream")
+	call.setPrompt(req.Language, conversationMessages)
+
+	prompt, ok := h.renderPrompt(c, call, "summarize", uint(chatID), req.Language)
+	if !ok {
+		return
+	}
+
+	h.streamAIResponse(c, call, "summary", gin.H{
+		"message_count": len(messages),
+		"language":      req.Language,
+	}, func(ctx context.Context, onDelta services.DeltaHandler) (string, error) {
+		return h.llmService.SummarizeConversationStream(ctx, conversationMessages, prompt, onDelta)
+	})
+}
+
+// streamAIResponse 输出流式 AI 结果
+// 默认以 text/event-stream 返回 start/delta/done/error 事件；
+// ?transport=ws 时立即返回 202 和 stream_id，增量通过 Operator 自己的 WebSocket 连接以 ai_delta 事件推送
+// 结束后（包括客户端断开）写入 ai_interactions
+func (h *AIAssistantHandler) streamAIResponse(c *gin.Context, call *aiCall, answerKey string, result gin.H, run aiStreamFunc) {
+	streamID := generateStreamID()
+	operatorID := call.operatorID
+	chatID := call.chatID
+
+	if c.Query("transport") == "ws" {
+		if h.hub == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "WebSocket is not available"})
+			return
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(call.track(context.Background()), aiStreamTimeout)
+			defer cancel()
+
+			answer, err := run(ctx, func(delta string) error {
+				h.hub.SendToOperator(operatorID, websocket.ServerMessage{
+					Type:     websocket.AIDelta,
+					ChatID:   chatID,
+					StreamID: streamID,
+					Delta:    delta,
+				})
+				return nil
+			})
+			h.finishAICall(call, answer, err)
+
+			final := websocket.ServerMessage{
+				Type:     websocket.AIDelta,
+				ChatID:   chatID,
+				StreamID: streamID,
+				Done:     true,
+			}
+			if err != nil {
+				logrus.WithError(err).Error("Failed to stream AI response for operator")
+				_, resp := aiErrorResponse(err, "Failed to get AI response")
+				final.Error = resp["error"].(string)
+				if code, ok := resp["code"].(string); ok {
+					final.Status = code
+				}
+			}
+			h.hub.SendToOperator(operatorID, final)
+		}()
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"stream_id": streamID,
+			"transport": "ws",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁止 nginx 缓冲
+	c.Status(http.StatusOK)
+
+	c.SSEvent("start", gin.H{"stream_id": streamID})
+	c.Writer.Flush()
+
+	ctx := call.track(c.Request.Context())
+	answer, err := run(ctx, func(delta string) error {
+		c.SSEvent("delta", gin.H{"delta": delta})
+		c.Writer.Flush()
+		return ctx.Err()
+	})
+	interactionID := h.finishAICall(call, answer, err)
+	if err != nil {
+		if ctx.Err() == nil {
+			logrus.WithError(err).Error("Failed to stream AI response for operator")
+			_, resp := aiErrorResponse(err, "Failed to get AI response")
+			c.SSEvent("error", resp)
+			c.Writer.Flush()
+		}
+		return
+	}
+
+	result[answerKey] = answer
+	result["stream_id"] = streamID
+	result["operator_id"] = operatorID
+	result["timestamp"] = time.Now()
+	result["interaction_id"] = interactionID
+	c.SSEvent("done", result)
+	c.Writer.Flush()
+}
+
+// generateStreamID 生成流式输出ID
+func generateStreamID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
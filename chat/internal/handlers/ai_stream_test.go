@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"kelisim-chat/internal/config"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sseEvent is one event of a text/event-stream response
+type sseEvent struct {
+	Name string
+	Data map[string]interface{}
+}
+
+func TestStreamAIResponseEvents(t *testing.T) {
+	engine := newStreamTestEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{"Hel", "lo"} {
+			io.WriteString(w, fakeStreamChunk(delta))
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	})
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/stream", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	events := parseSSEEvents(t, rec.Body)
+	names := make([]string, len(events))
+	for i, event := range events {
+		names[i] = event.Name
+	}
+	if got, want := strings.Join(names, ","), "start,delta,delta,done"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+
+	streamID := events[0].Data["stream_id"]
+	if streamID == "" || streamID == nil {
+		t.Fatalf("start event has no stream_id: %v", events[0].Data)
+	}
+	if events[1].Data["delta"] != "Hel" || events[2].Data["delta"] != "lo" {
+		t.Fatalf("deltas = %v, %v, want Hel, lo", events[1].Data, events[2].Data)
+	}
+
+	done := events[3].Data
+	if done["answer"] != "Hello" || done["stream_id"] != streamID || done["operator_id"] != float64(1) || done["context_used"] != false {
+		t.Fatalf("done event = %v", done)
+	}
+}
+
+func TestStreamAIResponseError(t *testing.T) {
+	engine := newStreamTestEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":{"message":"bad request","type":"invalid_request_error"}}`)
+	})
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/stream", nil))
+
+	events := parseSSEEvents(t, rec.Body)
+	if len(events) != 2 || events[0].Name != "start" || events[1].Name != "error" {
+		t.Fatalf("events = %v, want start then error", events)
+	}
+	if events[1].Data["error"] == nil {
+		t.Fatalf("error event has no message: %v", events[1].Data)
+	}
+}
+
+func TestStreamAIResponseClientDisconnect(t *testing.T) {
+	upstreamCancelled := make(chan struct{})
+	engine := newStreamTestEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, fakeStreamChunk("first"))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-r.Context().Done():
+			close(upstreamCancelled)
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Disconnect as soon as the first delta arrives
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended before the first delta: %v", err)
+		}
+		if strings.TrimSpace(line) == "event:delta" {
+			break
+		}
+	}
+	cancel()
+
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("LLM request was not cancelled after the client disconnected")
+	}
+}
+
+// newStreamTestEngine serves streamAIResponse on POST /stream, backed by a local fake LLM server.
+// AI interactions are recorded against a dry-run database, so no MySQL is needed.
+func newStreamTestEngine(t *testing.T, llm http.HandlerFunc) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	llmServer := httptest.NewServer(llm)
+	t.Cleanup(llmServer.Close)
+
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "test:test@tcp(127.0.0.1:1)/test", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open dry-run database: %v", err)
+	}
+	database.DB = db
+
+	config.AppConfig = &config.Config{}
+	h := &AIAssistantHandler{
+		llmService: services.NewLLMServiceWithProvider(services.NewOpenAIProvider(config.LLMProviderConfig{
+			APIKey:  "test",
+			APIBase: llmServer.URL,
+			Model:   "test-model",
+			Timeout: 5,
+		})),
+		aiInteractionService: services.NewAIInteractionService(),
+	}
+
+	engine := gin.New()
+	engine.POST("/stream", func(c *gin.Context) {
+		call := startAICall(1, 2, "ask_stream")
+		h.streamAIResponse(c, call, "answer", gin.H{"context_used": false}, func(ctx context.Context, onDelta services.DeltaHandler) (string, error) {
+			return h.llmService.AskQuestionStream(ctx, "question", "system", nil, onDelta)
+		})
+	})
+	return engine
+}
+
+// parseSSEEvents splits a text/event-stream body into events with JSON data
+func parseSSEEvents(t *testing.T, body io.Reader) []sseEvent {
+	t.Helper()
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(string(raw)), "\n\n") {
+		var event sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			field, value, _ := strings.Cut(line, ":")
+			switch field {
+			case "event":
+				event.Name = value
+			case "data":
+				if err := json.Unmarshal([]byte(value), &event.Data); err != nil {
+					t.Fatalf("event %q has invalid data %q: %v", event.Name, value, err)
+				}
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// fakeStreamChunk formats one OpenAI-compatible SSE chunk carrying a content delta
+func fakeStreamChunk(content string) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"choices": []interface{}{
+			map[string]interface{}{"delta": map[string]string{"content": content}},
+		},
+	})
+	return "data: " + string(data) + "\n\n"
+}
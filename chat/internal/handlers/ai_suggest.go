@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"kelisim-chat/internal/middleware"
+	"kelisim-chat/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 回复建议：参考的最近消息数和建议数量范围
+const (
+	suggestReplyContextMessages = 30
+	minSuggestedReplies         = services.MinReplySuggestions
+	maxSuggestedReplies         = 3
+)
+
+// SuggestRepliesRequest 回复建议请求
+type SuggestRepliesRequest struct {
+	Count    int    `json:"count"`    // 建议数量，2-3，默认3
+	Language string `json:"language"` // ru/kk/en/zh，默认使用检测到的聊天语言
+}
+
+// OperatorSuggestReplies 根据最近的聊天记录生成回复草稿 - Operator专用
+func (h *AIAssistantHandler) OperatorSuggestReplies(c *gin.Context) {
+	operatorID, exists := middleware.GetOperatorIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Operator not authenticated"})
+		return
+	}
+
+	chatIDStr := c.Param("id")
+	chatID, err := strconv.ParseUint(chatIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	var req SuggestRepliesRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.Count == 0 {
+		req.Count = maxSuggestedReplies
+	}
+	if req.Count < minSuggestedReplies || req.Count > maxSuggestedReplies {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "count must be between 2 and 3"})
+		return
+	}
+	if _, ok := services.SupportedLanguages[req.Language]; req.Language != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported language"})
+		return
+	}
+
+	messages, err := h.messageService.GetRecentMessages(uint(chatID), suggestReplyContextMessages)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get chat messages")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat messages"})
+		return
+	}
+
+	transcript := services.FormatTranscript(messages)
+	if len(transcript) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No messages to reply to"})
+		return
+	}
+
+	language := req.Language
+	if language == "" {
+		language = h.chatLanguage(uint(chatID))
+	}
+
+	call := startAICall(operatorID, uint(chatID), "suggest_replies")
+	ctx := call.track(c.Request.Context())
+
+	data := services.PromptData(uint(chatID), language)
+	data.Count = req.Count
+	instructions, ok := h.renderPromptData(c, call, "suggest_replies", data)
+	if !ok {
+		return
+	}
+
+	// 聊天记录过长时按上下文窗口裁剪
+	contextResult := h.llmService.BuildContext(ctx, transcript, h.llmService.ContextBudget(services.ReplySuggestionPrompt(instructions)))
+	call.setPrompt(language, contextResult.Messages)
+
+	suggestions, err := h.llmService.SuggestReplies(ctx, instructions, contextResult.Messages, req.Count)
+
+	// 记录的回答为建议列表的 JSON，发送时据此取出建议
+	var answer string
+	if err == nil {
+		data, _ := json.Marshal(services.ReplySuggestions{Suggestions: suggestions})
+		answer = string(data)
+	}
+	interactionID := h.finishAICall(call, answer, err)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to suggest replies")
+		respondAIError(c, err, "Failed to suggest replies")
+		return
+	}
+
+	items := make([]gin.H, 0, len(suggestions))
+	for i, suggestion := range suggestions {
+		items = append(items, gin.H{
+			"index":     i,
+			"reply":     suggestion.Reply,
+			"rationale": suggestion.Rationale,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"suggestions":    items,
+		"language":       language,
+		"chat_id":        chatID,
+		"interaction_id": interactionID,
+	})
+}
+
+// SendSuggestedReplyRequest 发送回复建议请求
+type SendSuggestedReplyRequest struct {
+	InteractionID uint `json:"interaction_id" binding:"required"` // suggest-replies 返回的 interaction_id
+	Index         int  `json:"index"`                             // 建议序号
+}
+
+// SendSuggestedReply 将选中的回复建议作为 Operator 消息发送 - Operator专用
+// 取出建议后交给 SendMessage 处理，通知和广播与普通 Operator 消息一致
+func (h *MessageHandler) SendSuggestedReply(c *gin.Context) {
+	chatIDStr := c.Param("id")
+	chatID, err := strconv.ParseUint(chatIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	var req SendSuggestedReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	interaction, err := h.aiInteractionService.GetByID(req.InteractionID)
+	if err != nil || interaction.ChatID != uint(chatID) || interaction.Endpoint != "suggest_replies" ||
+		interaction.Status != "success" || interaction.Answer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
+		return
+	}
+
+	var stored services.ReplySuggestions
+	if err := json.Unmarshal([]byte(*interaction.Answer), &stored); err != nil {
+		logrus.WithError(err).Errorf("Failed to parse stored suggestions of interaction %d", interaction.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load suggestion"})
+		return
+	}
+	if req.Index < 0 || req.Index >= len(stored.Suggestions) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
+		return
+	}
+
+	body, _ := json.Marshal(SendMessageRequest{
+		Type:    "text",
+		Content: stored.Suggestions[req.Index].Reply,
+	})
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+
+	h.SendMessage(c)
+}
@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"kelisim-chat/internal/middleware"
 	"kelisim-chat/internal/services"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 // ChatHandler 聊天处理器
@@ -22,6 +25,187 @@ func NewChatHandler() *ChatHandler {
 	}
 }
 
+// getOperatorChats Operator 聊天列表：游标分页、过滤、排序和搜索
+// 支持 ?type=、?user_type=、?organization_id=、?created_from=、?created_to=、?updated_from=、?updated_to=（YYYY-MM-DD，包含两端）、
+// ?unanswered=、?has_files=、?flagged=、?q=（标题或参与者姓名）、?sort=updated_at|created_at、?order=desc|asc、?limit=、?cursor=
+func (h *ChatHandler) getOperatorChats(c *gin.Context) {
+	operatorID, ok := middleware.GetOperatorIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Operator not authenticated"})
+		return
+	}
+
+	page, ok := parseChatPage(c, false)
+	if !ok {
+		return
+	}
+
+	filter := services.OperatorChatFilter{
+		Type:     c.Query("type"),
+		UserType: c.Query("user_type"),
+		Search:   c.Query("q"),
+	}
+	if filter.Type != "" && filter.Type != "private" && filter.Type != "group" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type, expected private or group"})
+		return
+	}
+	switch filter.UserType {
+	case "", "company_admin", "expert", "lawyer":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_type, expected company_admin, expert or lawyer"})
+		return
+	}
+
+	organizationID, err := parseOptionalID(c.Query("organization_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	filter.OrganizationID = organizationID
+
+	if filter.CreatedFrom, ok = parseDateQuery(c, "created_from", false); !ok {
+		return
+	}
+	if filter.CreatedTo, ok = parseDateQuery(c, "created_to", true); !ok {
+		return
+	}
+	if filter.UpdatedFrom, ok = parseDateQuery(c, "updated_from", false); !ok {
+		return
+	}
+	if filter.UpdatedTo, ok = parseDateQuery(c, "updated_to", true); !ok {
+		return
+	}
+	if filter.Unanswered, ok = parseBoolQuery(c, "unanswered"); !ok {
+		return
+	}
+	if filter.HasFiles, ok = parseBoolQuery(c, "has_files"); !ok {
+		return
+	}
+	if filter.Flagged, ok = parseBoolQuery(c, "flagged"); !ok {
+		return
+	}
+
+	chats, nextCursor, err := h.chatService.ListOperatorChats(operatorID, filter, page)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		logrus.WithError(err).Error("Failed to list operator chats")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chats":       chats,
+		"limit":       page.Limit,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != "",
+	})
+}
+
+// getUserChats 用户聊天列表：最后一条消息、未读数和提及标记，游标分页
+// 支持 ?updated_since=（RFC 3339 时间，只返回之后有更新的聊天室，用于增量同步）、?sort=、?order=、?limit=、?cursor=
+func (h *ChatHandler) getUserChats(c *gin.Context, userID uint) {
+	page, ok := parseChatPage(c, true)
+	if !ok {
+		return
+	}
+
+	var updatedSince time.Time
+	if value := c.Query("updated_since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid updated_since, expected an RFC 3339 timestamp"})
+			return
+		}
+		updatedSince = since
+	}
+
+	// 同步时间取查询之前，下次以它为 updated_since 不会漏掉查询期间更新的聊天室
+	syncedAt := time.Now()
+	chats, nextCursor, err := h.chatService.ListUserChats(userID, updatedSince, page)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		logrus.WithError(err).Error("Failed to list user chats")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chats":       chats,
+		"limit":       page.Limit,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != "",
+		"synced_at":   syncedAt.Format(time.RFC3339),
+	})
+}
+
+// parseChatPage 解析聊天列表的 limit、cursor、sort 和 order 参数，失败时已写入 400 响应
+// unbounded 为 true 时，未传 limit 和 cursor 的请求返回全部聊天室（兼容分页之前的客户端）
+func parseChatPage(c *gin.Context, unbounded bool) (services.ChatPage, bool) {
+	page := services.ChatPage{
+		Cursor: c.Query("cursor"),
+		SortBy: c.DefaultQuery("sort", "updated_at"),
+	}
+	if !unbounded || page.Cursor != "" || c.Query("limit") != "" {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit <= 0 || limit > 100 {
+			limit = 20
+		}
+		page.Limit = limit
+	}
+
+	if page.SortBy != "updated_at" && page.SortBy != "created_at" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, expected updated_at or created_at"})
+		return page, false
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+	case "asc":
+		page.Ascending = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order, expected asc or desc"})
+		return page, false
+	}
+	return page, true
+}
+
+// parseDateQuery 解析 YYYY-MM-DD 格式的查询参数，endOfDay 为 true 时返回次日零点（用于包含当天的结束日期）
+// 参数为空时返回零值，格式错误时已写入 400 响应
+func parseDateQuery(c *gin.Context, name string, endOfDay bool) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, true
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " date, expected YYYY-MM-DD"})
+		return time.Time{}, false
+	}
+	if endOfDay {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date, true
+}
+
+// parseBoolQuery 解析布尔查询参数（true/false/1/0），参数为空时返回 false，格式错误时已写入 400 响应
+func parseBoolQuery(c *gin.Context, name string) (bool, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return false, true
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + ", expected true or false"})
+		return false, false
+	}
+	return b, true
+}
+
 // CreateChatRequest 创建聊天请求
 type CreateChatRequest struct {
 	Title          string `json:"title" binding:"required"`
@@ -40,29 +224,13 @@ func (h *ChatHandler) GetChats(c *gin.Context) {
 	// 检查是否是 Operator
 	isOperator, _ := c.Get("is_operator")
 	if isOp, ok := isOperator.(bool); ok && isOp {
-		// Operator 可以看到所有聊天
-		chats, err := h.chatService.GetAllChats()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chats"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"chats": chats,
-		})
+		// Operator 可以看到所有聊天（分页、过滤）
+		h.getOperatorChats(c)
 		return
 	}
 
 	// 普通用户只能看到自己参与的聊天
-	chats, err := h.chatService.GetUserChats(userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chats"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"chats": chats,
-	})
+	h.getUserChats(c, userID)
 }
 
 // CreateChat 创建聊天室
@@ -375,3 +543,104 @@ func (h *ChatHandler) GetOrganizationMembers(c *gin.Context) {
 		"members": availableMembers,
 	})
 }
+
+// SetAIEnabledRequest 参与者 AI 助手开关请求
+type SetAIEnabledRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// SetAIEnabled 开启或关闭聊天室参与者的 AI 助手（公司管理员或 Operator）
+func (h *ChatHandler) SetAIEnabled(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	chatIDStr := c.Param("id")
+	chatID, err := strconv.ParseUint(chatIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	// 公司管理员只能修改自己所在的聊天室（Operator 跳过检查）
+	isOperator, _ := c.Get("is_operator")
+	if isOp, ok := isOperator.(bool); !ok || !isOp {
+		if !h.chatService.IsUserInChat(uint(chatID), userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		isAdmin, err := h.chatService.IsCompanyAdmin(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user permissions"})
+			return
+		}
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only company administrators can change the AI assistant setting"})
+			return
+		}
+	}
+
+	var req SetAIEnabledRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.chatService.SetAIEnabled(uint(chatID), req.Enabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update chat"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chat_id":    chatID,
+		"ai_enabled": req.Enabled,
+	})
+}
+
+// SetAutoTranslateRequest 自动翻译开关请求
+type SetAutoTranslateRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// SetAutoTranslate 开启或关闭聊天室的自动翻译
+func (h *ChatHandler) SetAutoTranslate(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	chatIDStr := c.Param("id")
+	chatID, err := strconv.ParseUint(chatIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	// 检查用户是否在聊天室中（Operator 跳过检查）
+	isOperator, _ := c.Get("is_operator")
+	if isOp, ok := isOperator.(bool); !ok || !isOp {
+		if !h.chatService.IsUserInChat(uint(chatID), userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+	}
+
+	var req SetAutoTranslateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.chatService.SetAutoTranslate(uint(chatID), req.Enabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update chat"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chat_id":        chatID,
+		"auto_translate": req.Enabled,
+	})
+}
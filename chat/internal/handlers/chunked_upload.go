@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"errors"
+	"kelisim-chat/internal/config"
+	"kelisim-chat/internal/middleware"
+	"kelisim-chat/internal/models"
+	"kelisim-chat/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateUploadRequest 创建分片上传会话请求
+type CreateUploadRequest struct {
+	FileName string `json:"file_name" binding:"required"`
+	FileSize int64  `json:"file_size" binding:"required,gt=0"`
+	Checksum string `json:"checksum" binding:"required"` // 整个文件的 SHA-256（十六进制）
+}
+
+// CreateUploadSession 创建分片上传会话
+func (h *FileHandler) CreateUploadSession(c *gin.Context) {
+	userID, isOp, chatID, ok := h.uploadAccess(c)
+	if !ok {
+		return
+	}
+
+	var req CreateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.uploadService.CreateSession(chatID, userID, isOp, req.FileName, req.FileSize, req.Checksum)
+	if err != nil {
+		h.respondUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Upload session created",
+		"data":    uploadSessionResponse(session),
+	})
+}
+
+// GetUploadSession 查询分片上传进度
+func (h *FileHandler) GetUploadSession(c *gin.Context) {
+	session, ok := h.loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": uploadSessionResponse(session),
+	})
+}
+
+// UploadChunk 上传分片，请求体为原始二进制数据，偏移量通过 ?offset= 指定
+func (h *FileHandler) UploadChunk(c *gin.Context) {
+	session, ok := h.loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	// 限制请求体大小，多读 1 字节用于判断分片是否超限
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, config.AppConfig.Storage.ChunkSize+1)
+
+	session, err = h.uploadService.WriteChunk(session, offset, body)
+	if err != nil {
+		h.respondUploadError(c, err, session)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": uploadSessionResponse(session),
+	})
+}
+
+// CompleteUpload 完成分片上传：组装文件、校验 SHA-256 并创建文件消息
+func (h *FileHandler) CompleteUpload(c *gin.Context) {
+	session, ok := h.loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	// 如果是 Operator，sender_id 为 nil，记录上传的 Operator（会话只能由创建它的 Operator 完成）
+	var senderID *uint
+	var operator *models.MessageOperator
+	if !session.IsOperator {
+		senderID = &session.UserID
+	} else {
+		operator = operatorFromContext(c)
+	}
+
+	alreadyCompleted := session.Status == "completed"
+
+	message, err := h.uploadService.Finalize(session, senderID, operator)
+	if err != nil {
+		h.respondUploadError(c, err, session)
+		return
+	}
+
+	// 重复提交时不再广播
+	if alreadyCompleted {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "File uploaded successfully",
+			"data": gin.H{
+				"message_id": message.ID,
+				"file_url":   message.FileURL,
+				"file_name":  message.FileName,
+				"file_size":  message.FileSize,
+				"file_type":  message.Type,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "File uploaded successfully",
+		"data":    h.publishFileMessage(message, session.UserID, session.IsOperator),
+	})
+}
+
+// uploadAccess 校验用户身份和聊天室权限
+func (h *FileHandler) uploadAccess(c *gin.Context) (uint, bool, uint, bool) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return 0, false, 0, false
+	}
+
+	chatIDStr := c.Param("id")
+	chatID, err := strconv.ParseUint(chatIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return 0, false, 0, false
+	}
+
+	// 检查是否是 Operator
+	isOperator, _ := c.Get("is_operator")
+	isOp, _ := isOperator.(bool)
+
+	// 检查用户是否在聊天室中（Operator 跳过检查）
+	if !isOp {
+		if !h.chatService.IsUserInChat(uint(chatID), userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return 0, false, 0, false
+		}
+	}
+
+	return userID, isOp, uint(chatID), true
+}
+
+// loadUploadSession 加载上传会话，只有创建者本人可以访问
+func (h *FileHandler) loadUploadSession(c *gin.Context) (*models.UploadSession, bool) {
+	userID, isOp, chatID, ok := h.uploadAccess(c)
+	if !ok {
+		return nil, false
+	}
+
+	session, err := h.uploadService.GetSession(c.Param("uploadId"), chatID)
+	if err != nil {
+		h.respondUploadError(c, err)
+		return nil, false
+	}
+
+	if session.UserID != userID || session.IsOperator != isOp {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return session, true
+}
+
+// respondUploadError 将上传错误转换为 HTTP 响应
+func (h *FileHandler) respondUploadError(c *gin.Context, err error, session ...*models.UploadSession) {
+	var maxBytesErr *http.MaxBytesError
+	var quotaErr *services.QuotaExceededError
+
+	switch {
+	case errors.Is(err, services.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload session not found"})
+	case errors.Is(err, services.ErrUploadExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Upload session expired"})
+	case errors.Is(err, services.ErrUploadTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":    "File size exceeds limit",
+			"max_size": config.AppConfig.Storage.MaxUploadSize,
+		})
+	case errors.As(err, &quotaErr):
+		respondQuotaExceeded(c, quotaErr)
+	case errors.Is(err, services.ErrUploadChunkTooLarge), errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":      "Chunk exceeds allowed size",
+			"chunk_size": config.AppConfig.Storage.ChunkSize,
+		})
+	case errors.Is(err, services.ErrUploadInvalidChecksum):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUploadOffsetMismatch), errors.Is(err, services.ErrUploadIncomplete):
+		// 返回当前进度，客户端据此从正确的偏移量继续上传
+		resp := gin.H{"error": err.Error()}
+		if len(session) > 0 && session[0] != nil {
+			resp["received_bytes"] = session[0].ReceivedBytes
+		}
+		c.JSON(http.StatusConflict, resp)
+	case errors.Is(err, services.ErrUploadChecksumMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Checksum mismatch"})
+	default:
+		logrus.WithError(err).Error("Chunked upload failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process upload"})
+	}
+}
+
+// uploadSessionResponse 上传会话响应数据
+func uploadSessionResponse(session *models.UploadSession) gin.H {
+	return gin.H{
+		"upload_id":      session.ID,
+		"chat_id":        session.ChatID,
+		"file_name":      session.FileName,
+		"file_size":      session.FileSize,
+		"received_bytes": session.ReceivedBytes,
+		"chunk_size":     config.AppConfig.Storage.ChunkSize,
+		"status":         session.Status,
+		"message_id":     session.MessageID,
+		"expires_at":     session.ExpiresAt,
+	}
+}
@@ -1,16 +1,21 @@
 package handlers
 
 import (
+	"errors"
 	"kelisim-chat/internal/config"
 	"kelisim-chat/internal/middleware"
+	"kelisim-chat/internal/models"
 	"kelisim-chat/internal/services"
 	"kelisim-chat/internal/websocket"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 // FileHandler 文件处理器
@@ -18,6 +23,8 @@ type FileHandler struct {
 	fileService    *services.FileService
 	messageService *services.MessageService
 	chatService    *services.ChatService
+	uploadService  *services.UploadService
+	quotaService   *services.QuotaService
 	hub            *websocket.Hub
 }
 
@@ -27,6 +34,8 @@ func NewFileHandler(hub *websocket.Hub) *FileHandler {
 		fileService:    services.NewFileService(),
 		messageService: services.NewMessageService(),
 		chatService:    services.NewChatService(),
+		uploadService:  services.NewUploadService(),
+		quotaService:   services.NewQuotaService(),
 		hub:            hub,
 	}
 }
@@ -65,31 +74,37 @@ func (h *FileHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
+	// 检查聊天室和组织的存储配额并预留空间，文件消息创建（计入用量）或失败后释放
+	reservationID, err := h.quotaService.ReserveUpload(uint(chatID), file.Size)
+	if err != nil {
+		var quotaErr *services.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			respondQuotaExceeded(c, quotaErr)
+			return
+		}
+		logrus.WithError(err).Error("Failed to check storage quota")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+		return
+	}
+	defer h.quotaService.ReleaseReservation(reservationID)
+
 	// 上传文件
-	fileURL, fileName, fileSize, err := h.fileService.UploadFile(file, uint(chatID))
+	fileURL, fileName, fileSize, _, err := h.fileService.UploadFile(file)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file"})
 		return
 	}
 
-	// 确定文件类型
-	fileType := h.fileService.GetFileType(fileName)
-
-	// 创建消息记录
-	messageType := "document"
-	if fileType == "image" {
-		messageType = "image"
-	}
+	// 确定文件类型（消息类型与文件类型一致：document/image/video）
+	messageType := h.fileService.GetFileType(fileName)
 
-	// 如果是 Operator，发送系统消息（sender_id = nil）
+	// 如果是 Operator，sender_id 为 nil，记录上传的 Operator
 	var senderID *uint
 	if !isOp {
 		senderID = &userID
-	} else {
-		senderID = nil // Operator 上传的文件是系统文件
 	}
 
-	message, err := h.messageService.SendMessage(uint(chatID), senderID, messageType, nil, &fileURL, &fileName, &fileSize)
+	message, err := h.messageService.SendMessage(uint(chatID), senderID, operatorFromContext(c), messageType, nil, &fileURL, &fileName, &fileSize)
 	if err != nil {
 		// 如果消息创建失败，删除已上传的文件
 		h.fileService.DeleteFile(fileURL)
@@ -97,46 +112,168 @@ func (h *FileHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
-	// 通过 WebSocket 广播文件消息（排除发送者）
-	if h.hub != nil {
-		wsMessage := &websocket.Message{
-			ID:        message.ID,
-			ChatID:    message.ChatID,
-			Type:      message.Type,
-			Content:   message.Content,
-			FileURL:   message.FileURL,
-			FileName:  message.FileName,
-			FileSize:  message.FileSize,
-			CreatedAt: message.CreatedAt.Format("2006-01-02T15:04:05.000Z07:00"),
-			Status:    "sent",
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "File uploaded successfully",
+		"data":    h.publishFileMessage(message, userID, isOp),
+	})
+}
+
+// publishFileMessage 发布文件消息并返回响应数据
+// 尚未确认安全的内容先在后台扫描，扫描通过后才广播给其他参与者；isOperator 表示 userID 是 operator_id
+func (h *FileHandler) publishFileMessage(message *models.Message, userID uint, isOperator bool) gin.H {
+	data := gin.H{
+		"message_id":  message.ID,
+		"file_url":    message.FileURL,
+		"file_name":   message.FileName,
+		"file_size":   message.FileSize,
+		"file_type":   message.Type,
+		"scan_status": services.ScanStatusClean,
+	}
+
+	var chatFile *models.ChatFile
+	if file, err := h.fileService.GetChatFileByMessageID(message.ID); err == nil {
+		chatFile = file
+
+		// 内容哈希，供客户端校验文件完整性
+		data["content_hash"] = chatFile.ContentHash
+		data["scan_status"] = chatFile.ScanStatus
+
+		if chatFile.ScanStatus == services.ScanStatusPending {
+			go h.scanAndPublish(message, chatFile, userID, isOperator)
+			return data
 		}
-		if message.Sender != nil {
-			wsMessage.Sender = &websocket.User{
-				ID:        message.Sender.ID,
-				FirstName: message.Sender.FirstName,
-				LastName:  message.Sender.LastName,
-				FullName:  message.Sender.GetFullName(),
-				Avatar:    message.Sender.Avatar,
-				UserType:  message.Sender.UserType,
-				Status:    message.Sender.Status,
-			}
+	}
+
+	wsMessage := h.broadcastFileMessage(message, chatFile, userID)
+	if wsMessage.Duration != nil || wsMessage.PosterURL != nil {
+		data["duration"] = wsMessage.Duration
+		data["width"] = wsMessage.Width
+		data["height"] = wsMessage.Height
+		data["poster_url"] = wsMessage.PosterURL
+	}
+
+	return data
+}
+
+// broadcastFileMessage 补充视频元数据并通过 WebSocket 广播文件消息（排除发送者）
+func (h *FileHandler) broadcastFileMessage(message *models.Message, chatFile *models.ChatFile, userID uint) *websocket.Message {
+	wsMessage := convertToWebSocketMessage(message)
+	if chatFile != nil {
+		wsMessage.ContentHash = chatFile.ContentHash
+	}
+
+	// 视频：从容器头部读取时长和分辨率，并尝试生成封面；解析失败时仍保存已得到的部分元数据
+	if message.Type == "video" {
+		meta, err := h.fileService.ProbeVideo(*message.FileURL)
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to probe video %s", *message.FileURL)
+		}
+		if err := h.fileService.SaveVideoMetadata(message.ID, meta); err != nil {
+			logrus.WithError(err).Warnf("Failed to save video metadata for message %d", message.ID)
+		} else {
+			wsMessage.Duration = meta.Duration
+			wsMessage.Width = meta.Width
+			wsMessage.Height = meta.Height
+			wsMessage.PosterURL = meta.PosterURL
 		}
-		h.hub.BroadcastToChat(uint(chatID), websocket.ServerMessage{
+	}
+
+	if h.hub != nil {
+		h.hub.BroadcastToChat(message.ChatID, websocket.ServerMessage{
 			Type:    websocket.NewMessage,
 			Message: wsMessage,
 		}, userID)
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "File uploaded successfully",
-		"data": gin.H{
-			"message_id": message.ID,
-			"file_url":   fileURL,
-			"file_name":  fileName,
-			"file_size":  fileSize,
-			"file_type":  fileType,
-		},
-	})
+	return wsMessage
+}
+
+// scanAndPublish 后台扫描文件，通过后广播消息；感染时广播替代的系统消息
+// 上传者始终会收到 file_scan_completed 事件（Operator 上传时发送到 Operator 连接）
+func (h *FileHandler) scanAndPublish(message *models.Message, chatFile *models.ChatFile, userID uint, isOperator bool) {
+	outcome, err := h.fileService.ScanChatFile(chatFile)
+	if err != nil {
+		logrus.WithError(err).Errorf("Failed to scan file for message %d", message.ID)
+		if outcome == nil {
+			outcome = &services.ScanOutcome{Status: services.ScanStatusFailed}
+		}
+	}
+
+	event := websocket.ServerMessage{
+		Type:      websocket.FileScanCompleted,
+		ChatID:    message.ChatID,
+		MessageID: message.ID,
+		Status:    outcome.Status,
+	}
+
+	switch outcome.Status {
+	case services.ScanStatusClean:
+		event.Message = h.broadcastFileMessage(message, chatFile, userID)
+	case services.ScanStatusInfected:
+		logrus.Warnf("Quarantined file %q in chat %d (signature: %s)", chatFile.FileName, message.ChatID, outcome.Signature)
+		if outcome.SystemMessage != nil && h.hub != nil {
+			h.hub.BroadcastToChat(message.ChatID, websocket.ServerMessage{
+				Type:    websocket.NewMessage,
+				Message: convertToWebSocketMessage(outcome.SystemMessage),
+			}, 0)
+		}
+	}
+
+	if h.hub == nil || userID == 0 {
+		return
+	}
+	if isOperator {
+		h.hub.SendToOperator(userID, event)
+	} else {
+		h.hub.SendToUser(userID, event)
+	}
+}
+
+// StartScanRetry 定期重新扫描扫描失败或积压的文件，结果与上传后的扫描一样广播
+// 每次最多处理一批，下一次从上一批之后继续，避免持续失败的文件挡住其他文件
+func (h *FileHandler) StartScanRetry(interval time.Duration) {
+	const batchSize = 100
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var lastID uint
+		for range ticker.C {
+			files, err := h.fileService.GetFilesToRescan(lastID, time.Now().Add(-interval), batchSize)
+			if err != nil {
+				logrus.WithError(err).Error("Failed to load files to rescan")
+				continue
+			}
+			if len(files) < batchSize {
+				lastID = 0
+			} else {
+				lastID = files[len(files)-1].ID
+			}
+
+			for i := range files {
+				chatFile := &files[i]
+				message, err := h.messageService.GetMessageByID(chatFile.MessageID)
+				if err != nil {
+					logrus.WithError(err).Warnf("Failed to load message %d for rescan", chatFile.MessageID)
+					continue
+				}
+
+				// 扫描结果发送给上传的用户或 Operator
+				uploaderID, isOperator := uint(0), false
+				if chatFile.UploadedBy != nil {
+					uploaderID = *chatFile.UploadedBy
+				} else if chatFile.OperatorID != nil {
+					uploaderID, isOperator = *chatFile.OperatorID, true
+				}
+				h.scanAndPublish(message, chatFile, uploaderID, isOperator)
+			}
+
+			if len(files) > 0 {
+				logrus.Infof("Rescanned %d files", len(files))
+			}
+		}
+	}()
 }
 
 // GetChatFiles 获取聊天文件列表
@@ -177,8 +314,21 @@ func (h *FileHandler) GetChatFiles(c *gin.Context) {
 		offset = 0
 	}
 
+	// 按文件类型过滤，例如 ?type=video 或 ?type=image,video
+	var fileTypes []string
+	if typeParam := c.Query("type"); typeParam != "" {
+		for _, t := range strings.Split(typeParam, ",") {
+			t = strings.TrimSpace(t)
+			if t != "image" && t != "document" && t != "video" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type: " + t})
+				return
+			}
+			fileTypes = append(fileTypes, t)
+		}
+	}
+
 	// 获取文件列表
-	files, err := h.fileService.GetChatFiles(uint(chatID), limit, offset)
+	files, err := h.fileService.GetChatFiles(uint(chatID), fileTypes, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get files"})
 		return
@@ -187,7 +337,7 @@ func (h *FileHandler) GetChatFiles(c *gin.Context) {
 	// 转换为前端需要的格式
 	fileList := make([]gin.H, 0, len(files))
 	for _, file := range files {
-		fileList = append(fileList, gin.H{
+		item := gin.H{
 			"id":         file.ID,
 			"file_url":   file.FileURL,
 			"file_name":  file.FileName,
@@ -195,7 +345,17 @@ func (h *FileHandler) GetChatFiles(c *gin.Context) {
 			"type":       file.Type,
 			"created_at": file.CreatedAt,
 			"sender":     file.Sender,
-		})
+		}
+		if len(file.ChatFiles) > 0 {
+			item["content_hash"] = file.ChatFiles[0].ContentHash
+		}
+		if file.Type == "video" && len(file.ChatFiles) > 0 {
+			item["duration"] = file.ChatFiles[0].Duration
+			item["width"] = file.ChatFiles[0].Width
+			item["height"] = file.ChatFiles[0].Height
+			item["poster_url"] = file.ChatFiles[0].PosterURL
+		}
+		fileList = append(fileList, item)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -13,26 +13,32 @@ import (
 
 // MessageHandler 消息处理器
 type MessageHandler struct {
-	messageService      *services.MessageService
-	chatService         *services.ChatService
-	notificationService *services.NotificationService
-	hub                 *websocket.Hub
+	messageService       *services.MessageService
+	chatService          *services.ChatService
+	notificationService  *services.NotificationService
+	aiInteractionService *services.AIInteractionService
+	translationService   *services.TranslationService
+	moderationService    *services.ModerationService
+	hub                  *websocket.Hub
 }
 
 // NewMessageHandler 创建消息处理器
 func NewMessageHandler(hub *websocket.Hub) *MessageHandler {
 	return &MessageHandler{
-		messageService:      services.NewMessageService(),
-		chatService:         services.NewChatService(),
-		notificationService: services.NewNotificationService(),
-		hub:                 hub,
+		messageService:       services.NewMessageService(),
+		chatService:          services.NewChatService(),
+		notificationService:  services.NewNotificationService(),
+		aiInteractionService: services.NewAIInteractionService(),
+		translationService:   services.NewTranslationService(services.NewLLMService()),
+		moderationService:    services.NewModerationService(services.NewLLMService()),
+		hub:                  hub,
 	}
 }
 
 // SendMessageRequest 发送消息请求
 type SendMessageRequest struct {
 	Content string `json:"content"`
-	Type    string `json:"type" binding:"required,oneof=text document image system ai_assistant"`
+	Type    string `json:"type" binding:"required,oneof=text document image video system ai_assistant"`
 }
 
 // GetMessages 获取聊天消息
@@ -74,12 +80,25 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 		offset = 0
 	}
 
-	messages, err := h.messageService.GetChatMessages(uint(chatID), limit, offset)
+	// 查看者自己上传、尚未扫描通过的文件消息也会返回（Operator 按 operator_id 匹配）
+	viewerID, viewerIsOperator := userID, false
+	if operator := operatorFromContext(c); operator != nil {
+		viewerID, viewerIsOperator = operator.ID, true
+	}
+	messages, err := h.messageService.GetChatMessages(uint(chatID), viewerID, viewerIsOperator, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
 		return
 	}
 
+	// 自动翻译的聊天室附加查看者语言已缓存的译文（可用 ?lang= 指定），不等待 LLM
+	// 没有缓存的消息在后台翻译，译文以 message_translated 事件推送给查看者
+	if language := viewerLanguage(c, "lang"); language != "" && h.chatService.IsAutoTranslate(uint(chatID)) {
+		if missing := h.translationService.AttachCachedTranslations(messages, language); len(missing) > 0 {
+			go h.pushTranslations(uint(chatID), missing, language, viewerID, viewerIsOperator)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"messages": messages,
 		"limit":    limit,
@@ -120,26 +139,40 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
+	// system 和 ai_assistant 消息只能由 Operator 发送
+	if !isOp && (req.Type == "system" || req.Type == "ai_assistant") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only operators can send this message type"})
+		return
+	}
+
 	// 验证消息内容
 	if req.Type == "text" && req.Content == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Content is required for text messages"})
 		return
 	}
 
-	// 如果是 Operator，发送系统消息（sender_id = nil）
+	// 如果是 Operator，sender_id 为 nil，记录发送的 Operator
 	var senderID *uint
 	if !isOp {
 		senderID = &userID
-	} else {
-		senderID = nil // Operator 发送的是系统消息
 	}
 
-	message, err := h.messageService.SendMessage(uint(chatID), senderID, req.Type, &req.Content, nil, nil, nil)
+	message, err := h.messageService.SendMessage(uint(chatID), senderID, operatorFromContext(c), req.Type, &req.Content, nil, nil, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
 		return
 	}
 
+	// 根据最新的参与者消息更新聊天语言（异步）
+	if req.Type == "text" && !isOp {
+		go h.chatService.DetectLanguage(uint(chatID))
+	}
+
+	// 风险审核（异步），标记推送到 Operator 的 flags 频道
+	if req.Type == "text" && !isOp && h.moderationService.Enabled() {
+		go h.moderateAndPublish(message)
+	}
+
 	// 获取聊天室的所有参与者
 	participants, err := h.chatService.GetChatParticipants(uint(chatID))
 	if err == nil {
@@ -160,13 +193,7 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 	}
 
 	// 通过 WebSocket 广播新消息（排除发送者）
-	if h.hub != nil {
-		wsMessage := convertToWebSocketMessage(message)
-		h.hub.BroadcastToChat(uint(chatID), websocket.ServerMessage{
-			Type:    websocket.NewMessage,
-			Message: wsMessage,
-		}, userID)
-	}
+	h.broadcastNewMessage(uint(chatID), message, participants, userID)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Message sent successfully",
@@ -215,11 +242,16 @@ func (h *MessageHandler) MarkChatAsRead(c *gin.Context) {
 		return
 	}
 
-	// 检查是否是 Operator（Operator 不需要标记已读，直接返回成功）
+	// Operator 不是参与者，单独记录 Operator 的已读位置
 	isOperator, _ := c.Get("is_operator")
 	if isOp, ok := isOperator.(bool); ok && isOp {
+		operatorID, _ := middleware.GetOperatorIDFromContext(c)
+		if err := h.chatService.MarkReadByOperator(uint(chatID), operatorID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark chat as read"})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Operator does not need to mark as read",
+			"message": "Chat marked as read",
 		})
 		return
 	}
@@ -292,29 +324,59 @@ func (h *MessageHandler) GetUnreadCount(c *gin.Context) {
 // convertToWebSocketMessage 将 models.Message 转换为 websocket.Message
 func convertToWebSocketMessage(msg *models.Message) *websocket.Message {
 	wsMsg := &websocket.Message{
-		ID:        msg.ID,
-		ChatID:    msg.ChatID,
-		Type:      msg.Type,
-		Content:   msg.Content,
-		FileURL:   msg.FileURL,
-		FileName:  msg.FileName,
-		FileSize:  msg.FileSize,
-		CreatedAt: msg.CreatedAt.Format("2006-01-02T15:04:05.000Z07:00"),
-		Status:    "sent",
-	}
-
-	// 转换发送者信息
+		ID:         msg.ID,
+		ChatID:     msg.ChatID,
+		Type:       msg.Type,
+		Content:    msg.Content,
+		FileURL:    msg.FileURL,
+		FileName:   msg.FileName,
+		FileSize:   msg.FileSize,
+		Question:   msg.Question,
+		SenderType: msg.SenderType,
+		CreatedAt:  msg.CreatedAt.Format("2006-01-02T15:04:05.000Z07:00"),
+		Status:     "sent",
+	}
+
+	// 转换发送者和提问者信息
 	if msg.Sender != nil {
-		wsMsg.Sender = &websocket.User{
-			ID:        msg.Sender.ID,
-			FirstName: msg.Sender.FirstName,
-			LastName:  msg.Sender.LastName,
-			FullName:  msg.Sender.GetFullName(),
-			Avatar:    msg.Sender.Avatar,
-			UserType:  msg.Sender.UserType,
-			Status:    msg.Sender.Status,
+		wsMsg.Sender = convertToWebSocketUser(msg.Sender)
+	}
+	if msg.Asker != nil {
+		wsMsg.Asker = convertToWebSocketUser(msg.Asker)
+	}
+	if msg.Operator != nil {
+		wsMsg.Operator = &websocket.Operator{
+			ID:   msg.Operator.ID,
+			Name: msg.Operator.Name,
 		}
 	}
 
 	return wsMsg
 }
+
+// operatorFromContext 当前请求的 Operator，非 Operator 请求返回 nil
+func operatorFromContext(c *gin.Context) *models.MessageOperator {
+	isOperator, _ := c.Get("is_operator")
+	if isOp, ok := isOperator.(bool); !ok || !isOp {
+		return nil
+	}
+	operatorID, ok := middleware.GetOperatorIDFromContext(c)
+	if !ok {
+		return nil
+	}
+	name, _ := middleware.GetOperatorNameFromContext(c)
+	return &models.MessageOperator{ID: operatorID, Name: name}
+}
+
+// convertToWebSocketUser 将 models.User 转换为 websocket.User
+func convertToWebSocketUser(user *models.User) *websocket.User {
+	return &websocket.User{
+		ID:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		FullName:  user.GetFullName(),
+		Avatar:    user.Avatar,
+		UserType:  user.UserType,
+		Status:    user.Status,
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"kelisim-chat/internal/middleware"
+	"kelisim-chat/internal/models"
+	"kelisim-chat/internal/services"
+	"kelisim-chat/internal/websocket"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// moderationTimeout 单条消息审核（含 LLM 分类）的超时时间
+const moderationTimeout = 60 * time.Second
+
+// ModerationHandler 消息风险标记处理器 (Operator 专用)
+type ModerationHandler struct {
+	moderationService *services.ModerationService
+	hub               *websocket.Hub
+}
+
+// NewModerationHandler 创建消息风险标记处理器
+func NewModerationHandler(hub *websocket.Hub) *ModerationHandler {
+	return &ModerationHandler{
+		moderationService: services.NewModerationService(nil),
+		hub:               hub,
+	}
+}
+
+// ListFlags 获取消息风险标记列表
+// 支持 ?status=（默认 open，all 表示全部）、?chat_id=、?category=、?severity= 过滤
+func (h *ModerationHandler) ListFlags(c *gin.Context) {
+	filter := services.FlagFilter{
+		Status:   c.DefaultQuery("status", services.FlagStatusOpen),
+		Category: c.Query("category"),
+		Severity: c.Query("severity"),
+	}
+	if filter.Status == "all" {
+		filter.Status = ""
+	}
+
+	chatID, err := parseOptionalID(c.Query("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+	filter.ChatID = chatID
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	flags, total, err := h.moderationService.ListFlags(filter, limit, offset)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get message flags")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get flags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   flags,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// AcknowledgeFlag 确认标记（Operator 正在处理）
+func (h *ModerationHandler) AcknowledgeFlag(c *gin.Context) {
+	h.reviewFlag(c, services.FlagStatusAcknowledged)
+}
+
+// DismissFlag 驳回标记（误报）
+func (h *ModerationHandler) DismissFlag(c *gin.Context) {
+	h.reviewFlag(c, services.FlagStatusDismissed)
+}
+
+// reviewFlag 更新标记状态，并通知其他订阅了 flags 频道的 Operator
+func (h *ModerationHandler) reviewFlag(c *gin.Context, status string) {
+	operatorID, ok := middleware.GetOperatorIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Operator not authenticated"})
+		return
+	}
+
+	flagID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flag ID"})
+		return
+	}
+
+	flag, err := h.moderationService.ReviewFlag(uint(flagID), operatorID, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFlagNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Flag not found"})
+		case errors.Is(err, services.ErrFlagDismissed):
+			c.JSON(http.StatusConflict, gin.H{"error": "Flag already dismissed"})
+		default:
+			logrus.WithError(err).Errorf("Failed to review flag %d", flagID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update flag"})
+		}
+		return
+	}
+
+	if h.hub != nil {
+		h.hub.Publish(websocket.FlagsChannel, websocket.ServerMessage{
+			Type:      websocket.FlagUpdated,
+			ChatID:    flag.ChatID,
+			MessageID: flag.MessageID,
+			Flag:      convertToWebSocketFlag(flag),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Flag updated",
+		"data":    flag,
+	})
+}
+
+// moderateAndPublish 后台审核参与者发送的消息，新增的标记推送到 flags 频道
+func (h *MessageHandler) moderateAndPublish(message *models.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), moderationTimeout)
+	defer cancel()
+
+	flags, err := h.moderationService.Moderate(ctx, message)
+	if err != nil {
+		logrus.WithError(err).Errorf("Failed to moderate message %d", message.ID)
+	}
+	if len(flags) == 0 || h.hub == nil {
+		return
+	}
+
+	for i := range flags {
+		logrus.WithFields(logrus.Fields{
+			"chat_id":  message.ChatID,
+			"category": flags[i].Category,
+			"severity": flags[i].Severity,
+			"source":   flags[i].Source,
+		}).Warnf("Message %d flagged", message.ID)
+
+		h.hub.Publish(websocket.FlagsChannel, websocket.ServerMessage{
+			Type:      websocket.MessageFlagged,
+			ChatID:    message.ChatID,
+			MessageID: message.ID,
+			Message:   convertToWebSocketMessage(message),
+			Flag:      convertToWebSocketFlag(&flags[i]),
+		})
+	}
+}
+
+// convertToWebSocketFlag 将 models.MessageFlag 转换为 websocket.Flag
+func convertToWebSocketFlag(flag *models.MessageFlag) *websocket.Flag {
+	wsFlag := &websocket.Flag{
+		ID:         flag.ID,
+		MessageID:  flag.MessageID,
+		ChatID:     flag.ChatID,
+		Category:   flag.Category,
+		Severity:   flag.Severity,
+		Source:     flag.Source,
+		Reason:     flag.Reason,
+		Status:     flag.Status,
+		ReviewedBy: flag.ReviewedBy,
+		CreatedAt:  flag.CreatedAt.Format("2006-01-02T15:04:05.000Z07:00"),
+	}
+	if flag.ReviewedAt != nil {
+		reviewedAt := flag.ReviewedAt.Format("2006-01-02T15:04:05.000Z07:00")
+		wsFlag.ReviewedAt = &reviewedAt
+	}
+	return wsFlag
+}
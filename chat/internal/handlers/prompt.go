@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"kelisim-chat/internal/prompts"
+	"kelisim-chat/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// PreviewPromptRequest 提示词预览请求
+type PreviewPromptRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Version  int    `json:"version"`  // 0 表示当前使用的最高版本
+	ChatID   uint   `json:"chat_id"`  // 用该聊天室的标题、参与者和语言渲染，0 表示不针对单个聊天室（如 search_answer）
+	Language string `json:"language"` // 默认使用聊天语言
+}
+
+// renderPrompt 渲染聊天室的提示词模板，call 不为空时记录模板版本
+// 渲染失败时返回错误响应和 false
+func (h *AIAssistantHandler) renderPrompt(c *gin.Context, call *aiCall, name string, chatID uint, language string) (string, bool) {
+	return h.renderPromptData(c, call, name, services.PromptData(chatID, language))
+}
+
+// renderPromptData 用准备好的变量渲染提示词模板，其余同 renderPrompt
+func (h *AIAssistantHandler) renderPromptData(c *gin.Context, call *aiCall, name string, data prompts.Data) (string, bool) {
+	text, templateID, err := services.RenderPromptData(name, 0, data)
+	if err != nil {
+		logrus.WithError(err).Errorf("Failed to render prompt template %s", name)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render prompt template"})
+		return "", false
+	}
+
+	if call != nil {
+		call.setTemplate(templateID)
+	}
+	return text, true
+}
+
+// ListPrompts 列出所有提示词模板及版本 - Operator专用
+func (h *AIAssistantHandler) ListPrompts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"templates": promptList(),
+	})
+}
+
+// PreviewPrompt 用指定聊天室的数据渲染提示词模板 - Operator专用
+func (h *AIAssistantHandler) PreviewPrompt(c *gin.Context) {
+	var req PreviewPromptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := prompts.Default().Get(req.Name, req.Version); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt template not found"})
+		return
+	}
+
+	data := services.PromptData(req.ChatID, req.Language)
+	text, template, err := prompts.Default().Render(req.Name, req.Version, data)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"template": template.ID(),
+		"text":     text,
+		"data":     data,
+	})
+}
+
+// ReloadPrompts 重新加载提示词模板文件 - Operator专用
+// 解析失败的文件会被跳过并在 errors 中返回
+func (h *AIAssistantHandler) ReloadPrompts(c *gin.Context) {
+	resp := gin.H{}
+	if err := prompts.Default().Reload(); err != nil {
+		logrus.WithError(err).Warn("Some prompt templates failed to load")
+		resp["errors"] = err.Error()
+	}
+	resp["templates"] = promptList()
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// promptList 模板列表，active 表示该名称当前使用的版本
+func promptList() []gin.H {
+	store := prompts.Default()
+
+	list := []gin.H{}
+	for _, t := range store.List() {
+		active, _ := store.Get(t.Name, 0)
+		list = append(list, gin.H{
+			"id":      t.ID(),
+			"name":    t.Name,
+			"version": t.Version,
+			"source":  t.Source,
+			"active":  active == t,
+			"text":    t.Text,
+		})
+	}
+	return list
+}
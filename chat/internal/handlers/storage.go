@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"kelisim-chat/internal/middleware"
+	"kelisim-chat/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// StorageHandler 存储用量处理器
+type StorageHandler struct {
+	quotaService *services.QuotaService
+}
+
+// NewStorageHandler 创建存储用量处理器
+func NewStorageHandler() *StorageHandler {
+	return &StorageHandler{
+		quotaService: services.NewQuotaService(),
+	}
+}
+
+// GetStorageUsage 获取当前用户所在组织的存储用量（仅公司管理员）
+func (h *StorageHandler) GetStorageUsage(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if !user.IsCompanyAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only company admins can view storage usage"})
+		return
+	}
+
+	orgIDs, err := h.quotaService.GetUserOrganizationIDs(user.ID)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get user organizations")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage usage"})
+		return
+	}
+
+	organizations := make([]gin.H, 0, len(orgIDs))
+	for _, orgID := range orgIDs {
+		usage, err := h.quotaService.GetOrganizationUsage(orgID)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to get storage usage for organization %d", orgID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage usage"})
+			return
+		}
+
+		chats, err := h.quotaService.GetOrganizationChatUsage(orgID, 20)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to get chat storage usage for organization %d", orgID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage usage"})
+			return
+		}
+
+		organizations = append(organizations, gin.H{
+			"organization_id": orgID,
+			"used_bytes":      usage.UsedBytes,
+			"file_count":      usage.FileCount,
+			"limit_bytes":     usage.LimitBytes,
+			"top_chats":       chats,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organizations": organizations,
+	})
+}
+
+// OperatorGetTopConsumers 获取存储用量最大的组织和聊天室 (Operator 专用)
+func (h *StorageHandler) OperatorGetTopConsumers(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	organizations, err := h.quotaService.GetTopOrganizations(limit)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get top organizations by storage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage usage"})
+		return
+	}
+
+	chats, err := h.quotaService.GetTopChats(limit)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get top chats by storage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organizations": organizations,
+		"chats":         chats,
+		"limit":         limit,
+	})
+}
+
+// respondQuotaExceeded 返回结构化的配额超限错误
+func respondQuotaExceeded(c *gin.Context, err *services.QuotaExceededError) {
+	resp := gin.H{
+		"error":           "Storage quota exceeded",
+		"code":            "storage_quota_exceeded",
+		"scope":           err.Scope,
+		"limit_bytes":     err.Limit,
+		"used_bytes":      err.Used,
+		"requested_bytes": err.Requested,
+	}
+	if err.Scope == "organization" {
+		resp["organization_id"] = err.ScopeID
+	} else {
+		resp["chat_id"] = err.ScopeID
+	}
+
+	c.JSON(http.StatusRequestEntityTooLarge, resp)
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"kelisim-chat/internal/middleware"
+	"kelisim-chat/internal/models"
+	"kelisim-chat/internal/services"
+	"kelisim-chat/internal/websocket"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// translationTimeout 后台自动翻译的最长时间，超时的消息不补发译文
+const translationTimeout = 30 * time.Second
+
+// TranslateMessage 将消息翻译为指定语言
+// target 默认为当前用户的语言，译文会被缓存
+func (h *MessageHandler) TranslateMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	messageIDStr := c.Param("id")
+	messageID, err := strconv.ParseUint(messageIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	target := viewerLanguage(c, "target")
+	if target == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported target language"})
+		return
+	}
+
+	message, err := h.messageService.GetMessageByID(uint(messageID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	// 检查用户是否在聊天室中（Operator 跳过检查）
+	isOperator, _ := c.Get("is_operator")
+	if isOp, ok := isOperator.(bool); !ok || !isOp {
+		if !h.chatService.IsUserInChat(message.ChatID, userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+	}
+
+	translation, err := h.translationService.TranslateMessage(c.Request.Context(), message, target)
+	if err != nil {
+		if errors.Is(err, services.ErrNothingToTranslate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Message has no text to translate"})
+			return
+		}
+		logrus.WithError(err).Error("Failed to translate message")
+		respondAIError(c, err, "Failed to translate message")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"translation": translation,
+	})
+}
+
+// viewerLanguage 查看者的语言：优先使用查询参数 param，其次是用户的 locale
+// Operator 没有 locale，只能通过查询参数指定；不支持的语言返回空字符串
+func viewerLanguage(c *gin.Context, param string) string {
+	if language := c.Query(param); language != "" {
+		return services.NormalizeLanguage(language)
+	}
+	if user, ok := middleware.GetUserFromContext(c); ok {
+		return services.NormalizeLanguage(user.Locale)
+	}
+	return ""
+}
+
+// broadcastNewMessage 通过 WebSocket 广播新消息（排除发送者），不等待翻译
+// 自动翻译的聊天室按接收者的语言分组：已缓存译文的语言直接在 new_message 中附带译文，
+// 其余语言并行翻译，译文以 message_translated 事件补发
+func (h *MessageHandler) broadcastNewMessage(chatID uint, message *models.Message, participants []models.ChatParticipant, excludeUserID uint) {
+	if h.hub == nil {
+		return
+	}
+
+	if len(participants) == 0 || message.Type == "system" || message.Content == nil || !h.chatService.IsAutoTranslate(chatID) {
+		h.hub.BroadcastToChat(chatID, websocket.ServerMessage{
+			Type:    websocket.NewMessage,
+			Message: convertToWebSocketMessage(message),
+		}, excludeUserID)
+		return
+	}
+
+	recipients := make(map[string][]uint)
+	for _, p := range participants {
+		if p.UserID == excludeUserID {
+			continue
+		}
+		language := services.NormalizeLanguage(p.User.Locale)
+		if language == "" {
+			continue
+		}
+		recipients[language] = append(recipients[language], p.UserID)
+	}
+
+	languages := make([]string, 0, len(recipients))
+	for language := range recipients {
+		languages = append(languages, language)
+	}
+	cached, err := h.translationService.GetCachedTranslations(message.ID, languages)
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to load cached translations for message %d", message.ID)
+	}
+
+	// 有缓存译文的接收者单独发送附带译文的 new_message，其余连接收到原文
+	withTranslation := make(map[uint]bool)
+	for language, translation := range cached {
+		if translation.SourceLanguage == language {
+			continue
+		}
+		wsMessage := convertToWebSocketMessage(message)
+		wsMessage.Translation = &websocket.Translation{
+			Language:       translation.Language,
+			SourceLanguage: translation.SourceLanguage,
+			Content:        translation.Content,
+		}
+		for _, userID := range recipients[language] {
+			withTranslation[userID] = true
+			h.hub.SendToUser(userID, websocket.ServerMessage{
+				Type:    websocket.NewMessage,
+				Message: wsMessage,
+			})
+		}
+	}
+
+	h.hub.BroadcastToChatExcept(chatID, websocket.ServerMessage{
+		Type:    websocket.NewMessage,
+		Message: convertToWebSocketMessage(message),
+	}, excludeUserID, withTranslation)
+
+	// 没有缓存的语言需要调用 LLM，每种语言单独异步翻译，一种语言慢不影响其他语言
+	for language, userIDs := range recipients {
+		if _, ok := cached[language]; ok {
+			continue
+		}
+		go func(language string, userIDs []uint) {
+			ctx, cancel := context.WithTimeout(context.Background(), translationTimeout)
+			defer cancel()
+
+			translation, err := h.translationService.TranslateMessage(ctx, message, language)
+			if err != nil {
+				logrus.WithError(err).Warnf("Failed to translate message %d", message.ID)
+				return
+			}
+			if translation.SourceLanguage == language {
+				return
+			}
+
+			for _, userID := range userIDs {
+				h.hub.SendToUser(userID, websocket.ServerMessage{
+					Type:      websocket.MessageTranslated,
+					ChatID:    chatID,
+					MessageID: message.ID,
+					Translation: &websocket.Translation{
+						Language:       translation.Language,
+						SourceLanguage: translation.SourceLanguage,
+						Content:        translation.Content,
+					},
+				})
+			}
+		}(language, userIDs)
+	}
+}
+
+// pushTranslations 后台翻译消息，每条译文以 message_translated 事件推送给查看者（Operator 时 viewerID 为 operator_id）
+func (h *MessageHandler) pushTranslations(chatID uint, messages []models.Message, language string, viewerID uint, viewerIsOperator bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), translationTimeout)
+	defer cancel()
+
+	h.translationService.TranslateMessages(ctx, messages, language, func(message *models.Message, translation *models.MessageTranslation) {
+		if h.hub == nil || translation.SourceLanguage == language {
+			return
+		}
+
+		event := websocket.ServerMessage{
+			Type:      websocket.MessageTranslated,
+			ChatID:    chatID,
+			MessageID: message.ID,
+			Translation: &websocket.Translation{
+				Language:       translation.Language,
+				SourceLanguage: translation.SourceLanguage,
+				Content:        translation.Content,
+			},
+		}
+		if viewerIsOperator {
+			h.hub.SendToOperator(viewerID, event)
+		} else {
+			h.hub.SendToUser(viewerID, event)
+		}
+	})
+}
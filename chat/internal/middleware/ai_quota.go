@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"kelisim-chat/internal/config"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AIDailyQuotaMiddleware 参与者 AI 助手的每日配额中间件
+// 调用 LLM 之前在 ai_quota_usages 的当天计数上原子地预留一次（按服务器时区的自然日），超出 AI_DAILY_QUOTA 返回 429；
+// 请求没有得到回答（非 2xx 响应）时退还。配额信息通过 X-AI-Quota-Limit 和 X-AI-Quota-Remaining 响应头返回
+func AIDailyQuotaMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		quota := config.AppConfig.LLM.AIDailyQuota
+		userID, exists := GetUserIDFromContext(c)
+		if quota <= 0 || !exists {
+			// 不限配额，或未认证用户（由认证中间件处理）
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		resetAt := dayStart.AddDate(0, 0, 1)
+		day := dayStart.Format("2006-01-02")
+
+		reserved, used, err := reserveAIQuota(userID, day, quota)
+		if err != nil {
+			// 预留失败时不阻止提问，分钟级限流仍然有效
+			logrus.WithError(err).Errorf("Failed to reserve AI quota for user %d", userID)
+			c.Next()
+			return
+		}
+
+		remaining := int64(quota) - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Header("X-AI-Quota-Limit", strconv.Itoa(quota))
+		c.Header("X-AI-Quota-Remaining", strconv.FormatInt(remaining, 10))
+
+		if !reserved {
+			c.Header("Retry-After", strconv.Itoa(int(resetAt.Sub(now).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":    "Daily AI assistant quota exceeded",
+				"code":     "ai_quota_exceeded",
+				"limit":    quota,
+				"used":     used,
+				"reset_at": resetAt,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		// 参数错误、无权限或 LLM 调用失败的提问不计入配额
+		if c.Writer.Status() >= http.StatusMultipleChoices {
+			if err := database.DB.Model(&models.AIQuotaUsage{}).
+				Where("user_id = ? AND day = ? AND used > 0", userID, day).
+				Update("used", gorm.Expr("used - 1")).Error; err != nil {
+				logrus.WithError(err).Errorf("Failed to release AI quota for user %d", userID)
+			}
+		}
+	}
+}
+
+// reserveAIQuota 在用户当天的计数上预留一次，返回是否预留成功和预留后的已用次数
+// 条件更新 used < quota 由数据库保证原子性，并发请求最多只有 quota 个成功
+func reserveAIQuota(userID uint, day string, quota int) (bool, int64, error) {
+	usage := models.AIQuotaUsage{UserID: userID, Day: day}
+	if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&usage).Error; err != nil {
+		return false, 0, err
+	}
+
+	result := database.DB.Model(&models.AIQuotaUsage{}).
+		Where("user_id = ? AND day = ? AND used < ?", userID, day, quota).
+		Update("used", gorm.Expr("used + 1"))
+	if result.Error != nil {
+		return false, 0, result.Error
+	}
+
+	var used int64
+	if err := database.DB.Model(&models.AIQuotaUsage{}).
+		Select("used").
+		Where("user_id = ? AND day = ?", userID, day).
+		Row().Scan(&used); err != nil {
+		return false, 0, err
+	}
+	return result.RowsAffected == 1, used, nil
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"kelisim-chat/internal/config"
 	"net/http"
 	"sync"
 	"time"
@@ -108,8 +109,12 @@ func getUserKey(userID uint) string {
 }
 
 // AIRateLimitMiddleware AI功能专用的限流中间件
-// 限制更严格，防止滥用
+// 限制更严格，防止滥用；每分钟请求数由 AI_RATE_LIMIT 配置，默认20次
 func AIRateLimitMiddleware() gin.HandlerFunc {
-	limiter := NewRateLimiter(20, time.Minute) // 每分钟20次请求
+	limit := config.AppConfig.LLM.AIRateLimit
+	if limit <= 0 {
+		limit = 20
+	}
+	limiter := NewRateLimiter(limit, time.Minute)
 	return limiter.Middleware()
 }
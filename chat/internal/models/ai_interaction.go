@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// AIInteraction Operator 的 AI 调用记录，用于历史查询和用量统计
+type AIInteraction struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	OperatorID       uint      `gorm:"not null;index:idx_ai_interactions_operator_created" json:"operator_id"`
+	ChatID           uint      `gorm:"not null;index" json:"chat_id"`
+	Endpoint         string    `gorm:"type:varchar(50);not null" json:"endpoint"`          // ask、summarize、analyze_files 等
+	PromptHash       string    `gorm:"type:varchar(64);not null;index" json:"prompt_hash"` // 请求内容的 SHA-256
+	PromptTemplate   string    `gorm:"type:varchar(100)" json:"prompt_template"`           // 使用的提示词模板版本，例如 operator_ask@v1
+	Answer           *string   `gorm:"type:mediumtext" json:"answer,omitempty"`            // 失败时为空
+	Provider         string    `gorm:"type:varchar(50)" json:"provider"`                   // 实际回答的提供方
+	Model            string    `gorm:"type:varchar(100)" json:"model"`
+	PromptTokens     int       `gorm:"default:0" json:"prompt_tokens"`
+	CompletionTokens int       `gorm:"default:0" json:"completion_tokens"`
+	LatencyMs        int64     `gorm:"default:0" json:"latency_ms"`
+	Status           string    `gorm:"type:enum('success','error');default:'success'" json:"status"`
+	Error            *string   `gorm:"type:varchar(500)" json:"error,omitempty"`
+	CreatedAt        time.Time `gorm:"index:idx_ai_interactions_operator_created;index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (AIInteraction) TableName() string {
+	return "ai_interactions"
+}
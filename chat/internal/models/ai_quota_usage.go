@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// AIQuotaUsage 参与者每天向 AI 助手提问的次数
+// 提问前用条件更新原子地加一预留配额，并发请求不会超出每日配额
+type AIQuotaUsage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_ai_quota_usages_user_day" json:"user_id"`
+	Day       string    `gorm:"type:date;not null;uniqueIndex:idx_ai_quota_usages_user_day" json:"day"` // 服务器时区的自然日（YYYY-MM-DD）
+	Used      int       `gorm:"not null;default:0" json:"used"`                                         // 已预留的次数（未得到回答的提问会退还）
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (AIQuotaUsage) TableName() string {
+	return "ai_quota_usages"
+}
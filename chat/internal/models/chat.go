@@ -8,13 +8,16 @@ import (
 
 // Chat 聊天室模型
 type Chat struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Title     string         `gorm:"type:varchar(255);not null" json:"title"`
-	Type      string         `gorm:"type:enum('private','group');default:'group'" json:"type"`
-	CreatedBy uint           `gorm:"not null" json:"created_by"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	Title         string         `gorm:"type:varchar(255);not null" json:"title"`
+	Type          string         `gorm:"type:enum('private','group');default:'group'" json:"type"`
+	CreatedBy     uint           `gorm:"not null" json:"created_by"`
+	AutoTranslate bool           `gorm:"default:false" json:"auto_translate"` // 自动把消息翻译为查看者的语言
+	Language      string         `gorm:"type:varchar(10)" json:"language"`    // 根据最近消息检测的聊天语言（ru/kk/en/zh），未检测时为空
+	AIEnabled     bool           `gorm:"default:false" json:"ai_enabled"`     // 参与者可以向 AI 助手提问（由公司管理员开启）
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// 关联关系
 	Creator      User              `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
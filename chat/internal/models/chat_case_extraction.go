@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// ChatCaseExtraction 从聊天记录中提取的案件信息，每个聊天室保留最新一份
+type ChatCaseExtraction struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ChatID        uint      `gorm:"not null;uniqueIndex" json:"chat_id"`
+	IssueCategory string    `gorm:"type:varchar(50);not null;index" json:"issue_category"` // 法律问题分类，便于筛选
+	Data          string    `gorm:"type:mediumtext;not null" json:"-"`                     // 提取结果 JSON
+	LastMessageID uint      `gorm:"not null" json:"last_message_id"`                       // 提取时的最后一条消息
+	MessageCount  int       `gorm:"not null;default:0" json:"message_count"`
+	OperatorID    uint      `gorm:"not null" json:"operator_id"`     // 最后一次刷新的 Operator
+	InteractionID uint      `gorm:"default:0" json:"interaction_id"` // 对应的 ai_interactions 记录
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ChatCaseExtraction) TableName() string {
+	return "chat_case_extractions"
+}
@@ -6,20 +6,27 @@ import (
 
 // ChatFile 聊天文件模型
 type ChatFile struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	ChatID     uint      `gorm:"not null" json:"chat_id"`
-	MessageID  uint      `gorm:"not null" json:"message_id"`
-	FileType   string    `gorm:"type:enum('document','image','video');not null" json:"file_type"`
-	FileURL    string    `gorm:"type:varchar(500);not null" json:"file_url"`
-	FileName   string    `gorm:"type:varchar(255);not null" json:"file_name"`
-	FileSize   int64     `gorm:"type:bigint;not null" json:"file_size"`
-	UploadedBy uint      `gorm:"not null" json:"uploaded_by"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ChatID      uint      `gorm:"not null" json:"chat_id"`
+	MessageID   uint      `gorm:"not null" json:"message_id"`
+	FileType    string    `gorm:"type:enum('document','image','video');not null" json:"file_type"`
+	FileURL     string    `gorm:"type:varchar(500);not null" json:"file_url"`
+	FileName    string    `gorm:"type:varchar(255);not null" json:"file_name"`
+	FileSize    int64     `gorm:"type:bigint;not null" json:"file_size"`
+	ContentHash *string   `gorm:"type:char(64);index" json:"content_hash,omitempty"`                                                   // SHA-256，对应 file_blobs
+	Duration    *float64  `gorm:"type:decimal(10,3)" json:"duration,omitempty"`                                                        // 视频时长(秒)
+	Width       *int      `json:"width,omitempty"`                                                                                     // 视频宽度(像素)
+	Height      *int      `json:"height,omitempty"`                                                                                    // 视频高度(像素)
+	PosterURL   *string   `gorm:"type:varchar(500)" json:"poster_url,omitempty"`                                                       // 视频封面URL
+	ScanStatus  string    `gorm:"type:enum('pending_scan','clean','infected','scan_failed');default:'clean';index" json:"scan_status"` // 病毒扫描状态，只有 clean 对其他人可见
+	UploadedBy  *uint     `gorm:"index" json:"uploaded_by,omitempty"`                                                                  // Operator 上传时为空
+	OperatorID  *uint     `json:"operator_id,omitempty"`                                                                               // 上传的 Operator (admin_users.id)
+	CreatedAt   time.Time `json:"created_at"`
 
 	// 关联关系
 	Chat     Chat    `gorm:"foreignKey:ChatID" json:"chat,omitempty"`
 	Message  Message `gorm:"foreignKey:MessageID" json:"message,omitempty"`
-	Uploader User    `gorm:"foreignKey:UploadedBy" json:"uploader,omitempty"`
+	Uploader *User   `gorm:"foreignKey:UploadedBy" json:"uploader,omitempty"`
 }
 
 // TableName 指定表名
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// ChatSummary 聊天室的滚动总结，每个聊天室每种语言保留最新一份
+// 新消息到来后只需把之前的总结和新消息交给模型，不必重新总结全部消息
+type ChatSummary struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ChatID        uint      `gorm:"not null;uniqueIndex:idx_chat_summaries_chat_language" json:"chat_id"`
+	Language      string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_chat_summaries_chat_language" json:"language"`
+	Summary       string    `gorm:"type:mediumtext;not null" json:"summary"`
+	LastMessageID uint      `gorm:"not null;default:0" json:"last_message_id"` // 总结覆盖到的最后一条消息
+	MessageCount  int       `gorm:"not null;default:0" json:"message_count"`   // 总结覆盖的消息数
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `gorm:"index" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ChatSummary) TableName() string {
+	return "chat_summaries"
+}
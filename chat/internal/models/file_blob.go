@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// FileBlob 按内容寻址存储的文件实体（相同内容只保存一份）
+type FileBlob struct {
+	ContentHash string    `gorm:"type:char(64);primaryKey" json:"content_hash"` // SHA-256（十六进制）
+	FilePath    string    `gorm:"type:varchar(500);not null" json:"-"`          // 相对存储目录的路径
+	FileURL     string    `gorm:"type:varchar(500);not null;uniqueIndex" json:"file_url"`
+	FileSize    int64     `gorm:"type:bigint;not null" json:"file_size"`
+	RefCount    int       `gorm:"not null;default:0" json:"ref_count"`                                                    // 引用该内容的文件记录数
+	ScanStatus  string    `gorm:"type:enum('pending_scan','clean','infected');default:'pending_scan'" json:"scan_status"` // 内容的扫描结论，再次上传时复用
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (FileBlob) TableName() string {
+	return "file_blobs"
+}
@@ -8,23 +8,87 @@ import (
 
 // Message 消息模型
 type Message struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	ChatID    uint           `gorm:"not null" json:"chat_id"`
-	SenderID  *uint          `gorm:"index" json:"sender_id,omitempty"`
-	Type      string         `gorm:"type:enum('text','document','image','system','ai_assistant');default:'text'" json:"type"`
-	Content   *string        `gorm:"type:text" json:"content,omitempty"`
-	FileURL   *string        `gorm:"type:varchar(500)" json:"file_url,omitempty"`
-	FileName  *string        `gorm:"type:varchar(255)" json:"file_name,omitempty"`
-	FileSize  *int64         `gorm:"type:bigint" json:"file_size,omitempty"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	ChatID       uint           `gorm:"not null;index:idx_messages_chat_operator" json:"chat_id"`
+	SenderID     *uint          `gorm:"index" json:"sender_id,omitempty"`
+	OperatorID   *uint          `gorm:"index;index:idx_messages_chat_operator" json:"operator_id,omitempty"` // Operator 发送、上传或发布的消息：admin_users.id
+	OperatorName *string        `gorm:"type:varchar(255)" json:"-"`                                          // 发送时的 Operator 名称
+	Type         string         `gorm:"type:enum('text','document','image','video','system','ai_assistant');default:'text'" json:"type"`
+	Content      *string        `gorm:"type:text" json:"content,omitempty"`
+	FileURL      *string        `gorm:"type:varchar(500)" json:"file_url,omitempty"`
+	FileName     *string        `gorm:"type:varchar(255)" json:"file_name,omitempty"`
+	FileSize     *int64         `gorm:"type:bigint" json:"file_size,omitempty"`
+	AskedBy      *uint          `gorm:"index:idx_messages_asked_by_created" json:"asked_by,omitempty"` // ai_assistant 消息：提问的参与者
+	Question     *string        `gorm:"type:text" json:"question,omitempty"`                           // ai_assistant 消息：参与者的问题
+	CreatedAt    time.Time      `gorm:"index:idx_messages_asked_by_created" json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// 关联关系
 	Chat      Chat            `gorm:"foreignKey:ChatID" json:"chat,omitempty"`
 	Sender    *User           `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
+	Asker     *User           `gorm:"foreignKey:AskedBy" json:"asker,omitempty"`
 	Statuses  []MessageStatus `gorm:"foreignKey:MessageID" json:"statuses,omitempty"`
 	ChatFiles []ChatFile      `gorm:"foreignKey:MessageID" json:"chat_files,omitempty"`
+	Flags     []MessageFlag   `gorm:"foreignKey:MessageID" json:"flags,omitempty"`
+
+	// 发送者类型（user、operator、ai_assistant、system）和 Operator 信息，由查询结果填充
+	SenderType string           `gorm:"-" json:"sender_type"`
+	Operator   *MessageOperator `gorm:"-" json:"operator,omitempty"`
+
+	// 文件消息的病毒扫描状态，上传者查看自己尚未扫描通过的文件时用于显示进度（不存储在 messages 表）
+	ScanStatus string `gorm:"-" json:"scan_status,omitempty"`
+
+	// 自动翻译聊天室中查看者语言的译文（不存储在 messages 表）
+	Translation *MessageTranslation `gorm:"-" json:"translation,omitempty"`
+}
+
+// MessageOperator 发送或发布消息的 Operator（admin_users），名称取自发送时的登录信息
+type MessageOperator struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// 消息的发送者类型
+const (
+	SenderTypeUser        = "user"
+	SenderTypeOperator    = "operator"
+	SenderTypeAIAssistant = "ai_assistant"
+	SenderTypeSystem      = "system"
+)
+
+// AfterFind 查询后填充发送者类型和 Operator 信息
+func (m *Message) AfterFind(tx *gorm.DB) error {
+	m.fillSender()
+	return nil
+}
+
+// AfterCreate 创建后填充发送者类型和 Operator 信息
+func (m *Message) AfterCreate(tx *gorm.DB) error {
+	m.fillSender()
+	return nil
+}
+
+// fillSender 根据 sender_id、operator_id 和消息类型填充 SenderType 和 Operator
+// 有 sender_id 的消息总是参与者发送的；Operator 发布的 AI 回答仍然是 ai_assistant，Operator 表示发布者
+func (m *Message) fillSender() {
+	if m.OperatorID != nil {
+		m.Operator = &MessageOperator{ID: *m.OperatorID}
+		if m.OperatorName != nil {
+			m.Operator.Name = *m.OperatorName
+		}
+	}
+
+	switch {
+	case m.SenderID != nil:
+		m.SenderType = SenderTypeUser
+	case m.Type == "ai_assistant":
+		m.SenderType = SenderTypeAIAssistant
+	case m.OperatorID != nil:
+		m.SenderType = SenderTypeOperator
+	default:
+		m.SenderType = SenderTypeSystem
+	}
 }
 
 // TableName 指定表名
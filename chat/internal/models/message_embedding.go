@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// MessageEmbedding 消息文本或文件文本片段的向量，用于跨聊天检索
+// 每条消息（文件按片段）每个模型一条，更换模型后按新模型重新索引
+type MessageEmbedding struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	ChatID           uint      `gorm:"not null;index" json:"chat_id"`
+	MessageID        uint      `gorm:"not null;uniqueIndex:idx_message_embeddings_unique" json:"message_id"`
+	FileID           *uint     `json:"file_id,omitempty"`                                                                 // 来源为文件时的 chat_files.id
+	Source           string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_message_embeddings_unique" json:"source"` // message 或 file
+	ChunkIndex       int       `gorm:"not null;default:0;uniqueIndex:idx_message_embeddings_unique" json:"chunk_index"`
+	Model            string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_message_embeddings_unique;index:idx_message_embeddings_model_created" json:"model"`
+	Content          string    `gorm:"type:text;not null" json:"content"`    // 被索引的文本，检索结果中作为片段返回
+	Vector           []byte    `gorm:"type:mediumblob" json:"-"`             // float32 小端序，单位长度；无法提取文本的文件为空
+	Dimensions       int       `gorm:"not null;default:0" json:"dimensions"` // 0 表示没有可索引的文本
+	MessageCreatedAt time.Time `gorm:"index:idx_message_embeddings_model_created" json:"message_created_at"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (MessageEmbedding) TableName() string {
+	return "message_embeddings"
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// MessageFlag 消息风险标记，由审核流程在消息发送后异步生成，Operator 确认或驳回
+type MessageFlag struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	MessageID  uint       `gorm:"not null;uniqueIndex:idx_message_flags_message_category" json:"message_id"`
+	ChatID     uint       `gorm:"not null;index" json:"chat_id"`
+	Category   string     `gorm:"type:varchar(20);not null;uniqueIndex:idx_message_flags_message_category" json:"category"` // self_harm、illegal、abuse
+	Severity   string     `gorm:"type:varchar(10);not null" json:"severity"`                                                // low、medium、high
+	Source     string     `gorm:"type:varchar(20);not null" json:"source"`                                                  // rules、llm 或 rules,llm
+	Reason     string     `gorm:"type:varchar(500);not null;default:''" json:"reason"`
+	Status     string     `gorm:"type:varchar(20);not null;default:'open';index" json:"status"` // open、acknowledged、dismissed
+	ReviewedBy *uint      `json:"reviewed_by,omitempty"`                                        // 处理的 Operator ID
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	// 关联关系
+	Message *Message `gorm:"foreignKey:MessageID" json:"message,omitempty"`
+}
+
+// TableName 指定表名
+func (MessageFlag) TableName() string {
+	return "message_flags"
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// MessageTranslation 消息译文缓存，每条消息每种语言一份
+type MessageTranslation struct {
+	ID             uint      `gorm:"primaryKey" json:"-"`
+	MessageID      uint      `gorm:"not null;uniqueIndex:idx_message_translations_message_language" json:"message_id"`
+	Language       string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_message_translations_message_language" json:"language"`
+	SourceLanguage string    `gorm:"type:varchar(10)" json:"source_language"` // 检测到的原文语言
+	Content        string    `gorm:"type:text;not null" json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (MessageTranslation) TableName() string {
+	return "message_translations"
+}
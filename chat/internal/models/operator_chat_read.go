@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// OperatorChatRead Operator 在聊天室中已读到的位置，用于统计 Operator 的未读消息
+// Operator 不是聊天室参与者，不能使用 chat_participants.last_read_at
+type OperatorChatRead struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	OperatorID        uint      `gorm:"not null;uniqueIndex:idx_operator_chat_reads_operator_chat" json:"operator_id"` // admin_users.id
+	ChatID            uint      `gorm:"not null;uniqueIndex:idx_operator_chat_reads_operator_chat;index" json:"chat_id"`
+	LastReadMessageID uint      `gorm:"not null;default:0" json:"last_read_message_id"` // 已读到的最后一条消息ID
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (OperatorChatRead) TableName() string {
+	return "operator_chat_reads"
+}
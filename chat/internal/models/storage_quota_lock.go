@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// StorageQuotaLock 每个配额范围（聊天室或组织）一行，检查配额和预留空间时用 SELECT ... FOR UPDATE 锁定
+// 同一范围内的并发上传因此依次检查，多个实例之间也不会一起超出配额
+type StorageQuotaLock struct {
+	Scope     string    `gorm:"type:enum('chat','organization');primaryKey" json:"scope"`
+	ScopeID   uint      `gorm:"primaryKey;autoIncrement:false" json:"scope_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (StorageQuotaLock) TableName() string {
+	return "storage_quota_locks"
+}
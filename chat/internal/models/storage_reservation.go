@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// StorageReservation 上传中的文件预留的存储配额
+// 文件写入并创建消息后由 chat_files 计入用量，预留随即释放；进程异常退出时按 expires_at 失效
+type StorageReservation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ChatID    uint      `gorm:"not null;index" json:"chat_id"`
+	Size      int64     `gorm:"type:bigint;not null" json:"size"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (StorageReservation) TableName() string {
+	return "storage_reservations"
+}
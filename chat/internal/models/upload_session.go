@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// UploadSession 分片上传会话
+type UploadSession struct {
+	ID            string    `gorm:"type:varchar(64);primaryKey" json:"upload_id"`
+	ChatID        uint      `gorm:"not null;index" json:"chat_id"`
+	UserID        uint      `gorm:"not null" json:"user_id"`                     // 上传者ID（Operator 时为 operator_id）
+	IsOperator    bool      `gorm:"default:false" json:"is_operator"`            // 是否由 Operator 上传
+	FileName      string    `gorm:"type:varchar(255);not null" json:"file_name"` // 原始文件名
+	FileSize      int64     `gorm:"type:bigint;not null" json:"file_size"`       // 文件总大小
+	ReceivedBytes int64     `gorm:"type:bigint;default:0" json:"received_bytes"` // 已接收字节数
+	Checksum      string    `gorm:"type:varchar(64);not null" json:"checksum"`   // SHA-256（十六进制）
+	Status        string    `gorm:"type:enum('uploading','completed');default:'uploading'" json:"status"`
+	MessageID     *uint     `json:"message_id,omitempty"` // 完成后创建的消息ID
+	ExpiresAt     time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (UploadSession) TableName() string {
+	return "upload_sessions"
+}
+
+// IsExpired 检查会话是否已过期
+func (u *UploadSession) IsExpired() bool {
+	return time.Now().After(u.ExpiresAt)
+}
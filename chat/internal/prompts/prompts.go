@@ -0,0 +1,212 @@
+package prompts
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// 内置模板，PROMPTS_PATH 目录中的同名同版本文件会覆盖它们
+//
+//go:embed templates/*.tmpl
+var builtinFS embed.FS
+
+// fileNamePattern 模板文件名：<name>.v<version>.tmpl
+var fileNamePattern = regexp.MustCompile(`^([a-z0-9_]+)\.v([0-9]+)\.tmpl$`)
+
+// Participant 模板中的聊天参与者
+type Participant struct {
+	Name     string
+	Role     string // 在聊天室中的角色
+	UserType string // company_admin、expert、lawyer
+}
+
+// Data 模板变量
+type Data struct {
+	ChatID       uint
+	ChatTitle    string
+	Participants []Participant
+	Language     string // ru/kk/en/zh
+	LanguageName string // Russian、Kazakh 等
+	Count        int    // 需要生成的条数（回复建议），0 表示未指定
+}
+
+// Template 一个版本的提示词模板
+type Template struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	Source  string `json:"source"` // builtin 或文件路径
+	Text    string `json:"text"`
+
+	tmpl *template.Template
+}
+
+// ID 模板版本标识，例如 operator_ask@v2，记录在 ai_interactions.prompt_template
+func (t *Template) ID() string {
+	return fmt.Sprintf("%s@v%d", t.Name, t.Version)
+}
+
+// Store 提示词模板集合，每个名称可以有多个版本，默认使用最高版本
+type Store struct {
+	mu        sync.RWMutex
+	dir       string
+	templates map[string][]*Template // 按版本升序
+}
+
+// defaultStore 全局模板集合，Init 之前只有内置模板
+var defaultStore = NewStore("")
+
+// Init 从目录加载模板（内置模板 + 目录中的文件），目录为空时只使用内置模板
+// 解析失败的文件会被跳过，返回的错误列出这些文件
+func Init(dir string) error {
+	store := &Store{dir: dir}
+	err := store.Reload()
+	defaultStore = store
+	return err
+}
+
+// Default 返回全局模板集合
+func Default() *Store {
+	return defaultStore
+}
+
+// NewStore 创建模板集合并加载模板，加载错误可通过 Reload 获取
+func NewStore(dir string) *Store {
+	store := &Store{dir: dir}
+	store.Reload()
+	return store
+}
+
+// Reload 重新加载内置模板和目录中的模板，修改模板文件后无需重新部署
+func (s *Store) Reload() error {
+	templates := make(map[string][]*Template)
+	var errs []error
+
+	builtin, _ := fs.Glob(builtinFS, "templates/*.tmpl")
+	for _, path := range builtin {
+		data, err := builtinFS.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := addTemplate(templates, filepath.Base(path), "builtin", string(data)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if s.dir != "" {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !fileNamePattern.MatchString(entry.Name()) {
+				continue
+			}
+			path := filepath.Join(s.dir, entry.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := addTemplate(templates, entry.Name(), path, string(data)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	for _, versions := range templates {
+		sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	}
+
+	s.mu.Lock()
+	s.templates = templates
+	s.mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// addTemplate 解析模板并加入集合，同名同版本的模板被替换（目录中的文件覆盖内置模板）
+func addTemplate(templates map[string][]*Template, fileName string, source string, text string) error {
+	match := fileNamePattern.FindStringSubmatch(fileName)
+	if match == nil {
+		return fmt.Errorf("invalid template file name: %s", fileName)
+	}
+	version, _ := strconv.Atoi(match[2])
+
+	tmpl, err := template.New(fileName).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+
+	t := &Template{Name: match[1], Version: version, Source: source, Text: text, tmpl: tmpl}
+	for i, existing := range templates[t.Name] {
+		if existing.Version == version {
+			templates[t.Name][i] = t
+			return nil
+		}
+	}
+	templates[t.Name] = append(templates[t.Name], t)
+	return nil
+}
+
+// List 返回所有模板，按名称和版本排序
+func (s *Store) List() []*Template {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var names []string
+	for name := range s.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var list []*Template
+	for _, name := range names {
+		list = append(list, s.templates[name]...)
+	}
+	return list
+}
+
+// Get 获取模板，version 为 0 时返回最高版本
+func (s *Store) Get(name string, version int) (*Template, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.templates[name]
+	if len(versions) == 0 {
+		return nil, false
+	}
+	if version == 0 {
+		return versions[len(versions)-1], true
+	}
+	for _, t := range versions {
+		if t.Version == version {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Render 渲染模板，返回文本和使用的模板
+func (s *Store) Render(name string, version int, data Data) (string, *Template, error) {
+	t, ok := s.Get(name, version)
+	if !ok {
+		return "", nil, fmt.Errorf("prompt template %s (version %d) not found", name, version)
+	}
+
+	var out strings.Builder
+	if err := t.tmpl.Execute(&out, data); err != nil {
+		return "", t, fmt.Errorf("render %s: %w", t.ID(), err)
+	}
+	return strings.TrimSpace(out.String()), t, nil
+}
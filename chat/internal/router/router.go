@@ -1,10 +1,12 @@
 package router
 
 import (
+	"kelisim-chat/internal/config"
 	"kelisim-chat/internal/handlers"
 	"kelisim-chat/internal/middleware"
 	"kelisim-chat/internal/websocket"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -43,6 +45,13 @@ func SetupRouter() *gin.Engine {
 	wsHandler := handlers.NewWebSocketHandler(hub)
 	notificationHandler := handlers.NewNotificationHandler()
 	aiAssistantHandler := handlers.NewAIAssistantHandler(hub)
+	storageHandler := handlers.NewStorageHandler()
+	moderationHandler := handlers.NewModerationHandler(hub)
+
+	// 定期重新扫描扫描失败或积压的文件
+	if interval := config.AppConfig.Scanner.RetryInterval; interval > 0 {
+		fileHandler.StartScanRetry(time.Duration(interval) * time.Minute)
+	}
 
 	// WebSocket 路由
 	r.GET("/ws", wsHandler.HandleWebSocket)
@@ -54,16 +63,53 @@ func SetupRouter() *gin.Engine {
 		operator := api.Group("/operator")
 		operator.Use(middleware.OperatorAuthMiddleware())
 		{
-			// Get all chats (operators can see all chats)
+			// Get all chats (operators can see all chats): cursor pagination, filters, search
 			operator.GET("/chats", chatHandler.GetChats)
 
+			// Mark a chat as read by the operator (drives unread counts in the operator chat list)
+			operator.PUT("/chats/:id/read", messageHandler.MarkChatAsRead)
+
 			// Send message as operator (system message)
 			operator.POST("/chats/:id/messages", messageHandler.SendMessage)
 
+			// Enable or disable the participant AI assistant for a chat
+			operator.PUT("/chats/:id/ai-enabled", chatHandler.SetAIEnabled)
+
 			// AI assistant tools (only for operators, no messages saved to chat)
 			operator.POST("/chats/:id/ai/ask", aiAssistantHandler.OperatorAskAI)
 			operator.POST("/chats/:id/ai/summarize", aiAssistantHandler.OperatorSummarize)
+			operator.POST("/chats/:id/ai/ask/stream", aiAssistantHandler.OperatorAskAIStream)
+			operator.POST("/chats/:id/ai/summarize/stream", aiAssistantHandler.OperatorSummarizeStream)
 			operator.POST("/chats/:id/ai/analyze-files", aiAssistantHandler.OperatorAnalyzeFiles)
+			operator.POST("/chats/:id/ai/suggest-replies", aiAssistantHandler.OperatorSuggestReplies)
+			operator.POST("/chats/:id/ai/extract", aiAssistantHandler.OperatorExtractCase)
+			operator.GET("/chats/:id/ai/extract", aiAssistantHandler.GetCaseExtraction)
+
+			// Send one of the suggested replies as an operator message
+			operator.POST("/chats/:id/ai/suggest-replies/send", messageHandler.SendSuggestedReply)
+
+			// Publish a reviewed AI answer to the chat as an ai_assistant message attributed to the operator
+			operator.POST("/chats/:id/ai/publish", messageHandler.PublishAIAnswer)
+
+			// AI interaction history and usage report (cost control)
+			operator.GET("/ai/history", aiAssistantHandler.GetAIHistory)
+			operator.GET("/ai/usage", aiAssistantHandler.GetAIUsage)
+
+			// Search across all chats and answer with citations to chat and message IDs
+			operator.POST("/ai/search", aiAssistantHandler.OperatorSearch)
+
+			// Prompt templates: list, preview with a chat's data, reload from disk
+			operator.GET("/ai/prompts", aiAssistantHandler.ListPrompts)
+			operator.POST("/ai/prompts/preview", aiAssistantHandler.PreviewPrompt)
+			operator.POST("/ai/prompts/reload", aiAssistantHandler.ReloadPrompts)
+
+			// Message risk flags raised by moderation (also pushed to the "flags" WebSocket channel)
+			operator.GET("/flags", moderationHandler.ListFlags)
+			operator.POST("/flags/:id/acknowledge", moderationHandler.AcknowledgeFlag)
+			operator.POST("/flags/:id/dismiss", moderationHandler.DismissFlag)
+
+			// Storage usage: largest consumers
+			operator.GET("/storage/usage", storageHandler.OperatorGetTopConsumers)
 		}
 
 		// 需要认证的路由（普通用户）
@@ -80,8 +126,11 @@ func SetupRouter() *gin.Engine {
 				chats.POST("/:id/participants", chatHandler.AddParticipant)
 				chats.DELETE("/:id/participants/:userId", chatHandler.RemoveParticipant)
 				chats.GET("/:id/available-members", chatHandler.GetOrganizationMembers)
+				chats.PUT("/:id/auto-translate", chatHandler.SetAutoTranslate)
+				chats.PUT("/:id/ai-enabled", chatHandler.SetAIEnabled)
 
-				// AI routes removed - now available only to operators
+				// AI 助手（聊天室开启 ai_enabled 后可用，按用户限流和每日配额）
+				chats.POST("/:id/ai/ask", middleware.AIRateLimitMiddleware(), middleware.AIDailyQuotaMiddleware(), aiAssistantHandler.AskAI)
 			}
 
 			// 消息管理
@@ -96,6 +145,7 @@ func SetupRouter() *gin.Engine {
 			{
 				messageStatus.PUT("/:id/status", messageHandler.MarkAsRead)
 				messageStatus.DELETE("/:id", messageHandler.DeleteMessage)
+				messageStatus.POST("/:id/translate", messageHandler.TranslateMessage)
 			}
 
 			// 聊天已读
@@ -109,11 +159,20 @@ func SetupRouter() *gin.Engine {
 			{
 				files.POST("", fileHandler.UploadFile)
 				files.GET("", fileHandler.GetChatFiles)
+
+				// 分片上传（断点续传）
+				files.POST("/uploads", fileHandler.CreateUploadSession)
+				files.GET("/uploads/:uploadId", fileHandler.GetUploadSession)
+				files.PUT("/uploads/:uploadId", fileHandler.UploadChunk)
+				files.POST("/uploads/:uploadId/complete", fileHandler.CompleteUpload)
 			}
 
 			// 文件下载
 			auth.GET("/files/:id/download", fileHandler.DownloadFile)
 
+			// 存储用量（公司管理员）
+			auth.GET("/storage/usage", storageHandler.GetStorageUsage)
+
 			// 推送通知
 			notifications := auth.Group("/notifications")
 			{
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AIInteractionService Operator AI 调用记录服务
+type AIInteractionService struct{}
+
+// NewAIInteractionService 创建 AI 调用记录服务
+func NewAIInteractionService() *AIInteractionService {
+	return &AIInteractionService{}
+}
+
+// AIUsageRow 按 Operator 和日期聚合的用量
+type AIUsageRow struct {
+	Day              string `json:"day,omitempty"`
+	OperatorID       uint   `json:"operator_id,omitempty"`
+	Calls            int64  `json:"calls"`
+	Errors           int64  `json:"errors"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	AvgLatencyMs     int64  `json:"avg_latency_ms"`
+}
+
+// AIUsageFilter 用量统计条件
+type AIUsageFilter struct {
+	From       time.Time // 包含
+	To         time.Time // 不包含
+	OperatorID uint      // 0 表示全部
+}
+
+// HashPrompt 计算请求内容的 SHA-256，用于识别重复的提问
+func HashPrompt(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Record 保存一次 AI 调用
+func (s *AIInteractionService) Record(interaction *models.AIInteraction) error {
+	return database.DB.Create(interaction).Error
+}
+
+// GetByID 根据ID获取 AI 调用记录
+func (s *AIInteractionService) GetByID(id uint) (*models.AIInteraction, error) {
+	var interaction models.AIInteraction
+	err := database.DB.First(&interaction, id).Error
+	return &interaction, err
+}
+
+// GetHistory 获取 AI 调用历史，按时间倒序
+func (s *AIInteractionService) GetHistory(chatID uint, operatorID uint, limit int, offset int) ([]models.AIInteraction, int64, error) {
+	query := database.DB.Model(&models.AIInteraction{})
+	if chatID > 0 {
+		query = query.Where("chat_id = ?", chatID)
+	}
+	if operatorID > 0 {
+		query = query.Where("operator_id = ?", operatorID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var interactions []models.AIInteraction
+	err := query.Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&interactions).Error
+
+	return interactions, total, err
+}
+
+// GetDailyUsage 按 Operator 和日期统计用量
+func (s *AIInteractionService) GetDailyUsage(filter AIUsageFilter) ([]AIUsageRow, error) {
+	var rows []AIUsageRow
+	err := s.usageQuery(filter).
+		Select("DATE_FORMAT(created_at, '%Y-%m-%d') AS day, operator_id, " + usageColumns).
+		Group("day, operator_id").
+		Order("day ASC, operator_id ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetOperatorUsage 按 Operator 统计用量，token 最多的在前
+func (s *AIInteractionService) GetOperatorUsage(filter AIUsageFilter) ([]AIUsageRow, error) {
+	var rows []AIUsageRow
+	err := s.usageQuery(filter).
+		Select("operator_id, " + usageColumns).
+		Group("operator_id").
+		Order("total_tokens DESC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetTotalUsage 统计总用量
+func (s *AIInteractionService) GetTotalUsage(filter AIUsageFilter) (*AIUsageRow, error) {
+	var row AIUsageRow
+	err := s.usageQuery(filter).
+		Select(usageColumns).
+		Scan(&row).Error
+	return &row, err
+}
+
+// usageColumns 用量聚合列
+const usageColumns = "COUNT(*) AS calls, " +
+	"SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) AS errors, " +
+	"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, " +
+	"COALESCE(SUM(completion_tokens), 0) AS completion_tokens, " +
+	"COALESCE(SUM(prompt_tokens + completion_tokens), 0) AS total_tokens, " +
+	"COALESCE(ROUND(AVG(latency_ms)), 0) AS avg_latency_ms"
+
+// usageQuery 用量统计的基础查询
+func (s *AIInteractionService) usageQuery(filter AIUsageFilter) *gorm.DB {
+	query := database.DB.Model(&models.AIInteraction{}).
+		Where("created_at >= ? AND created_at < ?", filter.From, filter.To)
+	if filter.OperatorID > 0 {
+		query = query.Where("operator_id = ?", filter.OperatorID)
+	}
+	return query
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"kelisim-chat/internal/models"
+	"strings"
+)
+
+// FormatTranscript converts chat messages (oldest first) into model messages for AI requests.
+// Participants become user turns labelled with their name and user type, e.g.
+// "Aigerim Nurlanovna (lawyer): ...", ai_assistant messages become assistant turns preceded by
+// the participant's question (stored on the answer message, there is no separate user message),
+// files are rendered as "[document: name.pdf]" and system events as short descriptions.
+// Messages without anything to show are skipped.
+func FormatTranscript(messages []models.Message) []ChatMessage {
+	transcript := make([]ChatMessage, 0, len(messages))
+
+	for _, msg := range messages {
+		switch msg.Type {
+		case "ai_assistant":
+			if msg.Question != nil && strings.TrimSpace(*msg.Question) != "" {
+				transcript = append(transcript, ChatMessage{
+					Role:    "user",
+					Content: AskerLabel(msg) + " (asked the AI assistant): " + strings.TrimSpace(*msg.Question),
+				})
+			}
+			if text := messageText(msg); text != "" {
+				transcript = append(transcript, ChatMessage{Role: "assistant", Content: text})
+			}
+		case "system":
+			if event := describeSystemEvent(msg); event != "" {
+				transcript = append(transcript, ChatMessage{Role: "system", Content: "[event] " + event})
+			}
+		default:
+			body := messageBody(msg)
+			if body == "" {
+				continue
+			}
+			transcript = append(transcript, ChatMessage{
+				Role:    "user",
+				Content: SpeakerLabel(msg) + ": " + body,
+			})
+		}
+	}
+
+	return transcript
+}
+
+// SpeakerLabel returns the name and user type of the message sender, e.g. "Daniyar Akhmetov (expert)".
+// Messages without a sender were sent by an operator, e.g. "Aliya (operator)".
+func SpeakerLabel(msg models.Message) string {
+	if msg.SenderID == nil {
+		if msg.Operator != nil && strings.TrimSpace(msg.Operator.Name) != "" {
+			return strings.TrimSpace(msg.Operator.Name) + " (operator)"
+		}
+		return "Operator"
+	}
+	return userLabel(msg.Sender, *msg.SenderID)
+}
+
+// AskerLabel returns the name and user type of the participant who asked the AI assistant.
+func AskerLabel(msg models.Message) string {
+	if msg.AskedBy == nil {
+		return "Participant"
+	}
+	return userLabel(msg.Asker, *msg.AskedBy)
+}
+
+// userLabel formats a user as "Name (user type)", falling back to "User #id" when not loaded
+func userLabel(user *models.User, userID uint) string {
+	if user == nil {
+		return fmt.Sprintf("User #%d", userID)
+	}
+
+	name := strings.TrimSpace(user.GetFullName())
+	if name == "" {
+		name = fmt.Sprintf("User #%d", user.ID)
+	}
+	if user.UserType != "" {
+		name += " (" + user.UserType + ")"
+	}
+	return name
+}
+
+// messageBody renders the text and attachment of a participant message
+func messageBody(msg models.Message) string {
+	text := messageText(msg)
+
+	switch msg.Type {
+	case "document", "image", "video":
+		name := "file"
+		if msg.FileName != nil && *msg.FileName != "" {
+			name = *msg.FileName
+		}
+		attachment := fmt.Sprintf("[%s: %s]", msg.Type, name)
+		if text != "" {
+			return attachment + " " + text
+		}
+		return attachment
+	default:
+		return text
+	}
+}
+
+// messageText returns the trimmed text content of a message
+func messageText(msg models.Message) string {
+	if msg.Content == nil {
+		return ""
+	}
+	return strings.TrimSpace(*msg.Content)
+}
+
+// describeSystemEvent renders a system message ({"type": ..., "data": {...}}) as a sentence
+func describeSystemEvent(msg models.Message) string {
+	text := messageText(msg)
+	if text == "" {
+		return ""
+	}
+
+	var event struct {
+		Type string                 `json:"type"`
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(text), &event); err != nil || event.Type == "" {
+		// Plain-text system message
+		return text
+	}
+
+	field := func(key string) string {
+		if value, ok := event.Data[key].(string); ok && value != "" {
+			return value
+		}
+		return "someone"
+	}
+
+	switch event.Type {
+	case "user_joined":
+		return field("user_name") + " joined the chat"
+	case "user_left":
+		return field("user_name") + " left the chat"
+	case "file_quarantined":
+		return "the file " + field("file_name") + " was removed because it contained malware"
+	default:
+		return strings.ReplaceAll(event.Type, "_", " ")
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// CaseExtractionService 案件信息提取结果服务
+type CaseExtractionService struct{}
+
+// NewCaseExtractionService 创建案件信息提取结果服务
+func NewCaseExtractionService() *CaseExtractionService {
+	return &CaseExtractionService{}
+}
+
+// GetByChatID 获取聊天室最新的提取结果
+func (s *CaseExtractionService) GetByChatID(chatID uint) (*models.ChatCaseExtraction, error) {
+	var extraction models.ChatCaseExtraction
+	err := database.DB.Where("chat_id = ?", chatID).First(&extraction).Error
+	return &extraction, err
+}
+
+// Save 保存提取结果，覆盖聊天室之前的结果
+func (s *CaseExtractionService) Save(extraction *models.ChatCaseExtraction) error {
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "chat_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"issue_category":  extraction.IssueCategory,
+			"data":            extraction.Data,
+			"last_message_id": extraction.LastMessageID,
+			"message_count":   extraction.MessageCount,
+			"operator_id":     extraction.OperatorID,
+			"interaction_id":  extraction.InteractionID,
+			"updated_at":      time.Now(),
+		}),
+	}).Create(extraction).Error; err != nil {
+		return err
+	}
+
+	// 以数据库中的记录为准（更新时 ID 和 created_at 沿用已有记录）
+	return database.DB.Where("chat_id = ?", extraction.ChatID).First(extraction).Error
+}
@@ -0,0 +1,416 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// chatPreviewRunes 聊天列表中最后一条消息预览的最大字符数
+const chatPreviewRunes = 100
+
+// ErrInvalidCursor 分页游标无法解析
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ChatListItem 聊天列表中的一行：聊天室、最后一条消息和未读数
+type ChatListItem struct {
+	models.Chat
+	LastMessage *ChatMessagePreview `json:"last_message"`
+	UnreadCount int64               `json:"unread_count"`
+}
+
+// UserChatListItem 用户聊天列表中的一行
+type UserChatListItem struct {
+	ChatListItem
+	Mentioned bool `json:"mentioned"` // 未读消息中有 @提及当前用户，或有回答当前用户提问的 AI 消息
+}
+
+// ChatMessagePreview 聊天列表中最后一条消息的摘要
+type ChatMessagePreview struct {
+	ID         uint      `json:"id"`
+	Type       string    `json:"type"`
+	Text       string    `json:"text"` // 文本的前 100 个字符，文件消息为 [document: 文件名]，系统消息为空
+	SenderID   *uint     `json:"sender_id,omitempty"`
+	SenderType string    `json:"sender_type"` // user、operator、ai_assistant 或 system
+	SenderName string    `json:"sender_name,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ChatPage 聊天列表的分页和排序
+type ChatPage struct {
+	Limit     int    // 0 表示不分页，返回全部
+	Cursor    string // 上一页返回的 next_cursor，为空表示第一页
+	SortBy    string // updated_at（默认，最近活动）或 created_at
+	Ascending bool
+}
+
+// OperatorChatFilter Operator 聊天列表的过滤条件，零值表示不过滤
+type OperatorChatFilter struct {
+	Type           string // private 或 group
+	UserType       string // 包含该类型参与者的聊天室：company_admin、expert、lawyer
+	OrganizationID uint   // 包含该组织成员的聊天室
+	CreatedFrom    time.Time
+	CreatedTo      time.Time // 不包含
+	UpdatedFrom    time.Time
+	UpdatedTo      time.Time // 不包含
+	Unanswered     bool      // 最后一条参与者消息之后没有 Operator 回复
+	HasFiles       bool      // 有（扫描通过的）文件
+	Flagged        bool      // 有未处理的风险标记
+	Search         string    // 标题或参与者姓名
+}
+
+// ListOperatorChats 按条件分页获取所有聊天室 (Operator 专用)
+// 每行带最后一条消息和当前 Operator 的未读数；返回下一页的游标，没有更多时为空
+func (s *ChatService) ListOperatorChats(operatorID uint, filter OperatorChatFilter, page ChatPage) ([]ChatListItem, string, error) {
+	query := database.DB.Model(&models.Chat{}).Where("chats.deleted_at IS NULL")
+
+	if filter.Type != "" {
+		query = query.Where("chats.type = ?", filter.Type)
+	}
+	if filter.UserType != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM chat_participants cp JOIN users u ON u.id = cp.user_id WHERE cp.chat_id = chats.id AND u.user_type = ?)", filter.UserType)
+	}
+	if filter.OrganizationID != 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM chat_participants cp JOIN organization_members om ON om.user_id = cp.user_id WHERE cp.chat_id = chats.id AND om.organization_id = ?)", filter.OrganizationID)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		query = query.Where("chats.created_at >= ?", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		query = query.Where("chats.created_at < ?", filter.CreatedTo)
+	}
+	if !filter.UpdatedFrom.IsZero() {
+		query = query.Where("chats.updated_at >= ?", filter.UpdatedFrom)
+	}
+	if !filter.UpdatedTo.IsZero() {
+		query = query.Where("chats.updated_at < ?", filter.UpdatedTo)
+	}
+	if filter.Unanswered {
+		query = query.Where(`EXISTS (SELECT 1 FROM messages pm WHERE pm.chat_id = chats.id AND pm.sender_id IS NOT NULL AND pm.deleted_at IS NULL
+			AND pm.id > COALESCE((SELECT MAX(rm.id) FROM messages rm WHERE rm.chat_id = chats.id AND rm.operator_id IS NOT NULL AND rm.deleted_at IS NULL), 0))`)
+	}
+	if filter.HasFiles {
+		query = query.Where("EXISTS (SELECT 1 FROM chat_files cf WHERE cf.chat_id = chats.id AND cf.scan_status = ?)", ScanStatusClean)
+	}
+	if filter.Flagged {
+		query = query.Where("EXISTS (SELECT 1 FROM message_flags mf WHERE mf.chat_id = chats.id AND mf.status = ?)", FlagStatusOpen)
+	}
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		like := "%" + escapeLike(search) + "%"
+		query = query.Where("(chats.title LIKE ? OR EXISTS (SELECT 1 FROM chat_participants cp JOIN users u ON u.id = cp.user_id WHERE cp.chat_id = chats.id AND CONCAT(u.first_name, ' ', u.last_name) LIKE ?))", like, like)
+	}
+
+	chats, nextCursor, err := findChatPage(query.Preload("Creator").Preload("Participants.User"), page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	items, err := s.chatListItems(chats, func(chatIDs []uint) (map[uint]int64, error) {
+		return operatorUnreadCounts(operatorID, chatIDs)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return items, nextCursor, nil
+}
+
+// ListUserChats 分页获取用户参与的聊天室，updatedSince 非零时只返回之后有更新的聊天室（增量同步），
+// 包括聊天室本身的更新和该用户在其他设备上的已读（chat_participants.updated_at）
+// 每行带最后一条消息、未读数和提及标记
+func (s *ChatService) ListUserChats(userID uint, updatedSince time.Time, page ChatPage) ([]UserChatListItem, string, error) {
+	query := database.DB.Model(&models.Chat{}).
+		Where("chats.deleted_at IS NULL").
+		Where("EXISTS (SELECT 1 FROM chat_participants cp WHERE cp.chat_id = chats.id AND cp.user_id = ?)", userID)
+	if !updatedSince.IsZero() {
+		query = query.Where("(chats.updated_at >= ? OR EXISTS (SELECT 1 FROM chat_participants cp WHERE cp.chat_id = chats.id AND cp.user_id = ? AND cp.updated_at >= ?))",
+			updatedSince, userID, updatedSince)
+	}
+
+	query = query.
+		Preload("Creator").
+		Preload("Participants", func(db *gorm.DB) *gorm.DB {
+			return db.Order("chat_participants.role DESC, chat_participants.created_at ASC")
+		}).
+		Preload("Participants.User")
+
+	chats, nextCursor, err := findChatPage(query, page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var mentions map[uint]bool
+	items, err := s.chatListItems(chats, func(chatIDs []uint) (map[uint]int64, error) {
+		counts, mentioned, err := userUnreadCounts(userID, chatIDs)
+		mentions = mentioned
+		return counts, err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	userItems := make([]UserChatListItem, len(items))
+	for i, item := range items {
+		userItems[i] = UserChatListItem{ChatListItem: item, Mentioned: mentions[item.ID]}
+	}
+	return userItems, nextCursor, nil
+}
+
+// MarkReadByOperator 记录 Operator 已读到聊天室当前的最后一条消息
+func (s *ChatService) MarkReadByOperator(chatID uint, operatorID uint) error {
+	var lastMessageID uint
+	if err := database.DB.Model(&models.Message{}).
+		Where("chat_id = ?", chatID).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&lastMessageID).Error; err != nil {
+		return err
+	}
+
+	read := &models.OperatorChatRead{
+		OperatorID:        operatorID,
+		ChatID:            chatID,
+		LastReadMessageID: lastMessageID,
+		UpdatedAt:         time.Now(),
+	}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "operator_id"}, {Name: "chat_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_read_message_id", "updated_at"}),
+	}).Create(read).Error
+}
+
+// findChatPage 按排序字段和ID做游标分页，多取一条判断是否还有下一页；Limit 为 0 时不分页
+func findChatPage(query *gorm.DB, page ChatPage) ([]models.Chat, string, error) {
+	column := "chats.updated_at"
+	if page.SortBy == "created_at" {
+		column = "chats.created_at"
+	}
+	direction, compare := "DESC", "<"
+	if page.Ascending {
+		direction, compare = "ASC", ">"
+	}
+
+	if page.Cursor != "" {
+		cursorTime, cursorID, err := decodeChatCursor(page.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND chats.id %s ?))", column, compare, column, compare), cursorTime, cursorTime, cursorID)
+	}
+
+	query = query.Order(fmt.Sprintf("%s %s, chats.id %s", column, direction, direction))
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit + 1)
+	}
+
+	var chats []models.Chat
+	if err := query.Find(&chats).Error; err != nil {
+		return nil, "", err
+	}
+
+	if page.Limit == 0 || len(chats) <= page.Limit {
+		return chats, "", nil
+	}
+	chats = chats[:page.Limit]
+	last := chats[len(chats)-1]
+	if page.SortBy == "created_at" {
+		return chats, encodeChatCursor(last.CreatedAt, last.ID), nil
+	}
+	return chats, encodeChatCursor(last.UpdatedAt, last.ID), nil
+}
+
+// chatListItems 为一页聊天室附加最后一条消息和未读数，查询次数与页大小无关
+func (s *ChatService) chatListItems(chats []models.Chat, unreadCounts func(chatIDs []uint) (map[uint]int64, error)) ([]ChatListItem, error) {
+	items := make([]ChatListItem, 0, len(chats))
+	if len(chats) == 0 {
+		return items, nil
+	}
+
+	chatIDs := make([]uint, len(chats))
+	for i, chat := range chats {
+		chatIDs[i] = chat.ID
+	}
+
+	lastMessages, err := lastChatMessages(chatIDs)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := unreadCounts(chatIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chat := range chats {
+		items = append(items, ChatListItem{
+			Chat:        chat,
+			LastMessage: lastMessages[chat.ID],
+			UnreadCount: counts[chat.ID],
+		})
+	}
+	return items, nil
+}
+
+// lastChatMessages 获取每个聊天室最后一条未删除、对参与者可见的消息
+func lastChatMessages(chatIDs []uint) (map[uint]*ChatMessagePreview, error) {
+	lastIDs := database.DB.Model(&models.Message{}).
+		Select("MAX(id) AS id").
+		Where("chat_id IN ?", chatIDs).
+		Where(ScannedFilesCondition).
+		Group("chat_id")
+
+	var messages []models.Message
+	err := database.DB.
+		Joins("JOIN (?) AS last_messages ON last_messages.id = messages.id", lastIDs).
+		Preload("Sender").
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	previews := make(map[uint]*ChatMessagePreview, len(messages))
+	for i := range messages {
+		previews[messages[i].ChatID] = newChatMessagePreview(&messages[i])
+	}
+	return previews, nil
+}
+
+// operatorUnreadCounts 统计 Operator 已读位置之后的参与者消息数
+func operatorUnreadCounts(operatorID uint, chatIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		ChatID uint
+		Count  int64
+	}
+	err := database.DB.Table("messages").
+		Select("messages.chat_id, COUNT(*) AS count").
+		Joins("LEFT JOIN operator_chat_reads r ON r.chat_id = messages.chat_id AND r.operator_id = ?", operatorID).
+		Where("messages.chat_id IN ? AND messages.deleted_at IS NULL AND messages.sender_id IS NOT NULL", chatIDs).
+		Where("messages.id > COALESCE(r.last_read_message_id, 0)").
+		Where(ScannedFilesCondition).
+		Group("messages.chat_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ChatID] = row.Count
+	}
+	return counts, nil
+}
+
+// userUnreadCounts 统计用户在每个聊天室的未读消息数，以及未读消息中是否有提及
+// 他人发送、晚于 chat_participants.last_read_at 且没有 message_status 已读记录的消息为未读；
+// 提及指内容包含 "@名 姓"，或是回答该用户提问的 AI 消息
+func userUnreadCounts(userID uint, chatIDs []uint) (map[uint]int64, map[uint]bool, error) {
+	var user struct {
+		FirstName string
+		LastName  string
+	}
+	if err := NewChatService().GetUserBasicInfo(userID, &user); err != nil {
+		return nil, nil, err
+	}
+	// 没有姓名的用户只按 AI 提问判断提及，避免 "%@%" 匹配任意 @
+	mentionCondition, mentionArgs := "messages.asked_by = ?", []interface{}{userID}
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		mentionCondition = "messages.content LIKE ? OR " + mentionCondition
+		mentionArgs = append([]interface{}{"%@" + escapeLike(name) + "%"}, mentionArgs...)
+	}
+
+	var rows []struct {
+		ChatID   uint
+		Count    int64
+		Mentions int64
+	}
+	err := database.DB.Table("messages").
+		Select("messages.chat_id, COUNT(*) AS count, SUM(CASE WHEN "+mentionCondition+" THEN 1 ELSE 0 END) AS mentions", mentionArgs...).
+		Joins("JOIN chat_participants cp ON cp.chat_id = messages.chat_id AND cp.user_id = ?", userID).
+		Where("messages.chat_id IN ? AND messages.deleted_at IS NULL", chatIDs).
+		Where("messages.sender_id IS NULL OR messages.sender_id <> ?", userID).
+		Where("cp.last_read_at IS NULL OR messages.created_at > cp.last_read_at").
+		Where("NOT EXISTS (SELECT 1 FROM message_status ms WHERE ms.message_id = messages.id AND ms.user_id = ? AND ms.status = 'read')", userID).
+		Where(ScannedFilesCondition).
+		Group("messages.chat_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	mentioned := make(map[uint]bool, len(rows))
+	for _, row := range rows {
+		counts[row.ChatID] = row.Count
+		mentioned[row.ChatID] = row.Mentions > 0
+	}
+	return counts, mentioned, nil
+}
+
+// newChatMessagePreview 生成消息摘要，文件消息的格式与 AI 聊天记录一致
+func newChatMessagePreview(msg *models.Message) *ChatMessagePreview {
+	preview := &ChatMessagePreview{
+		ID:         msg.ID,
+		Type:       msg.Type,
+		SenderID:   msg.SenderID,
+		SenderType: msg.SenderType,
+		CreatedAt:  msg.CreatedAt,
+	}
+
+	switch msg.Type {
+	case "document", "image", "video":
+		name := "file"
+		if msg.FileName != nil && *msg.FileName != "" {
+			name = *msg.FileName
+		}
+		preview.Text = fmt.Sprintf("[%s: %s]", msg.Type, name)
+	case "system":
+		// 系统消息的内容是 JSON，由客户端按类型展示
+	default:
+		if msg.Content != nil {
+			preview.Text = truncateRunes(strings.TrimSpace(*msg.Content), chatPreviewRunes)
+		}
+	}
+
+	if msg.Sender != nil {
+		preview.SenderName = msg.Sender.GetFullName()
+	} else if msg.Operator != nil {
+		preview.SenderName = msg.Operator.Name
+	}
+	return preview
+}
+
+// encodeChatCursor 把排序字段和ID编码为不透明的游标
+func encodeChatCursor(t time.Time, id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", t.UnixNano(), id)))
+}
+
+// decodeChatCursor 解析 encodeChatCursor 生成的游标
+func decodeChatCursor(cursor string) (time.Time, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	chatID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return time.Unix(0, n), uint(chatID), nil
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
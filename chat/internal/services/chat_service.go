@@ -11,6 +11,9 @@ import (
 // ChatService 聊天服务
 type ChatService struct{}
 
+// languageSampleMessages 检测聊天语言时参考的最近消息数
+const languageSampleMessages = 50
+
 // NewChatService 创建聊天服务
 func NewChatService() *ChatService {
 	return &ChatService{}
@@ -77,39 +80,6 @@ func (s *ChatService) CreateChat(title string, chatType string, createdBy uint,
 	return chat, nil
 }
 
-// GetUserChats 获取用户的聊天列表
-func (s *ChatService) GetUserChats(userID uint) ([]models.Chat, error) {
-	var chats []models.Chat
-
-	err := database.DB.
-		Joins("JOIN chat_participants ON chats.id = chat_participants.chat_id").
-		Where("chat_participants.user_id = ? AND chats.deleted_at IS NULL", userID).
-		Preload("Creator").
-		Preload("Participants", func(db *gorm.DB) *gorm.DB {
-			return db.Order("chat_participants.role DESC, chat_participants.created_at ASC")
-		}).
-		Preload("Participants.User").
-		Distinct().
-		Order("chats.updated_at DESC").
-		Find(&chats).Error
-
-	return chats, err
-}
-
-// GetAllChats 获取所有聊天列表 (Operator 专用)
-func (s *ChatService) GetAllChats() ([]models.Chat, error) {
-	var chats []models.Chat
-
-	err := database.DB.
-		Where("deleted_at IS NULL").
-		Preload("Creator").
-		Preload("Participants.User").
-		Order("updated_at DESC").
-		Find(&chats).Error
-
-	return chats, err
-}
-
 // GetChatByID 根据ID获取聊天室
 func (s *ChatService) GetChatByID(chatID uint, userID uint) (*models.Chat, error) {
 	var chat models.Chat
@@ -188,6 +158,67 @@ func (s *ChatService) IsUserInChat(chatID uint, userID uint) bool {
 	return count > 0
 }
 
+// SetAutoTranslate 开启或关闭聊天室的自动翻译
+func (s *ChatService) SetAutoTranslate(chatID uint, enabled bool) error {
+	return database.DB.Model(&models.Chat{}).
+		Where("id = ?", chatID).
+		Update("auto_translate", enabled).Error
+}
+
+// IsAutoTranslate 检查聊天室是否开启了自动翻译
+func (s *ChatService) IsAutoTranslate(chatID uint) bool {
+	var chat models.Chat
+	if err := database.DB.Select("auto_translate").Where("id = ?", chatID).First(&chat).Error; err != nil {
+		return false
+	}
+	return chat.AutoTranslate
+}
+
+// SetAIEnabled 开启或关闭参与者的 AI 助手
+func (s *ChatService) SetAIEnabled(chatID uint, enabled bool) error {
+	return database.DB.Model(&models.Chat{}).
+		Where("id = ?", chatID).
+		Update("ai_enabled", enabled).Error
+}
+
+// IsAIEnabled 检查聊天室是否允许参与者向 AI 助手提问
+func (s *ChatService) IsAIEnabled(chatID uint) bool {
+	var chat models.Chat
+	if err := database.DB.Select("ai_enabled").Where("id = ?", chatID).First(&chat).Error; err != nil {
+		return false
+	}
+	return chat.AIEnabled
+}
+
+// GetLanguage 获取聊天室检测到的语言，未检测时返回空字符串
+func (s *ChatService) GetLanguage(chatID uint) string {
+	var chat models.Chat
+	if err := database.DB.Select("language").Where("id = ?", chatID).First(&chat).Error; err != nil {
+		return ""
+	}
+	return chat.Language
+}
+
+// DetectLanguage 根据最近的参与者消息检测聊天语言并保存，返回检测结果
+// 最近的消息都没有文字时保留之前的结果
+func (s *ChatService) DetectLanguage(chatID uint) (string, error) {
+	messages, err := NewMessageService().GetRecentMessages(chatID, languageSampleMessages)
+	if err != nil {
+		return "", err
+	}
+
+	current := s.GetLanguage(chatID)
+	language := DominantLanguage(messages, current)
+	if language == current {
+		return language, nil
+	}
+
+	err = database.DB.Model(&models.Chat{}).
+		Where("id = ?", chatID).
+		Update("language", language).Error
+	return language, err
+}
+
 // UpdateLastReadAt 更新用户最后读取时间
 func (s *ChatService) UpdateLastReadAt(chatID uint, userID uint) error {
 	return database.DB.Model(&models.ChatParticipant{}).
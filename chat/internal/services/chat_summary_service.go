@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// 滚动总结：每批读取的新消息数，后台每轮最多刷新的总结数
+const (
+	summaryMessageBatch  = 200
+	summaryRefreshPerRun = 20
+)
+
+// summaryLocks 避免同一聊天室同一语言的总结被并发刷新
+var summaryLocks sync.Map
+
+// ChatSummaryService 聊天室滚动总结服务
+type ChatSummaryService struct {
+	llmService     *LLMService
+	messageService *MessageService
+}
+
+// NewChatSummaryService 创建滚动总结服务
+func NewChatSummaryService(llmService *LLMService) *ChatSummaryService {
+	return &ChatSummaryService{
+		llmService:     llmService,
+		messageService: NewMessageService(),
+	}
+}
+
+// GetSummary 获取聊天室指定语言的总结
+func (s *ChatSummaryService) GetSummary(chatID uint, language string) (*models.ChatSummary, error) {
+	var summary models.ChatSummary
+	err := database.DB.Where("chat_id = ? AND language = ?", chatID, language).First(&summary).Error
+	return &summary, err
+}
+
+// Refresh 用上次总结之后的新消息更新总结，返回最新的总结和本次新总结的消息数
+// prompt 为渲染后的 summarize 模板；新消息较多时分批处理，每批完成后立即保存，失败时已完成的部分不会丢失
+func (s *ChatSummaryService) Refresh(ctx context.Context, chatID uint, language string, prompt string) (*models.ChatSummary, int, error) {
+	unlock := lockSummary(chatID, language)
+	defer unlock()
+
+	summary, err := s.GetSummary(chatID, language)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, err
+		}
+		summary = &models.ChatSummary{ChatID: chatID, Language: language}
+	}
+
+	added := 0
+	for {
+		messages, err := s.messageService.GetMessagesAfter(chatID, summary.LastMessageID, summaryMessageBatch)
+		if err != nil {
+			return summary, added, err
+		}
+		if len(messages) == 0 {
+			return summary, added, nil
+		}
+
+		// 逐条转换，记录每段对话对应的消息，便于按模型实际处理的数量推进 last_message_id
+		var transcript []ChatMessage
+		var sources []int
+		for i, message := range messages {
+			for _, turn := range FormatTranscript([]models.Message{message}) {
+				transcript = append(transcript, turn)
+				sources = append(sources, i)
+			}
+		}
+
+		// 整批都是无内容的消息，直接跳过
+		last := len(messages) - 1
+		if len(transcript) > 0 {
+			text, covered, err := s.llmService.UpdateSummary(ctx, summary.Summary, transcript, prompt)
+			if err != nil {
+				return summary, added, err
+			}
+			summary.Summary = text
+			if covered < len(transcript) {
+				last = sources[covered-1]
+			}
+		}
+
+		summary.LastMessageID = messages[last].ID
+		summary.MessageCount += last + 1
+		added += last + 1
+
+		if err := database.DB.Save(summary).Error; err != nil {
+			return summary, added, err
+		}
+	}
+}
+
+// RefreshActiveSummaries 刷新新消息数达到 minNewMessages 的总结，返回刷新的数量
+// 只刷新已有的总结（Operator 第一次请求时创建），最久未更新的优先
+// 新消息只计算第一条等待扫描的文件消息之前的部分，与 Refresh 能处理的范围一致
+func (s *ChatSummaryService) RefreshActiveSummaries(ctx context.Context, minNewMessages int) (int, error) {
+	var summaries []models.ChatSummary
+	err := database.DB.
+		Where(`(SELECT COUNT(*) FROM messages WHERE messages.chat_id = chat_summaries.chat_id AND messages.id > chat_summaries.last_message_id AND messages.deleted_at IS NULL
+			AND messages.id < COALESCE((SELECT MIN(cf.message_id) FROM chat_files cf JOIN messages fm ON fm.id = cf.message_id AND fm.deleted_at IS NULL
+				WHERE cf.chat_id = chat_summaries.chat_id AND cf.message_id > chat_summaries.last_message_id AND cf.scan_status IN ?), ~0)) >= ?`,
+			[]string{ScanStatusPending, ScanStatusFailed}, minNewMessages).
+		Order("updated_at ASC").
+		Limit(summaryRefreshPerRun).
+		Find(&summaries).Error
+	if err != nil {
+		return 0, err
+	}
+
+	refreshed := 0
+	for _, summary := range summaries {
+		prompt, _, err := RenderPrompt("summarize", 0, summary.ChatID, summary.Language)
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to render summary prompt of chat %d", summary.ChatID)
+			continue
+		}
+		if _, _, err := s.Refresh(ctx, summary.ChatID, summary.Language, prompt); err != nil {
+			logrus.WithError(err).Warnf("Failed to refresh summary of chat %d (%s)", summary.ChatID, summary.Language)
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// StartSummaryRefresher 定期刷新有足够新消息的聊天室总结
+func StartSummaryRefresher(interval time.Duration, minNewMessages int) {
+	service := NewChatSummaryService(NewLLMService())
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			count, err := service.RefreshActiveSummaries(context.Background(), minNewMessages)
+			if err != nil {
+				logrus.WithError(err).Error("Failed to refresh chat summaries")
+				continue
+			}
+			if count > 0 {
+				logrus.Infof("Refreshed %d chat summaries", count)
+			}
+		}
+	}()
+}
+
+// lockSummary 获取总结锁，返回解锁函数
+func lockSummary(chatID uint, language string) func() {
+	value, _ := summaryLocks.LoadOrStore(fmt.Sprintf("%d:%s", chatID, language), &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
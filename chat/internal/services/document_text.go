@@ -0,0 +1,547 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 文本提取相关错误
+var (
+	ErrUnsupportedDocument = errors.New("unsupported file type")
+	ErrNoExtractableText   = errors.New("no extractable text")
+)
+
+// maxExtractedText 单个文件最多提取的文本字节数
+const maxExtractedText = 2 * 1024 * 1024
+
+// plainTextExtensions 按纯文本读取的扩展名
+var plainTextExtensions = map[string]bool{
+	".txt": true, ".md": true, ".csv": true, ".log": true, ".json": true, ".xml": true,
+}
+
+// ExtractText 提取文件的文本内容，支持 PDF、DOCX 和纯文本
+func (s *FileService) ExtractText(chatFile *models.ChatFile) (string, error) {
+	filePath := s.GetFilePath(chatFile.FileURL)
+	ext := strings.ToLower(filepath.Ext(chatFile.FileName))
+
+	var text string
+	var err error
+	switch {
+	case ext == ".pdf":
+		text, err = extractPDFText(filePath)
+	case ext == ".docx":
+		text, err = extractDOCXText(filePath)
+	case plainTextExtensions[ext]:
+		text, err = extractPlainText(filePath)
+	default:
+		return "", ErrUnsupportedDocument
+	}
+	if err != nil {
+		return "", err
+	}
+
+	text = normalizeExtractedText(text)
+	if text == "" {
+		return "", ErrNoExtractableText
+	}
+	return text, nil
+}
+
+// GetChatFilesByIDs 获取聊天室中指定ID的文件（排除已删除消息和未通过扫描的文件）
+func (s *FileService) GetChatFilesByIDs(chatID uint, fileIDs []uint) ([]models.ChatFile, error) {
+	var files []models.ChatFile
+	err := database.DB.
+		Joins("JOIN messages ON messages.id = chat_files.message_id AND messages.deleted_at IS NULL").
+		Where("chat_files.chat_id = ? AND chat_files.id IN ? AND chat_files.scan_status = ?", chatID, fileIDs, ScanStatusClean).
+		Find(&files).Error
+	return files, err
+}
+
+// GetRecentDocuments 获取聊天室最近的文档文件
+func (s *FileService) GetRecentDocuments(chatID uint, limit int) ([]models.ChatFile, error) {
+	var files []models.ChatFile
+	err := database.DB.
+		Joins("JOIN messages ON messages.id = chat_files.message_id AND messages.deleted_at IS NULL").
+		Where("chat_files.chat_id = ? AND chat_files.file_type = ? AND chat_files.scan_status = ?", chatID, "document", ScanStatusClean).
+		Order("chat_files.created_at DESC").
+		Limit(limit).
+		Find(&files).Error
+	return files, err
+}
+
+// extractPlainText 读取纯文本文件
+func extractPlainText(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxExtractedText))
+	if err != nil {
+		return "", err
+	}
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("file is not valid UTF-8 text")
+	}
+	return string(data), nil
+}
+
+// extractDOCXText 从 word/document.xml 中提取段落文本
+func extractDOCXText(path string) (string, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid docx archive: %w", err)
+	}
+	defer archive.Close()
+
+	var document *zip.File
+	for _, f := range archive.File {
+		if f.Name == "word/document.xml" {
+			document = f
+			break
+		}
+	}
+	if document == nil {
+		return "", fmt.Errorf("word/document.xml not found")
+	}
+
+	rc, err := document.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var sb strings.Builder
+	decoder := xml.NewDecoder(io.LimitReader(rc, 64*1024*1024))
+	inText := false
+	for sb.Len() < maxExtractedText {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid document.xml: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br", "cr":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// extractPDFText 提取 PDF 文本：优先使用 pdftotext（poppler），不可用时使用内置解析器
+// 内置解析器只支持标准编码的字体，使用内嵌 CID 字体的文件需要安装 pdftotext
+func extractPDFText(path string) (string, error) {
+	if _, err := exec.LookPath("pdftotext"); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var stdout bytes.Buffer
+		cmd := exec.CommandContext(ctx, "pdftotext", "-layout", "-enc", "UTF-8", path, "-")
+		cmd.Stdout = &stdout
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("pdftotext failed: %w", err)
+		}
+		return stdout.String(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", fmt.Errorf("not a PDF file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", fmt.Errorf("encrypted PDF is not supported")
+	}
+
+	text := extractPDFContentText(data)
+	if !looksLikeText(text) {
+		return "", ErrNoExtractableText
+	}
+	return text, nil
+}
+
+// extractPDFContentText 遍历所有流对象，解析其中的文本绘制指令
+func extractPDFContentText(data []byte) string {
+	var sb strings.Builder
+
+	for pos := 0; pos < len(data) && sb.Len() < maxExtractedText; {
+		idx := bytes.Index(data[pos:], []byte("stream"))
+		if idx < 0 {
+			break
+		}
+		start := pos + idx
+		pos = start + len("stream")
+
+		// 跳过 endstream
+		if start >= 3 && string(data[start-3:start]) == "end" {
+			continue
+		}
+
+		// 流数据从 stream 关键字后的换行开始
+		body := pos
+		if body < len(data) && data[body] == '\r' {
+			body++
+		}
+		if body < len(data) && data[body] == '\n' {
+			body++
+		}
+
+		end := bytes.Index(data[body:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		streamData := data[body : body+end]
+		pos = body + end + len("endstream")
+
+		// 流字典位于 stream 关键字之前的最近一个 obj 之后
+		dictStart := bytes.LastIndex(data[:start], []byte("obj"))
+		if dictStart < 0 {
+			continue
+		}
+		dict := data[dictStart:start]
+
+		// 跳过图片、字体等非内容流
+		if bytes.Contains(dict, []byte("/Image")) || bytes.Contains(dict, []byte("/Length1")) ||
+			bytes.Contains(dict, []byte("/Type /XRef")) || bytes.Contains(dict, []byte("/Type/XRef")) ||
+			bytes.Contains(dict, []byte("/ObjStm")) {
+			continue
+		}
+
+		content := streamData
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			r, err := zlib.NewReader(bytes.NewReader(streamData))
+			if err != nil {
+				continue
+			}
+			decoded, _ := io.ReadAll(io.LimitReader(r, 32*1024*1024))
+			r.Close()
+			content = decoded
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+
+		if bytes.Contains(content, []byte("BT")) {
+			parsePDFTextOperators(content, &sb)
+		}
+	}
+
+	return sb.String()
+}
+
+// parsePDFTextOperators 解析内容流中的 Tj/TJ/'/" 文本指令
+func parsePDFTextOperators(content []byte, sb *strings.Builder) {
+	var operands []string
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, next := readPDFLiteralString(content, i)
+			operands = append(operands, s)
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			s, next := readPDFHexString(content, i)
+			operands = append(operands, s)
+			i = next
+		case c == '[':
+			// TJ 数组：字符串之间的大幅负偏移通常表示空格
+			var parts strings.Builder
+			i++
+			for i < len(content) && content[i] != ']' {
+				switch {
+				case content[i] == '(':
+					s, next := readPDFLiteralString(content, i)
+					parts.WriteString(s)
+					i = next
+				case content[i] == '<':
+					s, next := readPDFHexString(content, i)
+					parts.WriteString(s)
+					i = next
+				case content[i] == '-' || (content[i] >= '0' && content[i] <= '9') || content[i] == '.':
+					j := i
+					for j < len(content) && (content[j] == '-' || content[j] == '.' || (content[j] >= '0' && content[j] <= '9')) {
+						j++
+					}
+					var n float64
+					fmt.Sscanf(string(content[i:j]), "%g", &n)
+					if n < -200 {
+						parts.WriteString(" ")
+					}
+					i = j
+				default:
+					i++
+				}
+			}
+			operands = append(operands, parts.String())
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case isPDFRegular(c) && !(c >= '0' && c <= '9') && c != '-' && c != '.' && c != '/':
+			j := i
+			for j < len(content) && isPDFRegular(content[j]) {
+				j++
+			}
+			op := string(content[i:j])
+			i = j
+
+			switch op {
+			case "Tj", "TJ":
+				if len(operands) > 0 {
+					sb.WriteString(operands[len(operands)-1])
+				}
+			case "'", "\"":
+				sb.WriteString("\n")
+				if len(operands) > 0 {
+					sb.WriteString(operands[len(operands)-1])
+				}
+			case "T*", "ET":
+				sb.WriteString("\n")
+			case "Td", "TD":
+				sb.WriteString(" ")
+			}
+			operands = operands[:0]
+		default:
+			i++
+		}
+	}
+}
+
+// isPDFRegular 是否为 PDF 普通字符（非空白、非分隔符）
+func isPDFRegular(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return false
+	}
+	return true
+}
+
+// readPDFLiteralString 读取 (...) 字符串，处理嵌套括号和转义
+func readPDFLiteralString(content []byte, start int) (string, int) {
+	var buf []byte
+	depth := 0
+	i := start
+	for i < len(content) {
+		c := content[i]
+		switch {
+		case c == '\\' && i+1 < len(content):
+			i++
+			switch e := content[i]; e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// 行尾续行
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					for k := 0; k < 3 && i < len(content) && content[i] >= '0' && content[i] <= '7'; k++ {
+						v = v*8 + int(content[i]-'0')
+						i++
+					}
+					buf = append(buf, byte(v))
+					continue
+				}
+				buf = append(buf, e)
+			}
+		case c == '(':
+			if depth > 0 {
+				buf = append(buf, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return decodePDFString(buf), i + 1
+			}
+			buf = append(buf, c)
+		default:
+			buf = append(buf, c)
+		}
+		i++
+	}
+	return decodePDFString(buf), i
+}
+
+// readPDFHexString 读取 <...> 十六进制字符串
+func readPDFHexString(content []byte, start int) (string, int) {
+	end := bytes.IndexByte(content[start:], '>')
+	if end < 0 {
+		return "", len(content)
+	}
+
+	var buf []byte
+	var hi, n int
+	for _, c := range content[start+1 : start+end] {
+		var v int
+		switch {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c >= 'a' && c <= 'f':
+			v = int(c-'a') + 10
+		case c >= 'A' && c <= 'F':
+			v = int(c-'A') + 10
+		default:
+			continue
+		}
+		if n%2 == 0 {
+			hi = v
+		} else {
+			buf = append(buf, byte(hi<<4|v))
+		}
+		n++
+	}
+	if n%2 == 1 {
+		buf = append(buf, byte(hi<<4))
+	}
+
+	return decodePDFString(buf), start + end + 1
+}
+
+// decodePDFString 解码字符串：UTF-16BE（带 BOM）或按 Latin-1 处理
+func decodePDFString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		var runes []rune
+		for i := 2; i+1 < len(b); i += 2 {
+			runes = append(runes, rune(b[i])<<8|rune(b[i+1]))
+		}
+		return string(runes)
+	}
+
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// looksLikeText 判断提取结果是否为可读文本（内嵌字体编码通常得到乱码）
+func looksLikeText(text string) bool {
+	var total, readable int
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsPunct(r) {
+			readable++
+		}
+	}
+	return total > 0 && readable*10 >= total*8
+}
+
+// normalizeExtractedText 合并多余的空白行并截断过长的文本
+func normalizeExtractedText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ToValidUTF8(text, "")
+
+	lines := strings.Split(text, "\n")
+	var out []string
+	blank := 0
+	for _, line := range lines {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		out = append(out, line)
+	}
+
+	text = strings.TrimSpace(strings.Join(out, "\n"))
+	if len(text) > maxExtractedText {
+		text = strings.ToValidUTF8(text[:maxExtractedText], "")
+	}
+	return text
+}
+
+// EstimateTokens 粗略估算文本的 token 数（约 3 个字符一个 token，对西里尔文和中文偏保守）
+func EstimateTokens(text string) int {
+	return utf8.RuneCountInString(text)/3 + 1
+}
+
+// ChunkText 按段落把文本切分为不超过 maxTokens 的片段，超长段落按字符切分
+func ChunkText(text string, maxTokens int) []string {
+	if maxTokens <= 0 || EstimateTokens(text) <= maxTokens {
+		return []string{text}
+	}
+
+	maxRunes := maxTokens * 3
+	var chunks []string
+	var current strings.Builder
+
+	flush := func() {
+		if strings.TrimSpace(current.String()) != "" {
+			chunks = append(chunks, strings.TrimSpace(current.String()))
+		}
+		current.Reset()
+	}
+
+	for _, paragraph := range strings.Split(text, "\n") {
+		runes := []rune(paragraph)
+		for len(runes) > maxRunes {
+			flush()
+			chunks = append(chunks, string(runes[:maxRunes]))
+			runes = runes[maxRunes:]
+		}
+
+		if utf8.RuneCountInString(current.String())+len(runes)+1 > maxRunes {
+			flush()
+		}
+		current.WriteString(string(runes))
+		current.WriteString("\n")
+	}
+	flush()
+
+	return chunks
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"kelisim-chat/internal/config"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EmbeddingProvider turns texts into vectors for semantic search
+type EmbeddingProvider interface {
+	// Name identifies the provider in logs
+	Name() string
+	// Model identifies the vector space; vectors of different models are never compared
+	Model() string
+	// Embed returns one vector per text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbeddingProvider creates the provider selected by EMBEDDING_PROVIDER.
+// Texts sent to the openai and ollama providers are masked according to PII_REDACT, like LLM requests.
+func NewEmbeddingProvider() EmbeddingProvider {
+	cfg := config.AppConfig.Embedding
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	redact := ParsePIITypes(config.AppConfig.LLM.PIIRedact)
+
+	switch cfg.Provider {
+	case "openai":
+		return newRedactingEmbeddingProvider(&OpenAIEmbeddingProvider{
+			apiKey:     cfg.APIKey,
+			apiBase:    firstNonEmpty(cfg.APIBase, "https://api.openai.com/v1"),
+			model:      firstNonEmpty(cfg.Model, "text-embedding-3-small"),
+			httpClient: &http.Client{Timeout: timeout},
+		}, redact)
+	case "ollama":
+		return newRedactingEmbeddingProvider(&OllamaEmbeddingProvider{
+			apiBase:    firstNonEmpty(cfg.APIBase, config.AppConfig.LLM.Ollama.APIBase),
+			model:      firstNonEmpty(cfg.Model, "nomic-embed-text"),
+			httpClient: &http.Client{Timeout: timeout},
+		}, redact)
+	case "local", "":
+		return NewLocalEmbeddingProvider(cfg.Dimensions)
+	default:
+		logrus.WithField("provider", cfg.Provider).Warn("Unknown embedding provider, falling back to local")
+		return NewLocalEmbeddingProvider(cfg.Dimensions)
+	}
+}
+
+// redactingEmbeddingProvider masks personal data before the texts leave the server.
+// Indexed texts and search questions go through the same masking, so a question that
+// mentions a phone number still finds messages with a phone number.
+type redactingEmbeddingProvider struct {
+	EmbeddingProvider
+	types map[PIIType]bool
+}
+
+// newRedactingEmbeddingProvider wraps provider, or returns it unchanged when redaction is off
+func newRedactingEmbeddingProvider(provider EmbeddingProvider, types map[PIIType]bool) EmbeddingProvider {
+	if !NewRedactor(types).Enabled() {
+		return provider
+	}
+	return &redactingEmbeddingProvider{EmbeddingProvider: provider, types: types}
+}
+
+// Embed masks every text and embeds the masked texts
+func (p *redactingEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	masked := make([]string, len(texts))
+	for i, text := range texts {
+		masked[i] = NewRedactor(p.types).Redact(text)
+	}
+	return p.EmbeddingProvider.Embed(ctx, masked)
+}
+
+// LocalEmbeddingProvider hashes words and their character trigrams into a fixed-size vector.
+// It needs no external service and always returns the same vector for the same text, which
+// makes it suitable for tests and small installations. Trigrams let inflected forms
+// ("земля", "земельный") land close to each other; it does not understand synonyms.
+type LocalEmbeddingProvider struct {
+	dimensions int
+}
+
+// NewLocalEmbeddingProvider creates a local provider with the given number of dimensions
+func NewLocalEmbeddingProvider(dimensions int) *LocalEmbeddingProvider {
+	if dimensions <= 0 {
+		dimensions = 512
+	}
+	return &LocalEmbeddingProvider{dimensions: dimensions}
+}
+
+// Name returns the provider name
+func (p *LocalEmbeddingProvider) Name() string {
+	return "local"
+}
+
+// Model returns the model name, which includes the dimensions
+func (p *LocalEmbeddingProvider) Model() string {
+	return fmt.Sprintf("local-hash-%d", p.dimensions)
+}
+
+// Embed hashes every text
+func (p *LocalEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = p.embed(text)
+	}
+	return vectors, nil
+}
+
+func (p *LocalEmbeddingProvider) embed(text string) []float32 {
+	vector := make([]float32, p.dimensions)
+	add := func(feature string, weight float32) {
+		h := fnv.New32a()
+		h.Write([]byte(feature))
+		sum := h.Sum32()
+		// The top bit picks the sign so unrelated features cancel out instead of piling up
+		if sum&0x80000000 != 0 {
+			weight = -weight
+		}
+		vector[int(sum%uint32(p.dimensions))] += weight
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		add("w:"+word, 1)
+		runes := []rune("^" + word + "$")
+		for i := 0; i+3 <= len(runes); i++ {
+			add("t:"+string(runes[i:i+3]), 0.5)
+		}
+	}
+
+	normalizeVector(vector)
+	return vector
+}
+
+// OpenAIEmbeddingProvider calls an OpenAI-compatible /embeddings endpoint
+type OpenAIEmbeddingProvider struct {
+	apiKey     string
+	apiBase    string
+	model      string
+	httpClient *http.Client
+}
+
+// Name returns the provider name
+func (p *OpenAIEmbeddingProvider) Name() string {
+	return "openai"
+}
+
+// Model returns the embedding model
+func (p *OpenAIEmbeddingProvider) Model() string {
+	return p.model
+}
+
+// Embed sends all texts in one /embeddings request
+func (p *OpenAIEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if p.apiKey == "" {
+		return nil, errors.New("EMBEDDING_API_KEY is not configured")
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	payload := map[string]interface{}{"model": p.model, "input": texts}
+	if err := postEmbeddingJSON(ctx, p.httpClient, p.Name(), p.apiBase+"/embeddings", p.apiKey, payload, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// OllamaEmbeddingProvider calls the /api/embed endpoint of a local Ollama server
+type OllamaEmbeddingProvider struct {
+	apiBase    string
+	model      string
+	httpClient *http.Client
+}
+
+// Name returns the provider name
+func (p *OllamaEmbeddingProvider) Name() string {
+	return "ollama"
+}
+
+// Model returns the embedding model
+func (p *OllamaEmbeddingProvider) Model() string {
+	return p.model
+}
+
+// Embed sends all texts in one /api/embed request
+func (p *OllamaEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	payload := map[string]interface{}{"model": p.model, "input": texts}
+	if err := postEmbeddingJSON(ctx, p.httpClient, p.Name(), p.apiBase+"/api/embed", "", payload, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Embeddings))
+	}
+	return result.Embeddings, nil
+}
+
+// postEmbeddingJSON posts payload to url and decodes the JSON response into out
+func postEmbeddingJSON(ctx context.Context, client *http.Client, provider string, url string, apiKey string, payload interface{}, out interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to prepare request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(url, "/"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return newTransportError(ctx, provider, fmt.Errorf("embedding request failed: %w", err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return newTransportError(ctx, provider, fmt.Errorf("failed to read response: %w", err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPError(provider, resp, fmt.Errorf("embedding request failed with status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// normalizeVector scales v to unit length so the dot product is the cosine similarity
+func normalizeVector(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"kelisim-chat/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRemoteEmbeddingProviderRedactsInput(t *testing.T) {
+	var input []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		input = payload.Input
+
+		data := make([]map[string]interface{}, len(payload.Input))
+		for i := range payload.Input {
+			data[i] = map[string]interface{}{"index": i, "embedding": []float32{1, 0}}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+
+	config.AppConfig = &config.Config{
+		LLM:       config.LLMConfig{PIIRedact: "all"},
+		Embedding: config.EmbeddingConfig{Provider: "openai", APIKey: "test", APIBase: server.URL, Timeout: 5},
+	}
+	provider := NewEmbeddingProvider()
+
+	texts := []string{"звоните +7 (701) 123-45-67", "ИИН 900101300017, телефон 87011234567"}
+	if _, err := provider.Embed(context.Background(), texts); err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	want := []string{"звоните [PHONE_1]", "ИИН [IIN_1], телефон [PHONE_1]"}
+	if !reflect.DeepEqual(input, want) {
+		t.Fatalf("sent %q, want %q", input, want)
+	}
+
+	config.AppConfig.LLM.PIIRedact = "none"
+	if _, err := NewEmbeddingProvider().Embed(context.Background(), texts); err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if !reflect.DeepEqual(input, texts) {
+		t.Fatalf("sent %q with redaction off, want the original texts", input)
+	}
+}
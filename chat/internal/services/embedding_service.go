@@ -0,0 +1,446 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"math"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 向量来源
+const (
+	EmbeddingSourceMessage = "message"
+	EmbeddingSourceFile    = "file"
+)
+
+const (
+	embeddingBatchSize       = 32   // 每次请求向量化的文本数
+	embeddingMessagesPerRun  = 500  // 每轮最多索引的消息数
+	embeddingFilesPerRun     = 20   // 每轮最多索引的文件数
+	embeddingFileChunkTokens = 300  // 文件文本按此大小切片
+	embeddingMaxFileChunks   = 50   // 每个文件最多索引的片段数
+	embeddingMaxTextRunes    = 2000 // 单条消息最多索引的字符数
+	embeddingScanBatch       = 1000 // 检索时每批读取的向量数
+)
+
+// SearchOptions 检索条件
+type SearchOptions struct {
+	TopK   int       // 返回的片段数
+	ChatID uint      // 0 表示全部聊天室
+	From   time.Time // 包含，零值表示不限
+	To     time.Time // 不包含，零值表示不限
+}
+
+// SearchHit 检索到的片段
+type SearchHit struct {
+	ChatID     uint      `json:"chat_id"`
+	ChatTitle  string    `json:"chat_title"`
+	MessageID  uint      `json:"message_id"`
+	FileID     *uint     `json:"file_id,omitempty"`
+	FileName   string    `json:"file_name,omitempty"`
+	Source     string    `json:"source"` // message 或 file
+	SenderName string    `json:"sender_name,omitempty"`
+	SenderType string    `json:"sender_type,omitempty"`
+	Content    string    `json:"content"`
+	Score      float64   `json:"score"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// EmbeddingService 消息向量索引和跨聊天检索
+// 向量保存在 MySQL，检索时按模型和时间过滤后在内存中计算相似度
+type EmbeddingService struct {
+	provider    EmbeddingProvider
+	fileService *FileService
+}
+
+// NewEmbeddingService 创建向量索引服务
+func NewEmbeddingService(provider EmbeddingProvider) *EmbeddingService {
+	return &EmbeddingService{
+		provider:    provider,
+		fileService: NewFileService(),
+	}
+}
+
+// Model 当前使用的向量模型
+func (s *EmbeddingService) Model() string {
+	return s.provider.Model()
+}
+
+// IndexPendingMessages 为尚未索引的文本消息生成向量，返回索引的数量
+func (s *EmbeddingService) IndexPendingMessages(ctx context.Context) (int, error) {
+	var messages []models.Message
+	err := database.DB.
+		Where("type = ? AND content IS NOT NULL AND content <> ''", "text").
+		Where("NOT EXISTS (SELECT 1 FROM message_embeddings e WHERE e.message_id = messages.id AND e.source = ? AND e.model = ?)", EmbeddingSourceMessage, s.Model()).
+		Order("id ASC").
+		Limit(embeddingMessagesPerRun).
+		Find(&messages).Error
+	if err != nil {
+		return 0, err
+	}
+
+	rows := make([]models.MessageEmbedding, 0, len(messages))
+	for _, message := range messages {
+		rows = append(rows, models.MessageEmbedding{
+			ChatID:           message.ChatID,
+			MessageID:        message.ID,
+			Source:           EmbeddingSourceMessage,
+			Content:          truncateRunes(*message.Content, embeddingMaxTextRunes),
+			MessageCreatedAt: message.CreatedAt,
+		})
+	}
+
+	if err := s.embedAndSave(ctx, rows, nil); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// IndexPendingFiles 提取尚未索引的文档文本，按片段生成向量，返回索引的文件数
+// 无法提取文本的文件保存一条空记录，之后不再重试
+func (s *EmbeddingService) IndexPendingFiles(ctx context.Context) (int, error) {
+	var files []models.ChatFile
+	err := database.DB.
+		Joins("JOIN messages ON messages.id = chat_files.message_id AND messages.deleted_at IS NULL").
+		Where("chat_files.file_type = ? AND chat_files.scan_status = ?", "document", ScanStatusClean).
+		Where("NOT EXISTS (SELECT 1 FROM message_embeddings e WHERE e.message_id = chat_files.message_id AND e.source = ? AND e.model = ?)", EmbeddingSourceFile, s.Model()).
+		Order("chat_files.id ASC").
+		Limit(embeddingFilesPerRun).
+		Find(&files).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, file := range files {
+		fileID := file.ID
+		text, err := s.fileService.ExtractText(&file)
+		if err != nil {
+			logrus.WithError(err).Debugf("No text to index in file %d", file.ID)
+			empty := []models.MessageEmbedding{{
+				ChatID:           file.ChatID,
+				MessageID:        file.MessageID,
+				FileID:           &fileID,
+				Source:           EmbeddingSourceFile,
+				MessageCreatedAt: file.CreatedAt,
+			}}
+			if err := s.save(empty); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		chunks := ChunkText(text, embeddingFileChunkTokens)
+		if len(chunks) > embeddingMaxFileChunks {
+			chunks = chunks[:embeddingMaxFileChunks]
+		}
+
+		rows := make([]models.MessageEmbedding, 0, len(chunks))
+		inputs := make([]string, 0, len(chunks))
+		for i, chunk := range chunks {
+			rows = append(rows, models.MessageEmbedding{
+				ChatID:           file.ChatID,
+				MessageID:        file.MessageID,
+				FileID:           &fileID,
+				Source:           EmbeddingSourceFile,
+				ChunkIndex:       i,
+				Content:          chunk,
+				MessageCreatedAt: file.CreatedAt,
+			})
+			// 文件名通常说明了文件内容，和片段一起向量化
+			inputs = append(inputs, file.FileName+"\n"+chunk)
+		}
+		if err := s.embedAndSave(ctx, rows, inputs); err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
+
+// IndexPending 索引所有新消息和新文件，直到没有待索引的内容
+func (s *EmbeddingService) IndexPending(ctx context.Context) (int, int, error) {
+	messageCount := 0
+	for {
+		n, err := s.IndexPendingMessages(ctx)
+		messageCount += n
+		if err != nil {
+			return messageCount, 0, err
+		}
+		if n < embeddingMessagesPerRun {
+			break
+		}
+	}
+
+	fileCount := 0
+	for {
+		n, err := s.IndexPendingFiles(ctx)
+		fileCount += n
+		if err != nil {
+			return messageCount, fileCount, err
+		}
+		if n < embeddingFilesPerRun {
+			break
+		}
+	}
+	return messageCount, fileCount, nil
+}
+
+// Search 按语义相似度检索消息和文件片段，返回得分最高的 TopK 条
+func (s *EmbeddingService) Search(ctx context.Context, query string, opts SearchOptions) ([]SearchHit, error) {
+	if opts.TopK <= 0 {
+		opts.TopK = 10
+	}
+
+	vectors, err := s.provider.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	queryVector := vectors[0]
+	normalizeVector(queryVector)
+
+	type scored struct {
+		row   models.MessageEmbedding
+		score float64
+	}
+	var top []scored
+
+	db := database.DB.WithContext(ctx).
+		Select("message_embeddings.id, message_embeddings.chat_id, message_embeddings.message_id, message_embeddings.file_id, "+
+			"message_embeddings.source, message_embeddings.content, message_embeddings.vector, message_embeddings.message_created_at").
+		Joins("JOIN messages ON messages.id = message_embeddings.message_id AND messages.deleted_at IS NULL").
+		Where("message_embeddings.model = ? AND message_embeddings.dimensions = ?", s.Model(), len(queryVector))
+	if opts.ChatID > 0 {
+		db = db.Where("message_embeddings.chat_id = ?", opts.ChatID)
+	}
+	if !opts.From.IsZero() {
+		db = db.Where("message_embeddings.message_created_at >= ?", opts.From)
+	}
+	if !opts.To.IsZero() {
+		db = db.Where("message_embeddings.message_created_at < ?", opts.To)
+	}
+
+	var batch []models.MessageEmbedding
+	result := db.FindInBatches(&batch, embeddingScanBatch, func(tx *gorm.DB, _ int) error {
+		for _, row := range batch {
+			score := dotProduct(queryVector, decodeVector(row.Vector))
+			if score <= 0 || (len(top) == opts.TopK && score <= top[len(top)-1].score) {
+				continue
+			}
+			row.Vector = nil
+			i := sort.Search(len(top), func(i int) bool { return top[i].score < score })
+			top = append(top, scored{})
+			copy(top[i+1:], top[i:])
+			top[i] = scored{row: row, score: score}
+			if len(top) > opts.TopK {
+				top = top[:opts.TopK]
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	hits := make([]SearchHit, len(top))
+	for i, item := range top {
+		hits[i] = SearchHit{
+			ChatID:    item.row.ChatID,
+			MessageID: item.row.MessageID,
+			FileID:    item.row.FileID,
+			Source:    item.row.Source,
+			Content:   item.row.Content,
+			Score:     math.Round(item.score*1000) / 1000,
+			CreatedAt: item.row.MessageCreatedAt,
+		}
+	}
+	if err := s.attachHitDetails(hits); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// attachHitDetails 补充聊天室标题、发送者和文件名
+func (s *EmbeddingService) attachHitDetails(hits []SearchHit) error {
+	if len(hits) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]uint, len(hits))
+	for i, hit := range hits {
+		messageIDs[i] = hit.MessageID
+	}
+
+	var messages []models.Message
+	err := database.DB.Preload("Sender").Preload("Chat").Preload("ChatFiles").Find(&messages, messageIDs).Error
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint]*models.Message, len(messages))
+	for i := range messages {
+		byID[messages[i].ID] = &messages[i]
+	}
+
+	for i := range hits {
+		message, ok := byID[hits[i].MessageID]
+		if !ok {
+			continue
+		}
+		hits[i].ChatTitle = message.Chat.Title
+		if message.Sender != nil {
+			hits[i].SenderName = message.Sender.GetFullName()
+			hits[i].SenderType = message.Sender.UserType
+		} else if message.Operator != nil {
+			hits[i].SenderName = message.Operator.Name
+			hits[i].SenderType = models.SenderTypeOperator
+		}
+		if hits[i].FileID != nil {
+			for _, file := range message.ChatFiles {
+				if file.ID == *hits[i].FileID {
+					hits[i].FileName = file.FileName
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// embedAndSave 分批生成向量并保存，inputs 为空时使用 rows 的 Content
+// 整批失败时逐条重试，单条仍失败的保存为空记录（dimensions = 0），之后不再重试，
+// 避免一条提供方拒绝的文本让索引一直停在同一批；全部失败时视为提供方不可用，返回错误
+func (s *EmbeddingService) embedAndSave(ctx context.Context, rows []models.MessageEmbedding, inputs []string) error {
+	for start := 0; start < len(rows); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		texts := make([]string, 0, end-start)
+		for i := start; i < end; i++ {
+			if inputs != nil {
+				texts = append(texts, inputs[i])
+			} else {
+				texts = append(texts, rows[i].Content)
+			}
+		}
+
+		vectors, err := s.provider.Embed(ctx, texts)
+		if err != nil {
+			vectors, err = s.embedEach(ctx, texts, err)
+			if err != nil {
+				return fmt.Errorf("%s embedding failed: %w", s.provider.Name(), err)
+			}
+		}
+		for i, vector := range vectors {
+			if vector == nil {
+				continue
+			}
+			normalizeVector(vector)
+			rows[start+i].Vector = encodeVector(vector)
+			rows[start+i].Dimensions = len(vector)
+		}
+
+		if err := s.save(rows[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// embedEach 逐条生成向量，失败的位置为 nil；全部失败或 ctx 已取消时返回错误
+func (s *EmbeddingService) embedEach(ctx context.Context, texts []string, batchErr error) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	failed := 0
+	for i, text := range texts {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		result, err := s.provider.Embed(ctx, []string{text})
+		if err == nil && len(result) != 1 {
+			err = fmt.Errorf("expected 1 vector, got %d", len(result))
+		}
+		if err != nil {
+			logrus.WithError(err).Warnf("Skipping text rejected by %s embedding", s.provider.Name())
+			failed++
+			continue
+		}
+		vectors[i] = result[0]
+	}
+	if failed == len(texts) {
+		return nil, batchErr
+	}
+	return vectors, nil
+}
+
+// save 保存向量，已存在的记录（并发索引）跳过
+func (s *EmbeddingService) save(rows []models.MessageEmbedding) error {
+	for i := range rows {
+		rows[i].Model = s.Model()
+	}
+	return database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// StartEmbeddingIndexer 定期索引新消息和新文件
+func StartEmbeddingIndexer(interval time.Duration) {
+	service := NewEmbeddingService(NewEmbeddingProvider())
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			messages, files, err := service.IndexPending(context.Background())
+			if err != nil {
+				logrus.WithError(err).Error("Failed to index messages for search")
+			}
+			if messages > 0 || files > 0 {
+				logrus.Infof("Indexed %d messages and %d files for search (%s)", messages, files, service.Model())
+			}
+		}
+	}()
+}
+
+// encodeVector 按 float32 小端序编码
+func encodeVector(vector []float32) []byte {
+	buf := make([]byte, 4*len(vector))
+	for i, x := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+// decodeVector 解码 encodeVector 的结果
+func decodeVector(buf []byte) []float32 {
+	vector := make([]float32, len(buf)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return vector
+}
+
+// dotProduct 单位向量的点积即余弦相似度，长度不同时返回 0
+func dotProduct(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// truncateRunes 截断到最多 n 个字符
+func truncateRunes(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n])
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// rejectingProvider fails any request that contains a rejected text
+type rejectingProvider struct {
+	rejected map[string]bool
+}
+
+func (p *rejectingProvider) Name() string  { return "test" }
+func (p *rejectingProvider) Model() string { return "test-model" }
+
+func (p *rejectingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if p.rejected[text] {
+			return nil, errors.New("input rejected")
+		}
+		vectors[i] = []float32{1, 0}
+	}
+	return vectors, nil
+}
+
+func TestEmbedEachSkipsRejectedTexts(t *testing.T) {
+	service := NewEmbeddingService(&rejectingProvider{rejected: map[string]bool{"bad": true}})
+	batchErr := errors.New("batch failed")
+
+	vectors, err := service.embedEach(context.Background(), []string{"bad", "good"}, batchErr)
+	if err != nil {
+		t.Fatalf("embedEach() error = %v", err)
+	}
+	if vectors[0] != nil || len(vectors[1]) != 2 {
+		t.Fatalf("vectors = %v, want only the second text embedded", vectors)
+	}
+
+	// Every text failing means the provider is down; nothing must be marked as skipped
+	if _, err := service.embedEach(context.Background(), []string{"bad"}, batchErr); !errors.Is(err, batchErr) {
+		t.Fatalf("embedEach() error = %v, want %v", err, batchErr)
+	}
+}
@@ -146,31 +146,8 @@ func (s *FCMService) SendChatMessageNotification(message *models.Message, recipi
 		return fmt.Errorf("message sender is nil")
 	}
 
-	senderName := message.Sender.GetFullName()
-	if senderName == "" {
-		if message.Sender.Email != nil {
-			senderName = *message.Sender.Email
-		} else {
-			senderName = "未知用户"
-		}
-	}
-
-	title := fmt.Sprintf("来自 %s 的新消息", senderName)
-	bodyText := ""
-
-	// 根据消息类型设置内容
-	switch message.Type {
-	case "text":
-		if message.Content != nil {
-			bodyText = *message.Content
-		}
-	case "image":
-		bodyText = "[图片]"
-	case "document":
-		bodyText = "[文档]"
-	default:
-		bodyText = "[消息]"
-	}
+	// 按聊天语言生成标题和内容
+	title, bodyText := chatMessageNotificationContent(message)
 
 	// 构建数据
 	data := map[string]string{
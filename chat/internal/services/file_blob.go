@@ -0,0 +1,218 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"kelisim-chat/internal/config"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StoreFile 按内容寻址保存文件，相同内容只保存一份
+// 每次调用都会为该内容增加一次引用，返回访问URL和 SHA-256
+// 尚未扫描通过的内容保存在待扫描目录，扫描通过后才移入静态文件目录，URL 在此之前无法访问
+// 引用计数的变更和文件的落盘在锁定 file_blobs 行的事务中完成，与其他实例上的释放互斥
+func (s *FileService) StoreFile(src io.Reader, originalName string) (string, string, error) {
+	tmpDir := filepath.Join(config.AppConfig.Scanner.PendingPath, "tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// 先写入临时文件，同时计算哈希
+	tmpPath := filepath.Join(tmpDir, s.generateFileName(originalName))
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create file: %w", err)
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dst, hasher), src)
+	dst.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", "", fmt.Errorf("failed to copy file: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	contentHash := hex.EncodeToString(hasher.Sum(nil))
+
+	// 内容寻址路径：blobs/ab/cd/<hash><ext>
+	ext := strings.ToLower(filepath.Ext(originalName))
+	if ext == "" {
+		ext = ".bin"
+	}
+	relativePath := filepath.Join("blobs", contentHash[0:2], contentHash[2:4], contentHash+ext)
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 新内容插入记录，已有内容只增加引用计数（同时锁定该行直到事务结束）
+	blob := models.FileBlob{
+		ContentHash: contentHash,
+		FilePath:    filepath.ToSlash(relativePath),
+		FileURL:     config.AppConfig.Storage.BaseURL + "/" + filepath.ToSlash(relativePath),
+		FileSize:    size,
+		RefCount:    1,
+	}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "content_hash"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("ref_count + 1"), "updated_at": time.Now()}),
+	}).Create(&blob).Error; err != nil {
+		tx.Rollback()
+		return "", "", err
+	}
+
+	// 以数据库中的记录为准（第一次上传时的扩展名）
+	current, err := lockBlob(tx, contentHash)
+	if err != nil {
+		tx.Rollback()
+		return "", "", err
+	}
+
+	// 落盘失败时回滚，引用计数不变
+	finalPath := blobFilePath(current)
+	if _, err := os.Stat(finalPath); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(finalPath), 0755); err != nil {
+			tx.Rollback()
+			return "", "", fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.Rename(tmpPath, finalPath); err != nil {
+			tx.Rollback()
+			return "", "", fmt.Errorf("failed to store file: %w", err)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return "", "", err
+	}
+
+	return current.FileURL, contentHash, nil
+}
+
+// lockBlob 在事务中锁定并读取文件实体（SELECT ... FOR UPDATE）
+// 引用计数、扫描状态和磁盘上文件的变更都在持有该行锁时进行
+func lockBlob(tx *gorm.DB, contentHash string) (*models.FileBlob, error) {
+	var blob models.FileBlob
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&blob, "content_hash = ?", contentHash).Error
+	return &blob, err
+}
+
+// blobFilePath 文件实体在磁盘上的路径：扫描通过的内容在静态文件目录下，其余在待扫描目录
+func blobFilePath(blob *models.FileBlob) string {
+	root := config.AppConfig.Storage.Path
+	if blob.ScanStatus != ScanStatusClean {
+		root = config.AppConfig.Scanner.PendingPath
+	}
+	return filepath.Join(root, filepath.FromSlash(blob.FilePath))
+}
+
+// pendingBlobFilePath 待扫描内容的路径；启用待扫描目录之前上传的内容仍在静态文件目录下
+func pendingBlobFilePath(blob *models.FileBlob) string {
+	pendingPath := filepath.Join(config.AppConfig.Scanner.PendingPath, filepath.FromSlash(blob.FilePath))
+	if _, err := os.Stat(pendingPath); os.IsNotExist(err) {
+		legacyPath := filepath.Join(config.AppConfig.Storage.Path, filepath.FromSlash(blob.FilePath))
+		if _, err := os.Stat(legacyPath); err == nil {
+			return legacyPath
+		}
+	}
+	return pendingPath
+}
+
+// GetBlobByURL 根据URL获取文件实体
+func (s *FileService) GetBlobByURL(fileURL string) (*models.FileBlob, error) {
+	var blob models.FileBlob
+	err := database.DB.Where("file_url = ?", fileURL).First(&blob).Error
+	return &blob, err
+}
+
+// ReleaseBlob 释放一次引用，没有引用时删除文件
+// 在锁定 file_blobs 行的事务中判断是否还有引用，其他实例不会在删除期间重新引用该内容
+func (s *FileService) ReleaseBlob(contentHash string) error {
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	blob, err := lockBlob(tx, contentHash)
+	if err != nil {
+		tx.Rollback()
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if blob.RefCount > 1 {
+		if err := tx.Model(&models.FileBlob{}).
+			Where("content_hash = ?", contentHash).
+			Update("ref_count", gorm.Expr("ref_count - 1")).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit().Error
+	}
+
+	if err := tx.Where("content_hash = ?", contentHash).Delete(&models.FileBlob{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	filePath := blobFilePath(blob)
+	if blob.ScanStatus == ScanStatusPending {
+		filePath = pendingBlobFilePath(blob)
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).Warnf("Failed to remove blob %s", contentHash)
+	}
+
+	// 同时删除视频封面
+	os.Remove(strings.TrimSuffix(filePath, filepath.Ext(filePath)) + "_poster.jpg")
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	logrus.Infof("Removed unreferenced blob %s", contentHash)
+	return nil
+}
+
+// ReleaseMessageFiles 释放消息关联的所有文件引用
+func (s *FileService) ReleaseMessageFiles(messageID uint) error {
+	var hashes []string
+	if err := database.DB.Model(&models.ChatFile{}).
+		Where("message_id = ? AND content_hash IS NOT NULL", messageID).
+		Pluck("content_hash", &hashes).Error; err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if err := s.ReleaseBlob(hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetChatFileByMessageID 根据消息ID获取文件记录
+func (s *FileService) GetChatFileByMessageID(messageID uint) (*models.ChatFile, error) {
+	var chatFile models.ChatFile
+	err := database.DB.Where("message_id = ?", messageID).First(&chatFile).Error
+	return &chatFile, err
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"kelisim-chat/internal/config"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 文件扫描状态
+const (
+	ScanStatusPending  = "pending_scan"
+	ScanStatusClean    = "clean"
+	ScanStatusInfected = "infected"
+	ScanStatusFailed   = "scan_failed"
+)
+
+// ScannedFilesCondition 消息查询条件：排除尚未通过病毒扫描的文件消息
+const ScannedFilesCondition = "NOT EXISTS (SELECT 1 FROM chat_files WHERE chat_files.message_id = messages.id AND chat_files.scan_status <> 'clean')"
+
+// 同 ScannedFilesCondition，但查看者自己上传的文件消息无论扫描状态都可见（参数为查看者的 user_id 或 operator_id）
+const (
+	ScannedOrOwnFilesCondition      = "(" + ScannedFilesCondition + " OR messages.sender_id = ?)"
+	ScannedOrOperatorFilesCondition = "(" + ScannedFilesCondition + " OR (messages.sender_id IS NULL AND messages.operator_id = ?))"
+)
+
+// ScanOutcome 文件扫描的处理结果
+type ScanOutcome struct {
+	Status        string
+	Signature     string
+	SystemMessage *models.Message // 文件被隔离时替代原消息的系统消息
+}
+
+// ScanChatFile 扫描文件记录对应的内容并更新状态
+// 干净的文件移入静态文件目录并变为可见；感染的文件移入隔离目录，原消息被删除并替换为系统消息
+func (s *FileService) ScanChatFile(chatFile *models.ChatFile) (*ScanOutcome, error) {
+	blob, err := s.GetBlobByURL(chatFile.FileURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blob: %w", err)
+	}
+
+	// 相同内容已经有结论时直接复用
+	status := blob.ScanStatus
+	var signature string
+	if status == ScanStatusPending {
+		start := time.Now()
+		result, err := s.scanner.Scan(pendingBlobFilePath(blob))
+		if err != nil {
+			s.updateScanStatus(chatFile, ScanStatusFailed)
+			return &ScanOutcome{Status: ScanStatusFailed}, fmt.Errorf("%s scan failed: %w", s.scanner.Name(), err)
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"scanner":      s.scanner.Name(),
+			"content_hash": blob.ContentHash,
+			"infected":     result.Infected,
+			"signature":    result.Signature,
+			"duration":     time.Since(start).String(),
+		}).Info("File scanned")
+
+		status = ScanStatusClean
+		if result.Infected {
+			status = ScanStatusInfected
+			signature = result.Signature
+		}
+	}
+
+	if status == ScanStatusClean {
+		if err := s.publishBlob(blob); err != nil {
+			s.updateScanStatus(chatFile, ScanStatusFailed)
+			return &ScanOutcome{Status: ScanStatusFailed}, err
+		}
+		if err := s.updateScanStatus(chatFile, ScanStatusClean); err != nil {
+			return nil, err
+		}
+		return &ScanOutcome{Status: ScanStatusClean}, nil
+	}
+
+	if err := s.quarantineBlob(blob); err != nil {
+		logrus.WithError(err).Errorf("Failed to quarantine blob %s", blob.ContentHash)
+	}
+
+	systemMessage, err := s.replaceInfectedMessage(chatFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScanOutcome{
+		Status:        ScanStatusInfected,
+		Signature:     signature,
+		SystemMessage: systemMessage,
+	}, nil
+}
+
+// GetFilesToRescan 按ID顺序获取 afterID 之后需要重新扫描的文件记录：扫描失败的，以及在 staleBefore 之前上传仍未扫描的（例如扫描期间服务重启）
+// 只包括消息未删除的文件
+func (s *FileService) GetFilesToRescan(afterID uint, staleBefore time.Time, limit int) ([]models.ChatFile, error) {
+	var files []models.ChatFile
+	err := database.DB.
+		Joins("JOIN messages ON messages.id = chat_files.message_id AND messages.deleted_at IS NULL").
+		Where("chat_files.id > ?", afterID).
+		Where("chat_files.scan_status = ? OR (chat_files.scan_status = ? AND chat_files.created_at < ?)", ScanStatusFailed, ScanStatusPending, staleBefore).
+		Order("chat_files.id ASC").
+		Limit(limit).
+		Find(&files).Error
+	return files, err
+}
+
+// updateScanStatus 更新文件记录的扫描状态
+func (s *FileService) updateScanStatus(chatFile *models.ChatFile, status string) error {
+	chatFile.ScanStatus = status
+	return database.DB.Model(&models.ChatFile{}).
+		Where("id = ?", chatFile.ID).
+		Update("scan_status", status).Error
+}
+
+// publishBlob 将扫描通过的内容从待扫描目录移入静态文件目录，并标记为 clean
+func (s *FileService) publishBlob(blob *models.FileBlob) error {
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 相同内容可能已由其他文件记录的扫描发布，也可能已被释放
+	current, err := lockBlob(tx, blob.ContentHash)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to load blob: %w", err)
+	}
+	if current.ScanStatus == ScanStatusClean {
+		return tx.Commit().Error
+	}
+
+	pendingPath := pendingBlobFilePath(current)
+	current.ScanStatus = ScanStatusClean
+	finalPath := blobFilePath(current)
+	if pendingPath != finalPath {
+		if err := os.MkdirAll(filepath.Dir(finalPath), 0755); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.Rename(pendingPath, finalPath); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to publish file: %w", err)
+		}
+	}
+
+	if err := tx.Model(&models.FileBlob{}).
+		Where("content_hash = ?", blob.ContentHash).
+		Update("scan_status", ScanStatusClean).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// quarantineBlob 将感染的内容移入隔离目录
+func (s *FileService) quarantineBlob(blob *models.FileBlob) error {
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	current, err := lockBlob(tx, blob.ContentHash)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to load blob: %w", err)
+	}
+
+	if err := tx.Model(&models.FileBlob{}).
+		Where("content_hash = ?", blob.ContentHash).
+		Update("scan_status", ScanStatusInfected).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	quarantineDir := config.AppConfig.Scanner.QuarantinePath
+	if err := os.MkdirAll(quarantineDir, 0700); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+
+	filePath := pendingBlobFilePath(current)
+	quarantinePath := filepath.Join(quarantineDir, filepath.Base(filePath))
+	if err := os.Rename(filePath, quarantinePath); err != nil && !os.IsNotExist(err) {
+		tx.Rollback()
+		return fmt.Errorf("failed to move file to quarantine: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// replaceInfectedMessage 删除感染文件的消息，释放引用，并创建系统消息说明原因
+func (s *FileService) replaceInfectedMessage(chatFile *models.ChatFile) (*models.Message, error) {
+	if err := s.updateScanStatus(chatFile, ScanStatusInfected); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Model(&models.Message{}).
+		Where("id = ?", chatFile.MessageID).
+		Update("deleted_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+
+	if chatFile.ContentHash != nil {
+		if err := s.ReleaseBlob(*chatFile.ContentHash); err != nil {
+			logrus.WithError(err).Warnf("Failed to release blob %s", *chatFile.ContentHash)
+		}
+	}
+
+	systemMessageData := map[string]interface{}{
+		"type": "file_quarantined",
+		"data": map[string]interface{}{
+			"message_id":  chatFile.MessageID,
+			"file_name":   chatFile.FileName,
+			"uploaded_by": chatFile.UploadedBy,
+		},
+	}
+	systemMessageJSON, _ := json.Marshal(systemMessageData)
+
+	return NewChatService().CreateSystemMessage(chatFile.ChatID, string(systemMessageJSON))
+}
@@ -4,7 +4,6 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
-	"io"
 	"kelisim-chat/internal/config"
 	"kelisim-chat/internal/database"
 	"kelisim-chat/internal/models"
@@ -12,63 +11,41 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 // FileService 文件服务
-type FileService struct{}
+type FileService struct {
+	scanner Scanner
+}
 
 // NewFileService 创建文件服务
 func NewFileService() *FileService {
-	return &FileService{}
+	return &FileService{
+		scanner: NewScanner(),
+	}
 }
 
-// UploadFile 上传文件
-func (s *FileService) UploadFile(file *multipart.FileHeader, chatID uint) (string, string, int64, error) {
+// UploadFile 上传文件，返回文件URL、原始文件名、大小和内容哈希
+// 新内容在 ScanChatFile 扫描通过前处于 pending_scan 状态
+func (s *FileService) UploadFile(file *multipart.FileHeader) (string, string, int64, string, error) {
 	// 检查文件大小
 	if file.Size > config.AppConfig.Storage.MaxFileSize {
-		return "", "", 0, fmt.Errorf("file size exceeds limit")
-	}
-
-	// 生成唯一文件名
-	fileName := s.generateFileName(file.Filename)
-
-	// 创建存储目录
-	year := time.Now().Format("2006")
-	month := time.Now().Format("01")
-	dir := filepath.Join(config.AppConfig.Storage.Path, "chats", fmt.Sprintf("%d", chatID), year, month)
-
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", "", 0, fmt.Errorf("failed to create directory: %w", err)
+		return "", "", 0, "", fmt.Errorf("file size exceeds limit")
 	}
 
-	// 文件路径
-	filePath := filepath.Join(dir, fileName)
-
 	// 打开上传的文件
 	src, err := file.Open()
 	if err != nil {
-		return "", "", 0, fmt.Errorf("failed to open uploaded file: %w", err)
+		return "", "", 0, "", fmt.Errorf("failed to open uploaded file: %w", err)
 	}
 	defer src.Close()
 
-	// 创建目标文件
-	dst, err := os.Create(filePath)
+	fileURL, contentHash, err := s.StoreFile(src, file.Filename)
 	if err != nil {
-		return "", "", 0, fmt.Errorf("failed to create file: %w", err)
-	}
-	defer dst.Close()
-
-	// 复制文件内容
-	if _, err := io.Copy(dst, src); err != nil {
-		return "", "", 0, fmt.Errorf("failed to copy file: %w", err)
+		return "", "", 0, "", err
 	}
 
-	// 生成访问URL
-	relativePath := filepath.Join("chats", fmt.Sprintf("%d", chatID), year, month, fileName)
-	fileURL := config.AppConfig.Storage.BaseURL + "/" + strings.ReplaceAll(relativePath, "\\", "/")
-
-	return fileURL, file.Filename, file.Size, nil
+	return fileURL, file.Filename, file.Size, contentHash, nil
 }
 
 // generateFileName 生成唯一文件名
@@ -121,8 +98,13 @@ func (s *FileService) FileExists(fileURL string) bool {
 	return !os.IsNotExist(err)
 }
 
-// DeleteFile 删除文件
+// DeleteFile 删除文件（去重存储的文件只释放一次引用，没有引用时才删除）
 func (s *FileService) DeleteFile(fileURL string) error {
+	blob, err := s.GetBlobByURL(fileURL)
+	if err == nil {
+		return s.ReleaseBlob(blob.ContentHash)
+	}
+
 	filePath := s.GetFilePath(fileURL)
 	return os.Remove(filePath)
 }
@@ -137,17 +119,39 @@ func (s *FileService) GetFileSize(fileURL string) (int64, error) {
 	return fileInfo.Size(), nil
 }
 
-// GetChatFiles 获取聊天室的文件列表
-func (s *FileService) GetChatFiles(chatID uint, limit int, offset int) ([]models.Message, error) {
+// SaveVideoMetadata 保存视频元数据到文件记录
+func (s *FileService) SaveVideoMetadata(messageID uint, meta *VideoMetadata) error {
+	if meta == nil {
+		return nil
+	}
+
+	return database.DB.Model(&models.ChatFile{}).
+		Where("message_id = ?", messageID).
+		Updates(map[string]interface{}{
+			"duration":   meta.Duration,
+			"width":      meta.Width,
+			"height":     meta.Height,
+			"poster_url": meta.PosterURL,
+		}).Error
+}
+
+// GetChatFiles 获取聊天室的文件列表，fileTypes 为空时返回所有文件类型
+func (s *FileService) GetChatFiles(chatID uint, fileTypes []string, limit int, offset int) ([]models.Message, error) {
 	var messages []models.Message
-	
-	err := database.DB.Where("chat_id = ? AND deleted_at IS NULL AND (type = ? OR type = ?) AND file_url IS NOT NULL", 
-		chatID, "image", "document").
+
+	if len(fileTypes) == 0 {
+		fileTypes = []string{"image", "document", "video"}
+	}
+
+	err := database.DB.Where("chat_id = ? AND deleted_at IS NULL AND type IN ? AND file_url IS NOT NULL",
+		chatID, fileTypes).
+		Where(ScannedFilesCondition).
 		Preload("Sender").
+		Preload("ChatFiles").
 		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&messages).Error
-	
+
 	return messages, err
-}
\ No newline at end of file
+}
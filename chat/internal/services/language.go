@@ -0,0 +1,129 @@
+package services
+
+import (
+	"kelisim-chat/internal/models"
+	"strings"
+	"unicode"
+)
+
+// SupportedLanguages are the languages AI features answer in
+var SupportedLanguages = map[string]string{
+	"ru": "Russian",
+	"kk": "Kazakh",
+	"en": "English",
+	"zh": "Chinese",
+}
+
+// kazakhLetters are Cyrillic letters used in Kazakh but not in Russian
+const kazakhLetters = "әғқңөұүһіӘҒҚҢӨҰҮҺІ"
+
+// stopWords are frequent short words that tell apart languages sharing a script
+var stopWords = map[string][]string{
+	"ru": {"и", "в", "не", "на", "что", "я", "с", "он", "как", "это", "по", "но", "за", "из", "у", "от", "так", "же", "для", "мы", "вы", "если", "нет", "да", "уже", "или", "ли", "бы", "мне", "есть", "был", "чтобы", "когда", "только", "здравствуйте", "привет", "добрый", "спасибо"},
+	"kk": {"және", "бұл", "мен", "сен", "сіз", "біз", "ол", "бар", "жоқ", "емес", "үшін", "керек", "деп", "пен", "бен", "да", "де", "ма", "ме", "ба", "бе", "па", "пе", "қалай", "не", "сәлеметсіз", "рахмет", "иә", "жане", "bul", "jáne", "jane", "men", "siz", "biz", "bar", "joq", "emes", "ushin", "úshin", "kerek", "rahmet", "salemetsiz", "sálemetsiz"},
+	"en": {"the", "and", "is", "are", "to", "of", "in", "it", "you", "that", "for", "on", "with", "this", "be", "have", "not", "we", "i", "my", "your", "can", "will", "do", "please", "thanks", "hello"},
+}
+
+// stopWordSets indexes stopWords for lookups
+var stopWordSets = func() map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(stopWords))
+	for language, words := range stopWords {
+		sets[language] = make(map[string]bool, len(words))
+		for _, word := range words {
+			sets[language][word] = true
+		}
+	}
+	return sets
+}()
+
+// DetectLanguage guesses the language of a text without any external service.
+// The script decides first: Han → zh, Cyrillic → ru or kk, Latin → en or kk (Kazakh Latin alphabet).
+// Within a script, stop words and Kazakh-specific letters decide between the candidates.
+// It returns "" when the text has no letters.
+func DetectLanguage(text string) string {
+	var han, cyrillic, latin int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	if han == 0 && cyrillic == 0 && latin == 0 {
+		return ""
+	}
+	if han >= cyrillic && han >= latin {
+		return "zh"
+	}
+
+	scores := map[string]int{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		for language, set := range stopWordSets {
+			if set[word] {
+				scores[language]++
+			}
+		}
+		// Words with Kazakh-specific letters count as Kazakh
+		if strings.ContainsAny(word, kazakhLetters) {
+			scores["kk"]++
+		}
+	}
+
+	if cyrillic >= latin {
+		if scores["kk"] > scores["ru"] {
+			return "kk"
+		}
+		return "ru"
+	}
+	if scores["kk"] > scores["en"] {
+		return "kk"
+	}
+	return "en"
+}
+
+// DominantLanguage returns the language most participant messages are written in,
+// or fallback if none of them has text
+func DominantLanguage(messages []models.Message, fallback string) string {
+	counts := map[string]int{}
+	best := ""
+	for _, msg := range messages {
+		if msg.SenderID == nil || msg.Content == nil || msg.Type == "system" {
+			continue
+		}
+		language := DetectLanguage(*msg.Content)
+		if language == "" {
+			continue
+		}
+		counts[language]++
+		if best == "" || counts[language] > counts[best] {
+			best = language
+		}
+	}
+
+	if best == "" {
+		return fallback
+	}
+	return best
+}
+
+// NormalizeLanguage maps a user locale such as "ru_RU", "kk-KZ" or "kz" to a supported
+// language code, or "" if the language is not supported
+func NormalizeLanguage(locale string) string {
+	language := strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		language = language[:i]
+	}
+	if language == "kz" {
+		language = "kk"
+	}
+	if _, ok := SupportedLanguages[language]; !ok {
+		return ""
+	}
+	return language
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// messageTokenOverhead approximates the tokens a chat message costs beyond its content (role, separators)
+const messageTokenOverhead = 4
+
+// ContextResult is a conversation history fitted into the prompt budget
+type ContextResult struct {
+	Messages   []ChatMessage // summary message (if any) followed by the recent messages, oldest first
+	Included   int           // history messages included verbatim
+	Summarized int           // older messages folded into the summary message
+	Omitted    int           // messages that fit neither verbatim nor into the summary
+	Tokens     int           // estimated tokens of Messages
+	Budget     int           // tokens available for the history
+}
+
+// ContextBudget returns the tokens available for history in a request whose other messages
+// (system prompt, question) are given: the model's context window minus the completion
+// MaxTokens and the other messages
+func (s *LLMService) ContextBudget(other ...ChatMessage) int {
+	cfg := s.backends[0].provider.Config()
+
+	budget := cfg.ContextWindow - cfg.MaxTokens - countPromptTokens(other)
+	if budget < 0 {
+		return 0
+	}
+	return budget
+}
+
+// BuildContext fits history (oldest first) into budget tokens. The most recent messages are kept
+// verbatim; when older messages do not fit, they are summarized into a single message.
+// If summarization fails the older messages are omitted.
+func (s *LLMService) BuildContext(ctx context.Context, history []ChatMessage, budget int) *ContextResult {
+	result := &ContextResult{Budget: budget}
+	if len(history) == 0 || budget <= 0 {
+		result.Omitted = len(history)
+		return result
+	}
+
+	// Everything fits
+	if total := countPromptTokens(history); total <= budget {
+		result.Messages = history
+		result.Included = len(history)
+		result.Tokens = total
+		return result
+	}
+
+	// Keep a quarter of the budget for the summary of older messages
+	summaryBudget := budget / 4
+	start, used := fitRecent(history, budget-summaryBudget)
+	recent := history[start:]
+	older := history[:start]
+
+	summary, summarized := s.summarizeHistory(ctx, older, summaryBudget)
+	if summary != "" {
+		summaryMessage := ChatMessage{
+			Role:    "system",
+			Content: "Summary of the earlier conversation:\n" + summary,
+		}
+		result.Messages = append(result.Messages, summaryMessage)
+		result.Tokens += countPromptTokens([]ChatMessage{summaryMessage})
+		result.Summarized = summarized
+	}
+
+	result.Messages = append(result.Messages, recent...)
+	result.Included = len(recent)
+	result.Tokens += used
+	result.Omitted = len(history) - result.Included - result.Summarized
+
+	return result
+}
+
+// summarizeHistory summarizes as many of the newest messages of older as fit into one
+// summarization request and returns the summary and the number of messages it covers
+func (s *LLMService) summarizeHistory(ctx context.Context, older []ChatMessage, summaryBudget int) (string, int) {
+	if len(older) == 0 || summaryBudget <= 0 {
+		return "", 0
+	}
+
+	cfg := s.backends[0].provider.Config()
+
+	// The summarization request has its own context window
+	prompt := []ChatMessage{{Role: "system", Content: contextSummaryPrompt}}
+	window := cfg.ContextWindow - cfg.MaxTokens - countPromptTokens(prompt) - messageTokenOverhead
+	start, _ := fitRecent(older, window)
+	covered := older[start:]
+	if len(covered) == 0 {
+		return "", 0
+	}
+
+	var transcript strings.Builder
+	for _, message := range covered {
+		// User turns already start with the speaker label
+		if message.Role != "user" {
+			transcript.WriteString(message.Role)
+			transcript.WriteString(": ")
+		}
+		transcript.WriteString(message.Content)
+		transcript.WriteString("\n")
+	}
+
+	resp, err := s.ChatCompletionContext(ctx, append(prompt, ChatMessage{Role: "user", Content: transcript.String()}))
+	if err != nil || len(resp.Choices) == 0 {
+		logrus.WithError(err).Warn("Failed to summarize older context, omitting it")
+		return "", 0
+	}
+
+	summary := strings.TrimSpace(resp.Choices[0].Message.Content)
+	// The model may ignore the length instruction; cut the summary to its budget
+	if maxRunes := summaryBudget * 3; len([]rune(summary)) > maxRunes {
+		summary = string([]rune(summary)[:maxRunes])
+	}
+	return summary, len(covered)
+}
+
+// contextSummaryPrompt condenses older conversation history
+const contextSummaryPrompt = "Summarize the following conversation history briefly. " +
+	"Keep names, dates, amounts, decisions and open questions. Answer in the language of the conversation."
+
+// fitRecent returns the index of the oldest message such that messages[index:] fit into budget,
+// and the tokens they use
+func fitRecent(messages []ChatMessage, budget int) (int, int) {
+	used := 0
+	start := len(messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		tokens := EstimateTokens(messages[i].Content) + messageTokenOverhead
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		start = i
+	}
+	return start, used
+}
+
+// countPromptTokens estimates the tokens of a list of messages including per-message overhead
+func countPromptTokens(messages []ChatMessage) int {
+	total := 0
+	for _, message := range messages {
+		total += EstimateTokens(message.Content) + messageTokenOverhead
+	}
+	return total
+}
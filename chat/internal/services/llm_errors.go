@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// LLM error kinds, matched with errors.Is
+var (
+	ErrLLMRateLimited = errors.New("LLM provider rate limited the request")
+	ErrLLMTimeout     = errors.New("LLM request timed out")
+	ErrLLMProvider    = errors.New("LLM provider returned an error")
+	ErrLLMUnavailable = errors.New("LLM provider is unavailable")
+)
+
+// LLMError describes a failed provider request
+type LLMError struct {
+	Provider   string
+	Kind       error         // one of the ErrLLM* kinds
+	StatusCode int           // HTTP status, 0 for transport errors
+	RetryAfter time.Duration // parsed from the Retry-After header, 0 if absent
+	Retryable  bool
+	Err        error
+}
+
+func (e *LLMError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s: %v", e.Provider, e.Kind)
+	}
+	return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+}
+
+// Unwrap exposes both the kind and the underlying error to errors.Is/As
+func (e *LLMError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// newHTTPError classifies a non-200 response
+func newHTTPError(provider string, resp *http.Response, err error) *LLMError {
+	llmErr := &LLMError{
+		Provider:   provider,
+		Kind:       ErrLLMProvider,
+		StatusCode: resp.StatusCode,
+		Err:        err,
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		llmErr.Kind = ErrLLMRateLimited
+		llmErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		llmErr.Retryable = true
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusGatewayTimeout:
+		llmErr.Kind = ErrLLMTimeout
+		llmErr.Retryable = true
+	case resp.StatusCode >= 500:
+		llmErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		llmErr.Retryable = true
+	}
+
+	return llmErr
+}
+
+// newTransportError classifies an error returned by http.Client.Do or while reading the body.
+// Cancellation by the caller is returned as is so it is neither retried nor counted as a failure.
+func newTransportError(ctx context.Context, provider string, err error) error {
+	if ctx.Err() == context.Canceled {
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &LLMError{Provider: provider, Kind: ErrLLMTimeout, Retryable: true, Err: err}
+	}
+	return &LLMError{Provider: provider, Kind: ErrLLMProvider, Retryable: true, Err: err}
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CaseCategories are the legal issue categories ExtractCase may assign
+var CaseCategories = []string{
+	"family",
+	"labor",
+	"housing",
+	"property",
+	"contract",
+	"consumer",
+	"debt",
+	"inheritance",
+	"criminal",
+	"administrative",
+	"tax",
+	"corporate",
+	"migration",
+	"other",
+}
+
+// CaseParty is a person or organization involved in the case
+type CaseParty struct {
+	Name string `json:"name"`
+	Role string `json:"role"` // e.g. client, employer, landlord
+}
+
+// CaseDate is a date mentioned in the conversation
+type CaseDate struct {
+	Date        string `json:"date"` // YYYY-MM-DD, empty if the exact date is unknown
+	Description string `json:"description"`
+	IsDeadline  bool   `json:"is_deadline"`
+}
+
+// CaseAmount is a sum of money mentioned in the conversation
+type CaseAmount struct {
+	Value       float64 `json:"value"`
+	Currency    string  `json:"currency"` // ISO 4217 code, empty if unknown
+	Description string  `json:"description"`
+}
+
+// CaseExtraction is the JSON the model returns for ExtractCase
+type CaseExtraction struct {
+	Parties       []CaseParty  `json:"parties"`
+	Dates         []CaseDate   `json:"dates"`
+	Amounts       []CaseAmount `json:"amounts"`
+	IssueCategory string       `json:"issue_category"`
+	OpenQuestions []string     `json:"open_questions"`
+}
+
+// Validate checks the category, the required fields and the date format
+func (e *CaseExtraction) Validate() error {
+	if !isCaseCategory(e.IssueCategory) {
+		return fmt.Errorf("issue_category must be one of: %s", strings.Join(CaseCategories, ", "))
+	}
+	for i, party := range e.Parties {
+		if strings.TrimSpace(party.Name) == "" {
+			return fmt.Errorf("parties[%d].name must not be empty", i)
+		}
+	}
+	for i, date := range e.Dates {
+		if strings.TrimSpace(date.Description) == "" {
+			return fmt.Errorf("dates[%d].description must not be empty", i)
+		}
+		if date.Date != "" {
+			if _, err := time.Parse("2006-01-02", date.Date); err != nil {
+				return fmt.Errorf("dates[%d].date must be YYYY-MM-DD or empty", i)
+			}
+		}
+	}
+	for i, amount := range e.Amounts {
+		if amount.Value < 0 {
+			return fmt.Errorf("amounts[%d].value must not be negative", i)
+		}
+		if strings.TrimSpace(amount.Description) == "" {
+			return fmt.Errorf("amounts[%d].description must not be empty", i)
+		}
+	}
+
+	// Empty lists are encoded as [] rather than null
+	if e.Parties == nil {
+		e.Parties = []CaseParty{}
+	}
+	if e.Dates == nil {
+		e.Dates = []CaseDate{}
+	}
+	if e.Amounts == nil {
+		e.Amounts = []CaseAmount{}
+	}
+	if e.OpenQuestions == nil {
+		e.OpenQuestions = []string{}
+	}
+	return nil
+}
+
+func isCaseCategory(category string) bool {
+	for _, c := range CaseCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// caseExtractionFormat is appended to the extract_case template: the categories and JSON that Validate checks
+var caseExtractionFormat = "issue_category must be one of: " + strings.Join(CaseCategories, ", ") + ". " +
+	"Respond with only a JSON object of the form " +
+	`{"parties": [{"name": "...", "role": "..."}], "dates": [{"date": "YYYY-MM-DD", "description": "...", "is_deadline": false}], ` +
+	`"amounts": [{"value": 0, "currency": "KZT", "description": "..."}], "issue_category": "...", "open_questions": ["..."]}`
+
+// CaseExtractionPrompt combines the rendered extract_case template with the expected JSON format.
+// It is the system prompt used by ExtractCase, also needed for context budgeting.
+func CaseExtractionPrompt(instructions string) ChatMessage {
+	return ChatMessage{Role: "system", Content: strings.TrimSpace(instructions) + "\n\n" + caseExtractionFormat}
+}
+
+// ExtractCase extracts structured case information from a conversation (a transcript from FormatTranscript).
+// instructions is the rendered extract_case template. It returns the validated extraction and its raw JSON.
+func (s *LLMService) ExtractCase(ctx context.Context, instructions string, transcript []ChatMessage) (*CaseExtraction, string, error) {
+	if len(transcript) == 0 {
+		return nil, "", errors.New("no messages to extract from")
+	}
+
+	messages := []ChatMessage{CaseExtractionPrompt(instructions)}
+	messages = append(messages, transcript...)
+	messages = append(messages, ChatMessage{Role: "user", Content: "Extract the case information now."})
+
+	var result CaseExtraction
+	raw, err := s.CompleteJSON(ctx, messages, &result)
+	if err != nil {
+		return nil, "", err
+	}
+	return &result, raw, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"kelisim-chat/internal/config"
+	"strings"
+)
+
+// FakeProvider returns deterministic answers without calling any API.
+// It is meant for tests and local development.
+type FakeProvider struct {
+	cfg config.LLMProviderConfig
+}
+
+// NewFakeProvider creates a fake provider
+func NewFakeProvider(cfg config.LLMProviderConfig) *FakeProvider {
+	return &FakeProvider{cfg: cfg}
+}
+
+// Name returns the provider name
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// Config returns the provider settings
+func (p *FakeProvider) Config() config.LLMProviderConfig {
+	return p.cfg
+}
+
+// ChatCompletion answers by echoing the last user message
+func (p *FakeProvider) ChatCompletion(ctx context.Context, messages []ChatMessage) (*ChatCompletionResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	answer := p.answer(messages)
+	return newCompletionResponse(p.cfg.Model, answer, countPromptTokens(messages), EstimateTokens(answer)), nil
+}
+
+// ChatCompletionStream streams the same answer as ChatCompletion word by word
+func (p *FakeProvider) ChatCompletionStream(ctx context.Context, messages []ChatMessage, onDelta DeltaHandler) (string, error) {
+	answer := p.answer(messages)
+
+	var sent strings.Builder
+	for _, word := range strings.SplitAfter(answer, " ") {
+		if err := ctx.Err(); err != nil {
+			return sent.String(), err
+		}
+		sent.WriteString(word)
+		if err := onDelta(word); err != nil {
+			return sent.String(), err
+		}
+	}
+	return sent.String(), nil
+}
+
+// answer builds the deterministic reply for a conversation
+func (p *FakeProvider) answer(messages []ChatMessage) string {
+	last := ""
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			last = messages[i].Content
+			break
+		}
+	}
+
+	runes := []rune(strings.TrimSpace(last))
+	if len(runes) > 200 {
+		runes = runes[:200]
+	}
+	return "Fake response to: " + string(runes)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// jsonAttempts is how many times CompleteJSON asks the model before giving up
+const jsonAttempts = 3
+
+// ErrInvalidJSON is returned when the model keeps answering with invalid JSON
+var ErrInvalidJSON = errors.New("model did not return valid JSON")
+
+// jsonValidator is implemented by CompleteJSON targets that check their own content
+type jsonValidator interface {
+	Validate() error
+}
+
+// CompleteJSON sends messages and decodes the answer into out. If the answer is not valid JSON
+// or out.Validate() fails, the model is shown the error and asked again.
+// Every answer is decoded into a fresh copy of out's initial value, and out is only updated
+// with an answer that passed validation. It returns the raw JSON that was decoded.
+func (s *LLMService) CompleteJSON(ctx context.Context, messages []ChatMessage, out interface{}) (string, error) {
+	conversation := append([]ChatMessage{}, messages...)
+
+	var lastErr error
+	for attempt := 0; attempt < jsonAttempts; attempt++ {
+		resp, err := s.ChatCompletionContext(ctx, conversation)
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Choices) == 0 {
+			return "", errors.New("no response from API")
+		}
+
+		answer := resp.Choices[0].Message.Content
+		attemptOut := reflect.New(reflect.TypeOf(out).Elem())
+		attemptOut.Elem().Set(reflect.ValueOf(out).Elem())
+		raw, err := decodeJSONAnswer(answer, attemptOut.Interface())
+		if err == nil {
+			reflect.ValueOf(out).Elem().Set(attemptOut.Elem())
+			return raw, nil
+		}
+		lastErr = err
+
+		// Show the model its answer and what is wrong with it
+		conversation = append(conversation,
+			ChatMessage{Role: "assistant", Content: answer},
+			ChatMessage{Role: "user", Content: fmt.Sprintf("Your answer is invalid: %v. Reply again with only the corrected JSON object.", err)},
+		)
+	}
+
+	return "", fmt.Errorf("%w: %v", ErrInvalidJSON, lastErr)
+}
+
+// decodeJSONAnswer extracts the JSON object from an answer (models like to wrap it in
+// code fences or add a sentence around it), decodes and validates it
+func decodeJSONAnswer(answer string, out interface{}) (string, error) {
+	start := strings.Index(answer, "{")
+	end := strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return "", errors.New("no JSON object found")
+	}
+	raw := answer[start : end+1]
+
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return "", err
+	}
+
+	if validator, ok := out.(jsonValidator); ok {
+		if err := validator.Validate(); err != nil {
+			return "", err
+		}
+	}
+	return raw, nil
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSuggestRepliesRetriesTooFewSuggestions(t *testing.T) {
+	answers := []string{
+		`{"suggestions": [{"reply": "only one", "rationale": "from the rejected answer"}]}`,
+		`{"suggestions": [{"reply": "first"}, {"reply": "second", "rationale": "shorter"}]}`,
+	}
+	requests := 0
+	service := newStreamTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		answer := answers[requests]
+		requests++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{
+				map[string]interface{}{"message": map[string]string{"role": "assistant", "content": answer}},
+			},
+		})
+	})
+
+	suggestions, err := service.SuggestReplies(context.Background(), "Draft 3 replies in English.", []ChatMessage{{Role: "user", Content: "Client: hello"}}, 3)
+	if err != nil {
+		t.Fatalf("SuggestReplies() error = %v", err)
+	}
+	if requests != 2 {
+		t.Fatalf("requests = %d, want 2 (the one-item answer must be retried)", requests)
+	}
+
+	// Nothing from the rejected answer may leak into the accepted one
+	want := []ReplySuggestion{{Reply: "first"}, {Reply: "second", Rationale: "shorter"}}
+	if !reflect.DeepEqual(suggestions, want) {
+		t.Fatalf("suggestions = %+v, want %+v", suggestions, want)
+	}
+}
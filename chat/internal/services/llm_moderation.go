@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Moderation categories and severities shared by the rule set and the model
+var (
+	ModerationCategories = []string{FlagCategorySelfHarm, FlagCategoryIllegal, FlagCategoryAbuse}
+	ModerationSeverities = []string{FlagSeverityLow, FlagSeverityMedium, FlagSeverityHigh}
+)
+
+// ModerationLabel is one risk the model found in a message
+type ModerationLabel struct {
+	Category string `json:"category"`
+	Severity string `json:"severity"`
+	Reason   string `json:"reason"`
+}
+
+// ModerationVerdict is the JSON the model returns for ModerateMessage
+type ModerationVerdict struct {
+	Flags []ModerationLabel `json:"flags"`
+}
+
+// Validate checks categories and severities
+func (v *ModerationVerdict) Validate() error {
+	for i, flag := range v.Flags {
+		if !containsString(ModerationCategories, flag.Category) {
+			return fmt.Errorf("flags[%d].category must be one of: %s", i, strings.Join(ModerationCategories, ", "))
+		}
+		if !containsString(ModerationSeverities, flag.Severity) {
+			return fmt.Errorf("flags[%d].severity must be one of: %s", i, strings.Join(ModerationSeverities, ", "))
+		}
+	}
+	return nil
+}
+
+// moderationPrompt describes the categories and the expected JSON
+var moderationPrompt = "You review client messages on a legal consultation platform and alert operators to risks. " +
+	"Flag a message only for these categories: " +
+	"self_harm (the writer threatens or plans to hurt or kill themselves), " +
+	"illegal (the writer asks for help with something illegal, such as forging documents, bribery or hiding assets from a court), " +
+	"abuse (insults, harassment or threats against other people). " +
+	"Describing a legal problem, quoting what someone else did or being upset is not a risk by itself. " +
+	"Severity is high for immediate danger to life, medium for clear violations and low for borderline cases. " +
+	"Write the reason in English, in one short sentence. " +
+	`Respond with only a JSON object of the form {"flags": [{"category": "...", "severity": "...", "reason": "..."}]}; ` +
+	`use {"flags": []} when the message is fine.`
+
+// ModerateMessage asks the model to classify a single message
+func (s *LLMService) ModerateMessage(ctx context.Context, text string) (*ModerationVerdict, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("no text to moderate")
+	}
+
+	messages := []ChatMessage{
+		{Role: "system", Content: moderationPrompt},
+		{Role: "user", Content: text},
+	}
+
+	var verdict ModerationVerdict
+	if _, err := s.CompleteJSON(ctx, messages, &verdict); err != nil {
+		return nil, err
+	}
+	return &verdict, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
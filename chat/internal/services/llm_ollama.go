@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kelisim-chat/internal/config"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OllamaProvider talks to a local Ollama server through its native /api/chat endpoint
+type OllamaProvider struct {
+	cfg          config.LLMProviderConfig
+	httpClient   *http.Client
+	streamClient *http.Client
+}
+
+// ollamaChatRequest is the request payload of /api/chat
+type ollamaChatRequest struct {
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  struct {
+		NumPredict  int     `json:"num_predict,omitempty"`
+		Temperature float64 `json:"temperature,omitempty"`
+	} `json:"options"`
+}
+
+// ollamaChatResponse is one response object of /api/chat; streaming returns one per line
+type ollamaChatResponse struct {
+	Model           string      `json:"model"`
+	Message         ChatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+	Error           string      `json:"error"`
+}
+
+// NewOllamaProvider creates an Ollama provider
+func NewOllamaProvider(cfg config.LLMProviderConfig) *OllamaProvider {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+
+	return &OllamaProvider{
+		cfg: cfg,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+		streamClient: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				ResponseHeaderTimeout: timeout,
+			},
+		},
+	}
+}
+
+// Name returns the provider name
+func (p *OllamaProvider) Name() string {
+	return "ollama"
+}
+
+// Config returns the provider settings
+func (p *OllamaProvider) Config() config.LLMProviderConfig {
+	return p.cfg
+}
+
+// ChatCompletion sends a non-streaming /api/chat request
+func (p *OllamaProvider) ChatCompletion(ctx context.Context, messages []ChatMessage) (*ChatCompletionResponse, error) {
+	resp, err := p.send(ctx, p.httpClient, messages, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, newTransportError(ctx, p.Name(), fmt.Errorf("failed to parse response: %w", err))
+	}
+	if chatResp.Error != "" {
+		return nil, &LLMError{Provider: p.Name(), Kind: ErrLLMProvider, Retryable: true, Err: fmt.Errorf("API error: %s", chatResp.Error)}
+	}
+
+	return newCompletionResponse(chatResp.Model, chatResp.Message.Content, chatResp.PromptEvalCount, chatResp.EvalCount), nil
+}
+
+// ChatCompletionStream sends a streaming /api/chat request; Ollama streams newline-delimited JSON
+func (p *OllamaProvider) ChatCompletionStream(ctx context.Context, messages []ChatMessage, onDelta DeltaHandler) (string, error) {
+	resp, err := p.send(ctx, p.streamClient, messages, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var answer strings.Builder
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return answer.String(), fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return answer.String(), &LLMError{Provider: p.Name(), Kind: ErrLLMProvider, Retryable: true, Err: fmt.Errorf("API error: %s", chunk.Error)}
+		}
+
+		if chunk.Message.Content != "" {
+			answer.WriteString(chunk.Message.Content)
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return answer.String(), err
+			}
+		}
+		if chunk.Done {
+			return answer.String(), nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return answer.String(), newTransportError(ctx, p.Name(), fmt.Errorf("failed to read stream: %w", err))
+	}
+	return answer.String(), nil
+}
+
+// send posts the request and returns the response if the status is 200
+func (p *OllamaProvider) send(ctx context.Context, client *http.Client, messages []ChatMessage, stream bool) (*http.Response, error) {
+	reqPayload := ollamaChatRequest{
+		Model:    p.cfg.Model,
+		Messages: messages,
+		Stream:   stream,
+	}
+	reqPayload.Options.NumPredict = p.cfg.MaxTokens
+	reqPayload.Options.Temperature = p.cfg.Temperature
+
+	jsonData, err := json.Marshal(reqPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/chat", strings.TrimRight(p.cfg.APIBase, "/"))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, newTransportError(ctx, p.Name(), fmt.Errorf("API request failed: %w", err))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+			return nil, newHTTPError(p.Name(), resp, fmt.Errorf("API error: %s", errResp.Error))
+		}
+		return nil, newHTTPError(p.Name(), resp, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kelisim-chat/internal/config"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIProvider talks to any OpenAI-compatible chat completions endpoint (DeepSeek by default)
+type OpenAIProvider struct {
+	cfg        config.LLMProviderConfig
+	httpClient *http.Client
+	// streamClient has no overall timeout since a stream may outlive the request timeout;
+	// the timeout applies to waiting for the response headers instead
+	streamClient *http.Client
+}
+
+// NewOpenAIProvider creates an OpenAI-compatible provider
+func NewOpenAIProvider(cfg config.LLMProviderConfig) *OpenAIProvider {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+
+	return &OpenAIProvider{
+		cfg: cfg,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+		streamClient: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				ResponseHeaderTimeout: timeout,
+			},
+		},
+	}
+}
+
+// Name returns the provider name
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+// Config returns the provider settings
+func (p *OpenAIProvider) Config() config.LLMProviderConfig {
+	return p.cfg
+}
+
+// ChatCompletion sends a chat completion request to the /chat/completions endpoint
+func (p *OpenAIProvider) ChatCompletion(ctx context.Context, messages []ChatMessage) (*ChatCompletionResponse, error) {
+	resp, err := p.send(ctx, p.httpClient, messages, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newTransportError(ctx, p.Name(), fmt.Errorf("failed to read response: %w", err))
+	}
+
+	// Parse response
+	var completionResp ChatCompletionResponse
+	if err := json.Unmarshal(body, &completionResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &completionResp, nil
+}
+
+// ChatCompletionStream sends a streaming chat completion request and parses the SSE chunks
+func (p *OpenAIProvider) ChatCompletionStream(ctx context.Context, messages []ChatMessage, onDelta DeltaHandler) (string, error) {
+	resp, err := p.send(ctx, p.streamClient, messages, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var answer strings.Builder
+	var deltaErr error
+	err = readSSE(resp.Body, func(data string) (bool, error) {
+		if data == "[DONE]" {
+			return true, nil
+		}
+
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			answer.WriteString(choice.Delta.Content)
+			if err := onDelta(choice.Delta.Content); err != nil {
+				deltaErr = err
+				return false, err
+			}
+		}
+		return false, nil
+	})
+	// Errors returned by onDelta are passed through unchanged
+	if err != nil && err != deltaErr {
+		return answer.String(), newTransportError(ctx, p.Name(), err)
+	}
+
+	return answer.String(), err
+}
+
+// send posts the request and returns the response if the status is 200
+func (p *OpenAIProvider) send(ctx context.Context, client *http.Client, messages []ChatMessage, stream bool) (*http.Response, error) {
+	if p.cfg.APIKey == "" {
+		return nil, errors.New("DEEPSEEK_API_KEY is not configured")
+	}
+
+	// Prepare request payload
+	reqPayload := ChatCompletionRequest{
+		Model:       p.cfg.Model,
+		Messages:    messages,
+		MaxTokens:   p.cfg.MaxTokens,
+		Temperature: p.cfg.Temperature,
+		Stream:      stream,
+	}
+
+	jsonData, err := json.Marshal(reqPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare request: %w", err)
+	}
+
+	// Create HTTP request
+	url := fmt.Sprintf("%s/chat/completions", strings.TrimRight(p.cfg.APIBase, "/"))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.cfg.APIKey))
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, newTransportError(ctx, p.Name(), fmt.Errorf("API request failed: %w", err))
+	}
+
+	// Check for error response
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var errResp ErrorResponse
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+			return nil, newHTTPError(p.Name(), resp, fmt.Errorf("API error: %s", errResp.Error.Message))
+		}
+		return nil, newHTTPError(p.Name(), resp, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"kelisim-chat/internal/prompts"
+)
+
+// PromptData collects the template variables of a chat: title, participants and language.
+// language is normalized; when it is empty or unsupported the detected chat language is used.
+// chatID 0 renders a prompt that is not about a single chat, with only the language set.
+func PromptData(chatID uint, language string) prompts.Data {
+	data := prompts.Data{
+		ChatID:   chatID,
+		Language: NormalizeLanguage(language),
+	}
+
+	if chatID == 0 {
+		data.LanguageName = SupportedLanguages[data.Language]
+		return data
+	}
+
+	chat, err := NewChatService().GetChatByIDForOperator(chatID)
+	if err == nil {
+		data.ChatTitle = chat.Title
+		for _, p := range chat.Participants {
+			participant := prompts.Participant{
+				Name:     p.User.GetFullName(),
+				UserType: p.User.UserType,
+			}
+			if p.Role != nil {
+				participant.Role = *p.Role
+			}
+			data.Participants = append(data.Participants, participant)
+		}
+		if data.Language == "" {
+			data.Language = chat.Language
+		}
+	}
+
+	data.LanguageName = SupportedLanguages[data.Language]
+	return data
+}
+
+// RenderPrompt renders the latest (version 0) or a specific version of a prompt template for a chat
+// and returns the text and the template ID (e.g. "operator_ask@v1") to record with the interaction
+func RenderPrompt(name string, version int, chatID uint, language string) (string, string, error) {
+	return RenderPromptData(name, version, PromptData(chatID, language))
+}
+
+// RenderPromptData renders a prompt template with prepared data, e.g. PromptData with Count set
+func RenderPromptData(name string, version int, data prompts.Data) (string, string, error) {
+	text, template, err := prompts.Default().Render(name, version, data)
+	if err != nil {
+		return "", "", err
+	}
+	return text, template.ID(), nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"kelisim-chat/internal/config"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Provider is a chat completion backend used by LLMService
+type Provider interface {
+	// Name identifies the provider in logs and responses
+	Name() string
+	// Config returns the model, token and timeout settings of the provider
+	Config() config.LLMProviderConfig
+	// ChatCompletion sends a non-streaming chat completion request
+	ChatCompletion(ctx context.Context, messages []ChatMessage) (*ChatCompletionResponse, error)
+	// ChatCompletionStream sends a streaming chat completion request, calls onDelta for every
+	// content delta and returns the full answer
+	ChatCompletionStream(ctx context.Context, messages []ChatMessage, onDelta DeltaHandler) (string, error)
+}
+
+// NewProvider creates the provider selected by LLM_PROVIDER
+func NewProvider(cfg config.LLMConfig) Provider {
+	return newNamedProvider(cfg, cfg.Provider, "")
+}
+
+// NewFallbackProviders creates the fallbacks listed in LLM_FALLBACKS.
+// Each entry is a provider name, optionally followed by a model: "openai:deepseek-reasoner", "ollama".
+func NewFallbackProviders(cfg config.LLMConfig) []Provider {
+	providers := make([]Provider, 0, len(cfg.Fallbacks))
+	for _, entry := range cfg.Fallbacks {
+		name, model, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		if name == "" {
+			continue
+		}
+		providers = append(providers, newNamedProvider(cfg, name, model))
+	}
+	return providers
+}
+
+// newNamedProvider creates a provider by name, overriding its model if model is not empty
+func newNamedProvider(cfg config.LLMConfig, name string, model string) Provider {
+	withModel := func(pcfg config.LLMProviderConfig) config.LLMProviderConfig {
+		if model != "" {
+			pcfg.Model = model
+		}
+		return pcfg
+	}
+
+	switch name {
+	case "ollama":
+		return NewOllamaProvider(withModel(cfg.Ollama))
+	case "fake":
+		return NewFakeProvider(withModel(cfg.Fake))
+	case "openai", "deepseek", "":
+		return NewOpenAIProvider(withModel(cfg.OpenAI))
+	default:
+		logrus.WithField("provider", name).Warn("Unknown LLM provider, falling back to openai")
+		return NewOpenAIProvider(withModel(cfg.OpenAI))
+	}
+}
+
+// newCompletionResponse builds a single-choice response for providers with their own wire format
+func newCompletionResponse(model string, content string, promptTokens int, completionTokens int) *ChatCompletionResponse {
+	resp := &ChatCompletionResponse{
+		Object: "chat.completion",
+		Model:  model,
+	}
+	resp.Choices = make([]struct {
+		Index   int `json:"index"`
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	}, 1)
+	resp.Choices[0].Message.Role = "assistant"
+	resp.Choices[0].Message.Content = content
+	resp.Choices[0].FinishReason = "stop"
+	resp.Usage.PromptTokens = promptTokens
+	resp.Usage.CompletionTokens = completionTokens
+	resp.Usage.TotalTokens = promptTokens + completionTokens
+	return resp
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MinReplySuggestions is the fewest drafts SuggestReplies accepts from the model
+const MinReplySuggestions = 2
+
+// ReplySuggestion is a draft reply an operator can send
+type ReplySuggestion struct {
+	Reply     string `json:"reply"`
+	Rationale string `json:"rationale"`
+}
+
+// ReplySuggestions is the JSON the model returns for SuggestReplies
+type ReplySuggestions struct {
+	Suggestions []ReplySuggestion `json:"suggestions"`
+
+	minCount int // fewest suggestions Validate accepts
+}
+
+// Validate checks that there are enough suggestions and every suggestion has a reply
+func (r *ReplySuggestions) Validate() error {
+	if len(r.Suggestions) == 0 {
+		return errors.New("suggestions must not be empty")
+	}
+	if len(r.Suggestions) < r.minCount {
+		return fmt.Errorf("expected at least %d suggestions, got %d", r.minCount, len(r.Suggestions))
+	}
+	for i, suggestion := range r.Suggestions {
+		if strings.TrimSpace(suggestion.Reply) == "" {
+			return fmt.Errorf("suggestions[%d].reply must not be empty", i)
+		}
+	}
+	return nil
+}
+
+// replySuggestionFormat is appended to the suggest_replies template: the JSON that Validate checks
+const replySuggestionFormat = "Respond with only a JSON object of the form " +
+	`{"suggestions": [{"reply": "...", "rationale": "..."}]}`
+
+// ReplySuggestionPrompt combines the rendered suggest_replies template with the expected JSON format
+func ReplySuggestionPrompt(instructions string) ChatMessage {
+	return ChatMessage{Role: "system", Content: strings.TrimSpace(instructions) + "\n\n" + replySuggestionFormat}
+}
+
+// SuggestReplies drafts count replies to a conversation (a transcript from FormatTranscript).
+// instructions is the rendered suggest_replies template.
+func (s *LLMService) SuggestReplies(ctx context.Context, instructions string, transcript []ChatMessage, count int) ([]ReplySuggestion, error) {
+	if len(transcript) == 0 {
+		return nil, errors.New("no messages to reply to")
+	}
+
+	messages := []ChatMessage{ReplySuggestionPrompt(instructions)}
+	messages = append(messages, transcript...)
+	messages = append(messages, ChatMessage{Role: "user", Content: "Draft the operator's next reply now."})
+
+	result := ReplySuggestions{minCount: min(count, MinReplySuggestions)}
+	if _, err := s.CompleteJSON(ctx, messages, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Suggestions) > count {
+		result.Suggestions = result.Suggestions[:count]
+	}
+	return result.Suggestions, nil
+}
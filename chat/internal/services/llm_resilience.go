@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// llmBackend is a provider together with its circuit breaker
+type llmBackend struct {
+	provider Provider
+	breaker  *circuitBreaker
+}
+
+// retryPolicy controls retries of a single provider
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+// delay returns the wait before retry number attempt (0-based): exponential backoff with jitter,
+// or the provider's Retry-After when it is given
+func (p retryPolicy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	d := p.baseDelay << attempt
+	if d <= 0 || d > p.maxDelay {
+		d = p.maxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Wait between half and the full backoff so concurrent callers do not retry in lockstep
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// circuitBreaker stops sending requests to a provider after consecutive failures.
+// After the cooldown a single trial request is let through; its result closes or reopens the circuit.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int // consecutive failures before opening, 0 disables the breaker
+	cooldown  time.Duration
+	failures  int
+	open      bool
+	openedAt  time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// breakers holds one circuit breaker per provider and model, shared by every LLMService,
+// so an outage seen by one feature (assistant, translation, moderation, summaries) is seen by all
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*circuitBreaker{}
+)
+
+// sharedBreaker returns the circuit breaker of provider, creating it on first use
+func sharedBreaker(provider Provider, threshold int, cooldown time.Duration) *circuitBreaker {
+	key := provider.Name() + ":" + provider.Config().Model
+
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	breaker, ok := breakers[key]
+	if !ok {
+		breaker = newCircuitBreaker(threshold, cooldown)
+		breakers[key] = breaker
+	}
+	return breaker
+}
+
+// allow reports whether a request may be sent
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || !b.open {
+		return true
+	}
+	if time.Since(b.openedAt) < b.cooldown || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// isOpen reports whether the circuit is currently open
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.open
+}
+
+// success closes the circuit
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.open = false
+	b.probing = false
+}
+
+// release ends a trial request without a verdict (e.g. the caller went away)
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// failure records a failed request and returns true if it opened the circuit
+func (b *circuitBreaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	b.failures++
+	if b.open {
+		// The trial request failed, wait another cooldown
+		b.openedAt = time.Now()
+		return false
+	}
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.open = true
+		b.openedAt = time.Now()
+		return true
+	}
+	return false
+}
+
+// llmAttempt performs one request against a provider
+type llmAttempt func(ctx context.Context, provider Provider) error
+
+// call runs attempt against the primary provider and then the fallbacks in order,
+// retrying each one according to the retry policy. canRetry, if set, is consulted before
+// every retry or fallback (a stream that already sent deltas must not be repeated).
+func (s *LLMService) call(ctx context.Context, attempt llmAttempt, canRetry func() bool) error {
+	var lastErr error
+
+	for _, backend := range s.backends {
+		if lastErr != nil && canRetry != nil && !canRetry() {
+			return lastErr
+		}
+
+		if !backend.breaker.allow() {
+			logrus.WithField("provider", backend.provider.Name()).Warn("LLM circuit breaker is open, skipping provider")
+			if lastErr == nil {
+				lastErr = &LLMError{Provider: backend.provider.Name(), Kind: ErrLLMUnavailable}
+			}
+			continue
+		}
+
+		err := s.callBackend(ctx, backend, attempt, canRetry)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		lastErr = err
+	}
+
+	return lastErr
+}
+
+// callBackend runs attempt against one provider with retries
+func (s *LLMService) callBackend(ctx context.Context, backend *llmBackend, attempt llmAttempt, canRetry func() bool) error {
+	name := backend.provider.Name()
+
+	for i := 0; ; i++ {
+		err := attempt(ctx, backend.provider)
+		if err == nil {
+			backend.breaker.success()
+			return nil
+		}
+		if ctx.Err() != nil {
+			backend.breaker.release()
+			return err
+		}
+
+		llmErr := asLLMError(name, err)
+
+		// Only outages count towards the breaker; rate limits and rejected requests mean the provider is up
+		if llmErr.Retryable && !errors.Is(llmErr, ErrLLMRateLimited) {
+			if backend.breaker.failure() {
+				logrus.WithField("provider", name).Warn("LLM circuit breaker opened")
+			}
+		} else {
+			backend.breaker.success()
+		}
+
+		if !llmErr.Retryable || i >= s.retry.maxRetries || backend.breaker.isOpen() {
+			return llmErr
+		}
+		if canRetry != nil && !canRetry() {
+			return llmErr
+		}
+
+		// A Retry-After longer than we are willing to wait goes straight to the fallback
+		if llmErr.RetryAfter > s.retry.maxDelay {
+			return llmErr
+		}
+
+		delay := s.retry.delay(i, llmErr.RetryAfter)
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"provider": name,
+			"attempt":  i + 1,
+			"delay":    delay.String(),
+		}).Warn("LLM request failed, retrying")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// asLLMError converts any provider error into an LLMError; unclassified errors are not retried
+func asLLMError(provider string, err error) *LLMError {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		return llmErr
+	}
+	return &LLMError{Provider: provider, Kind: ErrLLMProvider, Err: err}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxSnippetRunes limits how much of each excerpt is shown to the model
+const maxSnippetRunes = 1200
+
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// SnippetContext formats retrieved excerpts as a numbered list for the search_answer prompt.
+// Excerpt [n] is hits[n-1].
+func SnippetContext(hits []SearchHit) ChatMessage {
+	var sb strings.Builder
+	sb.WriteString("Excerpts:\n")
+	for i, hit := range hits {
+		fmt.Fprintf(&sb, "\n[%d] chat #%d", i+1, hit.ChatID)
+		if hit.ChatTitle != "" {
+			fmt.Fprintf(&sb, " %q", hit.ChatTitle)
+		}
+		fmt.Fprintf(&sb, ", message #%d, %s", hit.MessageID, hit.CreatedAt.Format("2006-01-02"))
+		if hit.SenderName != "" {
+			fmt.Fprintf(&sb, ", %s", hit.SenderName)
+			if hit.SenderType != "" {
+				fmt.Fprintf(&sb, " (%s)", hit.SenderType)
+			}
+		}
+		if hit.Source == EmbeddingSourceFile {
+			fmt.Fprintf(&sb, ", from document %q", hit.FileName)
+		}
+		sb.WriteString(":\n")
+		sb.WriteString(truncateRunes(hit.Content, maxSnippetRunes))
+		sb.WriteString("\n")
+	}
+	return ChatMessage{Role: "user", Content: sb.String()}
+}
+
+// CitedSnippets returns the 1-based excerpt numbers cited in an answer, in order of first
+// appearance. Numbers that do not refer to an excerpt are ignored.
+func CitedSnippets(answer string, count int) []int {
+	var cited []int
+	seen := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n < 1 || n > count || seen[n] {
+			continue
+		}
+		seen[n] = true
+		cited = append(cited, n)
+	}
+	return cited
+}
@@ -1,27 +1,21 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"kelisim-chat/internal/config"
-	"net/http"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
-// LLMService handles interactions with DeepSeek LLM API
+// LLMService handles interactions with the configured LLM providers
 type LLMService struct {
-	apiKey      string
-	apiBase     string
-	model       string
-	maxTokens   int
-	temperature float64
-	timeout     time.Duration
-	httpClient  *http.Client
+	backends []*llmBackend // primary provider first, then the fallbacks in order
+	retry    retryPolicy
+	redact   map[PIIType]bool // personal data masked before requests leave the server
 }
 
 // ChatMessage represents a message in the conversation
@@ -45,7 +39,9 @@ type ChatCompletionResponse struct {
 	Object  string `json:"object"`
 	Created int64  `json:"created"`
 	Model   string `json:"model"`
-	Choices []struct {
+	// Provider is the name of the provider that answered, set by LLMService
+	Provider string `json:"-"`
+	Choices  []struct {
 		Index   int `json:"index"`
 		Message struct {
 			Role    string `json:"role"`
@@ -69,109 +65,124 @@ type ErrorResponse struct {
 	} `json:"error"`
 }
 
-// NewLLMService creates a new LLM service instance
+// NewLLMService creates a new LLM service using the provider selected by LLM_PROVIDER
+// and the fallbacks listed in LLM_FALLBACKS
 func NewLLMService() *LLMService {
 	cfg := config.AppConfig.LLM
-	
-	return &LLMService{
-		apiKey:      cfg.APIKey,
-		apiBase:     cfg.APIBase,
-		model:       cfg.Model,
-		maxTokens:   cfg.MaxTokens,
-		temperature: cfg.Temperature,
-		timeout:     time.Duration(cfg.Timeout) * time.Second,
-		httpClient: &http.Client{
-			Timeout: time.Duration(cfg.Timeout) * time.Second,
+	return NewLLMServiceWithProvider(NewProvider(cfg), NewFallbackProviders(cfg)...)
+}
+
+// NewLLMServiceWithProvider creates a new LLM service backed by the given provider and fallbacks.
+// Circuit breakers are shared with the other services using the same provider and model.
+func NewLLMServiceWithProvider(provider Provider, fallbacks ...Provider) *LLMService {
+	cfg := config.AppConfig.LLM
+
+	s := &LLMService{
+		retry: retryPolicy{
+			maxRetries: cfg.MaxRetries,
+			baseDelay:  time.Duration(cfg.RetryBaseDelay) * time.Millisecond,
+			maxDelay:   time.Duration(cfg.RetryMaxDelay) * time.Millisecond,
 		},
+		redact: ParsePIITypes(cfg.PIIRedact),
+	}
+	for _, p := range append([]Provider{provider}, fallbacks...) {
+		s.backends = append(s.backends, &llmBackend{
+			provider: p,
+			breaker:  sharedBreaker(p, cfg.BreakerThreshold, time.Duration(cfg.BreakerCooldown)*time.Second),
+		})
 	}
+	return s
 }
 
-// ChatCompletion sends a chat completion request to DeepSeek API
-func (s *LLMService) ChatCompletion(messages []ChatMessage) (*ChatCompletionResponse, error) {
-	if s.apiKey == "" {
-		return nil, errors.New("DEEPSEEK_API_KEY is not configured")
-	}
+// ProviderName returns the name of the primary provider
+func (s *LLMService) ProviderName() string {
+	return s.backends[0].provider.Name()
+}
 
-	// Prepare request payload
-	reqPayload := ChatCompletionRequest{
-		Model:       s.model,
-		Messages:    messages,
-		MaxTokens:   s.maxTokens,
-		Temperature: s.temperature,
-		Stream:      false,
-	}
+// MaxTokens returns the completion token limit of the primary provider
+func (s *LLMService) MaxTokens() int {
+	return s.backends[0].provider.Config().MaxTokens
+}
 
-	jsonData, err := json.Marshal(reqPayload)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to marshal request payload")
-		return nil, fmt.Errorf("failed to prepare request: %w", err)
-	}
+// ChatCompletion sends a chat completion request to the provider
+func (s *LLMService) ChatCompletion(messages []ChatMessage) (*ChatCompletionResponse, error) {
+	return s.ChatCompletionContext(context.Background(), messages)
+}
 
-	// Create HTTP request
-	url := fmt.Sprintf("%s/chat/completions", s.apiBase)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+// ChatCompletionContext sends a chat completion request and cancels it when ctx is done.
+// Failed requests are retried and then sent to the fallback providers; the returned error
+// is an *LLMError unless ctx was cancelled.
+func (s *LLMService) ChatCompletionContext(ctx context.Context, messages []ChatMessage) (*ChatCompletionResponse, error) {
+	var completionResp *ChatCompletionResponse
+
+	redactor := NewRedactor(s.redact)
+	messages = s.redactMessages(redactor, messages)
+
+	err := s.call(ctx, func(ctx context.Context, provider Provider) error {
+		// Log request (without sensitive data)
+		logrus.WithFields(logrus.Fields{
+			"provider": provider.Name(),
+			"model":    provider.Config().Model,
+			"messages": len(messages),
+		}).Debug("Sending chat completion request")
+
+		resp, err := provider.ChatCompletion(ctx, messages)
+		if err != nil {
+			return err
+		}
+		resp.Provider = provider.Name()
+		completionResp = resp
+		recordUsage(ctx, resp.Provider, resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+		return nil
+	}, nil)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to create HTTP request")
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		logrus.WithError(err).Error("LLM request failed")
+		return nil, err
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.apiKey))
-
-	// Log request (without sensitive data)
+	// Log success
 	logrus.WithFields(logrus.Fields{
-		"model":    s.model,
-		"messages": len(messages),
-	}).Debug("Sending chat completion request to DeepSeek API")
+		"provider":    completionResp.Provider,
+		"tokens_used": completionResp.Usage.TotalTokens,
+		"model":       completionResp.Model,
+	}).Info("LLM request successful")
 
-	// Send request
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to send request to DeepSeek API")
-		return nil, fmt.Errorf("API request failed: %w", err)
+	for i := range completionResp.Choices {
+		completionResp.Choices[i].Message.Content = redactor.Restore(completionResp.Choices[i].Message.Content)
 	}
-	defer resp.Body.Close()
+	return completionResp, nil
+}
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to read response body")
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Check for error response
-	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
-			logrus.WithFields(logrus.Fields{
-				"status":  resp.StatusCode,
-				"message": errResp.Error.Message,
-				"type":    errResp.Error.Type,
-			}).Error("DeepSeek API returned error")
-			return nil, fmt.Errorf("API error: %s", errResp.Error.Message)
-		}
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+// redactMessages masks personal data in the outgoing messages
+func (s *LLMService) redactMessages(redactor *Redactor, messages []ChatMessage) []ChatMessage {
+	if !redactor.Enabled() {
+		return messages
 	}
+	messages = redactor.RedactMessages(messages)
+	if redactor.Count() > 0 {
+		logrus.WithField("entities", redactor.Count()).Debug("Redacted personal data from LLM request")
+	}
+	return messages
+}
 
-	// Parse response
-	var completionResp ChatCompletionResponse
-	if err := json.Unmarshal(body, &completionResp); err != nil {
-		logrus.WithError(err).WithField("body", string(body)).Error("Failed to parse response")
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+// AskQuestion is a helper method to ask a single question with optional context
+func (s *LLMService) AskQuestion(ctx context.Context, question string, systemPrompt string, contextMessages []ChatMessage) (string, error) {
+	// Call API
+	response, err := s.ChatCompletionContext(ctx, buildQuestionMessages(question, systemPrompt, contextMessages))
+	if err != nil {
+		return "", err
 	}
 
-	// Log success
-	logrus.WithFields(logrus.Fields{
-		"tokens_used": completionResp.Usage.TotalTokens,
-		"model":       completionResp.Model,
-	}).Info("DeepSeek API request successful")
+	// Extract the assistant's reply
+	if len(response.Choices) == 0 {
+		return "", errors.New("no response from API")
+	}
 
-	return &completionResp, nil
+	return response.Choices[0].Message.Content, nil
 }
 
-// AskQuestion is a helper method to ask a single question with optional context
-func (s *LLMService) AskQuestion(question string, systemPrompt string, context []ChatMessage) (string, error) {
+// buildQuestionMessages builds the message list for a question with optional context
+func buildQuestionMessages(question string, systemPrompt string, contextMessages []ChatMessage) []ChatMessage {
 	messages := []ChatMessage{}
 
 	// Add system prompt if provided
@@ -183,8 +194,8 @@ func (s *LLMService) AskQuestion(question string, systemPrompt string, context [
 	}
 
 	// Add context messages if provided
-	if len(context) > 0 {
-		messages = append(messages, context...)
+	if len(contextMessages) > 0 {
+		messages = append(messages, contextMessages...)
 	}
 
 	// Add the user's question
@@ -193,13 +204,23 @@ func (s *LLMService) AskQuestion(question string, systemPrompt string, context [
 		Content: question,
 	})
 
+	return messages
+}
+
+// SummarizeConversation summarizes a conversation given the message history and
+// the summarization prompt (the rendered "summarize" template)
+func (s *LLMService) SummarizeConversation(ctx context.Context, messages []ChatMessage, prompt string) (string, error) {
+	if len(messages) == 0 {
+		return "", errors.New("no messages to summarize")
+	}
+
 	// Call API
-	response, err := s.ChatCompletion(messages)
+	response, err := s.ChatCompletionContext(ctx, buildSummaryMessages(messages, prompt))
 	if err != nil {
 		return "", err
 	}
 
-	// Extract the assistant's reply
+	// Extract the summary
 	if len(response.Choices) == 0 {
 		return "", errors.New("no response from API")
 	}
@@ -207,25 +228,8 @@ func (s *LLMService) AskQuestion(question string, systemPrompt string, context [
 	return response.Choices[0].Message.Content, nil
 }
 
-// SummarizeConversation summarizes a conversation given the message history
-func (s *LLMService) SummarizeConversation(messages []ChatMessage, language string) (string, error) {
-	if len(messages) == 0 {
-		return "", errors.New("no messages to summarize")
-	}
-
-	// Determine the prompt based on language
-	var prompt string
-	switch language {
-	case "zh", "zh-Hans", "zh_CN":
-		prompt = "请用中文总结以下对话的主要内容和关键要点："
-	case "ru":
-		prompt = "Пожалуйста, суммируйте основное содержание и ключевые моменты следующего разговора на русском языке:"
-	case "kk":
-		prompt = "Келесі әңгіменің негізгі мазмұны мен басты тұстарын қазақ тілінде қорытындылаңыз:"
-	default:
-		prompt = "Please summarize the main content and key points of the following conversation in English:"
-	}
-
+// buildSummaryMessages builds the message list for summarizing a conversation
+func buildSummaryMessages(messages []ChatMessage, prompt string) []ChatMessage {
 	// Build the summary request
 	summaryMessages := []ChatMessage{
 		{
@@ -237,17 +241,76 @@ func (s *LLMService) SummarizeConversation(messages []ChatMessage, language stri
 	// Add the conversation history
 	summaryMessages = append(summaryMessages, messages...)
 
-	// Call API
-	response, err := s.ChatCompletion(summaryMessages)
-	if err != nil {
-		return "", err
+	return summaryMessages
+}
+
+// DocumentChunk is a piece of extracted file text sent to the model
+type DocumentChunk struct {
+	FileID   uint
+	FileName string
+	Part     int // 1-based part number within the file
+	Parts    int // total parts of the file
+	Text     string
+}
+
+// documentAnalysisPrompt instructs the model to cite the source file of every point
+const documentAnalysisPrompt = "You are a legal assistant analyzing documents shared in a consultation chat. " +
+	"Answer the operator's question using only the provided documents. " +
+	"End every point with the source file in square brackets, e.g. [contract.pdf]. " +
+	"If the documents do not contain the answer, say so. Answer in the language of the question."
+
+// documentNotesPrompt is used for each excerpt when the documents do not fit into one request
+const documentNotesPrompt = "You are reading one excerpt of a document shared in a consultation chat. " +
+	"List the facts from this excerpt that are relevant to the operator's question as short bullet points. " +
+	"End every bullet with the source file in square brackets, e.g. [contract.pdf]. " +
+	"If nothing is relevant, answer with NONE."
+
+// AnalyzeDocuments answers a question about documents, citing the source file of each point.
+// Chunks that fit into a single request are sent together; otherwise each chunk is condensed
+// into cited notes first and the notes are combined into the final answer.
+func (s *LLMService) AnalyzeDocuments(ctx context.Context, question string, chunks []DocumentChunk, budget int) (string, error) {
+	if len(chunks) == 0 {
+		return "", errors.New("no documents to analyze")
 	}
 
-	// Extract the summary
-	if len(response.Choices) == 0 {
-		return "", errors.New("no response from API")
+	total := 0
+	for _, chunk := range chunks {
+		total += EstimateTokens(chunk.Text)
 	}
 
-	return response.Choices[0].Message.Content, nil
+	if total <= budget {
+		var sb strings.Builder
+		for _, chunk := range chunks {
+			sb.WriteString(formatDocumentChunk(chunk))
+		}
+		return s.AskQuestion(ctx, "Question: "+question+"\n\n"+sb.String(), documentAnalysisPrompt, nil)
+	}
+
+	var notes strings.Builder
+	for _, chunk := range chunks {
+		answer, err := s.AskQuestion(ctx, "Question: "+question+"\n\n"+formatDocumentChunk(chunk), documentNotesPrompt, nil)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(answer) == "NONE" {
+			continue
+		}
+		notes.WriteString(answer)
+		notes.WriteString("\n")
+	}
+
+	if notes.Len() == 0 {
+		return s.AskQuestion(ctx, "Question: "+question+"\n\nNo relevant information was found in the documents.", documentAnalysisPrompt, nil)
+	}
+
+	return s.AskQuestion(ctx, "Question: "+question+"\n\nNotes extracted from the documents:\n"+notes.String(), documentAnalysisPrompt, nil)
 }
 
+// formatDocumentChunk labels a chunk with its file name so the model can cite it
+func formatDocumentChunk(chunk DocumentChunk) string {
+	header := fmt.Sprintf("=== File: %s ===", chunk.FileName)
+	if chunk.Parts > 1 {
+		header = fmt.Sprintf("=== File: %s (part %d of %d) ===", chunk.FileName, chunk.Part, chunk.Parts)
+	}
+	return header + "\n" + chunk.Text + "\n\n"
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ChatCompletionChunk represents one SSE chunk of a streamed chat completion
+type ChatCompletionChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// DeltaHandler receives each content delta of a streamed completion.
+// Returning an error aborts the stream.
+type DeltaHandler func(delta string) error
+
+// ChatCompletionStream sends a streaming chat completion request and calls onDelta for
+// every content delta. It returns the full answer once the stream is finished.
+// The request is cancelled when ctx is done (e.g. the client disconnected).
+func (s *LLMService) ChatCompletionStream(ctx context.Context, messages []ChatMessage, onDelta DeltaHandler) (string, error) {
+	var answer string
+	var answeredBy Provider
+	emitted := false
+
+	redactor := NewRedactor(s.redact)
+	messages = s.redactMessages(redactor, messages)
+
+	err := s.call(ctx, func(ctx context.Context, provider Provider) error {
+		logrus.WithFields(logrus.Fields{
+			"provider": provider.Name(),
+			"model":    provider.Config().Model,
+			"messages": len(messages),
+		}).Debug("Sending streaming chat completion request")
+
+		restorer := &streamRestorer{redactor: redactor}
+		var err error
+		answer, err = provider.ChatCompletionStream(ctx, messages, func(delta string) error {
+			emitted = true
+			if delta = restorer.Write(delta); delta == "" {
+				return nil
+			}
+			return onDelta(delta)
+		})
+		answeredBy = provider
+		if err == nil {
+			if rest := restorer.Flush(); rest != "" {
+				err = onDelta(rest)
+			}
+		}
+		return err
+	}, func() bool {
+		// Deltas already sent to the client cannot be taken back
+		return !emitted
+	})
+	if err != nil {
+		logrus.WithError(err).Error("LLM streaming request failed")
+		return redactor.Restore(answer), err
+	}
+
+	// Streams carry no usage, record an estimate
+	recordUsage(ctx, answeredBy.Name(), answeredBy.Config().Model, countPromptTokens(messages), EstimateTokens(answer))
+	answer = redactor.Restore(answer)
+
+	logrus.WithFields(logrus.Fields{
+		"provider": answeredBy.Name(),
+		"model":    answeredBy.Config().Model,
+		"length":   len(answer),
+	}).Info("LLM streaming request successful")
+
+	return answer, nil
+}
+
+// AskQuestionStream is the streaming variant of AskQuestion
+func (s *LLMService) AskQuestionStream(ctx context.Context, question string, systemPrompt string, contextMessages []ChatMessage, onDelta DeltaHandler) (string, error) {
+	return s.ChatCompletionStream(ctx, buildQuestionMessages(question, systemPrompt, contextMessages), onDelta)
+}
+
+// SummarizeConversationStream is the streaming variant of SummarizeConversation
+func (s *LLMService) SummarizeConversationStream(ctx context.Context, messages []ChatMessage, prompt string, onDelta DeltaHandler) (string, error) {
+	if len(messages) == 0 {
+		return "", errors.New("no messages to summarize")
+	}
+	return s.ChatCompletionStream(ctx, buildSummaryMessages(messages, prompt), onDelta)
+}
+
+// readSSE reads a text/event-stream body and calls handle with the data of every event.
+// Multi-line data fields are joined with newlines; comments and other fields are ignored.
+// handle returns true to stop reading (e.g. on the [DONE] sentinel).
+func readSSE(r io.Reader, handle func(data string) (bool, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var data []string
+	dispatch := func() (bool, error) {
+		if len(data) == 0 {
+			return false, nil
+		}
+		payload := strings.Join(data, "\n")
+		data = data[:0]
+		return handle(payload)
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		// An empty line terminates the event
+		if line == "" {
+			done, err := dispatch()
+			if err != nil || done {
+				return err
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		if field == "data" {
+			data = append(data, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	// The stream may end without a trailing blank line
+	_, err := dispatch()
+	return err
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"kelisim-chat/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadSSE(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"single events", "data: a\n\ndata: b\n\n", []string{"a", "b"}},
+		{"multi-line data", "data: first\ndata: second\n\n", []string{"first\nsecond"}},
+		{"crlf line endings", "data: a\r\n\r\ndata: b\r\n\r\n", []string{"a", "b"}},
+		{"comments and other fields ignored", ": keep-alive\nevent: message\nid: 1\ndata: a\n\n", []string{"a"}},
+		{"no space after colon", "data:a\n\n", []string{"a"}},
+		{"stops at done", "data: a\n\ndata: [DONE]\n\ndata: b\n\n", []string{"a", "[DONE]"}},
+		{"no trailing blank line", "data: a\n\ndata: b", []string{"a", "b"}},
+		{"blank lines without data", "\n\ndata: a\n\n\n\n", []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := readSSE(strings.NewReader(tt.input), func(data string) (bool, error) {
+				got = append(got, data)
+				return data == "[DONE]", nil
+			})
+			if err != nil {
+				t.Fatalf("readSSE() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("readSSE() events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSSEHandlerError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := readSSE(strings.NewReader("data: a\n\ndata: b\n\n"), func(data string) (bool, error) {
+		calls++
+		return false, stop
+	})
+	if err != stop || calls != 1 {
+		t.Fatalf("readSSE() = %v after %d calls, want %v after 1 call", err, calls, stop)
+	}
+}
+
+func TestChatCompletionStreamForwardsDeltas(t *testing.T) {
+	var request ChatCompletionRequest
+	service := newStreamTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, ": connected\n\n")
+		io.WriteString(w, `data: {"choices":[{"delta":{"role":"assistant"}}]}`+"\n\n")
+		for _, delta := range []string{"Hello", ", ", "world"} {
+			io.WriteString(w, streamChunk(delta))
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	})
+
+	var deltas []string
+	answer, err := service.ChatCompletionStream(context.Background(), []ChatMessage{{Role: "user", Content: "hi"}}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream() error = %v", err)
+	}
+	if !request.Stream {
+		t.Fatalf("request stream = false, want true")
+	}
+	if want := []string{"Hello", ", ", "world"}; !reflect.DeepEqual(deltas, want) {
+		t.Fatalf("deltas = %q, want %q", deltas, want)
+	}
+	if answer != "Hello, world" {
+		t.Fatalf("answer = %q, want %q", answer, "Hello, world")
+	}
+}
+
+func TestChatCompletionStreamCutOff(t *testing.T) {
+	var requests int32
+	service := newStreamTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		// Promise more bytes than are sent, then drop the connection
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		chunk := streamChunk("partial")
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nContent-Length: 1000\r\n\r\n")
+		buf.WriteString(chunk)
+		buf.Flush()
+	})
+
+	var deltas []string
+	answer, err := service.ChatCompletionStream(context.Background(), []ChatMessage{{Role: "user", Content: "hi"}}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("ChatCompletionStream() error = nil, want an error for the truncated stream")
+	}
+	var llmErr *LLMError
+	if !errors.As(err, &llmErr) {
+		t.Fatalf("ChatCompletionStream() error = %T, want *LLMError", err)
+	}
+	if answer != "partial" || !reflect.DeepEqual(deltas, []string{"partial"}) {
+		t.Fatalf("answer = %q, deltas = %q, want the partial answer", answer, deltas)
+	}
+	// Deltas already reached the client, so the request must not be retried
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("requests = %d, want 1", n)
+	}
+}
+
+func TestChatCompletionStreamCancel(t *testing.T) {
+	cancelled := make(chan struct{})
+	service := newStreamTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, streamChunk("first"))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+			io.WriteString(w, streamChunk("too late"))
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var deltas []string
+	start := time.Now()
+	answer, err := service.ChatCompletionStream(ctx, []ChatMessage{{Role: "user", Content: "hi"}}, func(delta string) error {
+		deltas = append(deltas, delta)
+		cancel() // the client disconnected after the first delta
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("ChatCompletionStream() error = nil, want an error after cancellation")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("ChatCompletionStream() returned after %s, want it to stop on cancellation", elapsed)
+	}
+	if answer != "first" || !reflect.DeepEqual(deltas, []string{"first"}) {
+		t.Fatalf("answer = %q, deltas = %q, want only the first delta", answer, deltas)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("upstream request was not cancelled")
+	}
+}
+
+// newStreamTestService creates an LLMService backed by a local fake OpenAI-compatible server
+func newStreamTestService(t *testing.T, handler http.HandlerFunc) *LLMService {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config.AppConfig = &config.Config{
+		LLM: config.LLMConfig{MaxRetries: 2, RetryBaseDelay: 1, RetryMaxDelay: 10},
+	}
+	return NewLLMServiceWithProvider(NewOpenAIProvider(config.LLMProviderConfig{
+		APIKey:  "test",
+		APIBase: server.URL,
+		Model:   "test-model",
+		Timeout: 5,
+	}))
+}
+
+// streamChunk formats one SSE event carrying a content delta
+func streamChunk(content string) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"choices": []interface{}{
+			map[string]interface{}{"delta": map[string]string{"content": content}},
+		},
+	})
+	return "data: " + string(data) + "\n\n"
+}
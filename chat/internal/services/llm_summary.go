@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// rollingSummaryInstruction is appended to the summary prompt when a previous summary exists
+const rollingSummaryInstruction = "You are given the summary of the earlier conversation and the messages that followed it. " +
+	"Return the complete updated summary of the whole conversation, not only of the new messages. " +
+	"Keep names, dates, amounts, decisions and open questions from the earlier summary unless the new messages change them."
+
+// UpdateSummary extends a rolling summary with new messages (a transcript from FormatTranscript, oldest first)
+// using the summarization prompt (the rendered "summarize" template).
+// Only the previous summary and the new messages are sent. When the new messages do not fit into one
+// request, the oldest ones that fit are summarized and the number of messages covered is returned;
+// the caller continues with the rest.
+func (s *LLMService) UpdateSummary(ctx context.Context, previous string, newMessages []ChatMessage, summaryPrompt string) (string, int, error) {
+	if len(newMessages) == 0 {
+		return previous, 0, nil
+	}
+
+	prompt := []ChatMessage{{Role: "system", Content: summaryPrompt}}
+	if previous != "" {
+		prompt[0].Content += "\n" + rollingSummaryInstruction
+		prompt = append(prompt, ChatMessage{
+			Role:    "system",
+			Content: "Summary of the earlier conversation:\n" + previous,
+		})
+	}
+
+	cfg := s.backends[0].provider.Config()
+	budget := cfg.ContextWindow - cfg.MaxTokens - countPromptTokens(prompt)
+
+	// Always make progress, even if a single message exceeds the budget
+	covered := fitOldest(newMessages, budget)
+	if covered == 0 {
+		covered = 1
+	}
+
+	resp, err := s.ChatCompletionContext(ctx, append(prompt, newMessages[:covered]...))
+	if err != nil {
+		return "", 0, err
+	}
+	if len(resp.Choices) == 0 {
+		return "", 0, errors.New("no response from API")
+	}
+
+	return strings.TrimSpace(resp.Choices[0].Message.Content), covered, nil
+}
+
+// fitOldest returns how many messages from the start of messages fit into budget
+func fitOldest(messages []ChatMessage, budget int) int {
+	used := 0
+	for i, message := range messages {
+		used += EstimateTokens(message.Content) + messageTokenOverhead
+		if used > budget {
+			return i
+		}
+	}
+	return len(messages)
+}
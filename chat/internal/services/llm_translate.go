@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// translationPrompt asks for a plain translation into the target language
+const translationPrompt = "You translate chat messages on a legal consultation platform into %s. " +
+	"Translate the user's message faithfully, keeping names, numbers, dates, amounts and legal terms exact. " +
+	"Do not answer or comment on the message. Reply with only the translation."
+
+// Translate translates text into the target language (a SupportedLanguages code)
+func (s *LLMService) Translate(ctx context.Context, text string, target string) (string, error) {
+	languageName, ok := SupportedLanguages[target]
+	if !ok {
+		return "", fmt.Errorf("unsupported language: %s", target)
+	}
+
+	messages := []ChatMessage{
+		{Role: "system", Content: fmt.Sprintf(translationPrompt, languageName)},
+		{Role: "user", Content: text},
+	}
+
+	resp, err := s.ChatCompletionContext(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("no response from API")
+	}
+
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// LLMUsage accumulates the token usage of all completions made with a tracked context
+type LLMUsage struct {
+	mu               sync.Mutex
+	Provider         string // provider of the last completion
+	Model            string // model of the last completion
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+}
+
+type usageKey struct{}
+
+// WithUsage returns a context whose completions are added to usage
+func WithUsage(ctx context.Context, usage *LLMUsage) context.Context {
+	return context.WithValue(ctx, usageKey{}, usage)
+}
+
+// Snapshot returns a copy of the accumulated usage
+func (u *LLMUsage) Snapshot() LLMUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return LLMUsage{
+		Provider:         u.Provider,
+		Model:            u.Model,
+		Calls:            u.Calls,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+	}
+}
+
+// recordUsage adds a completion to the usage tracked by ctx, if any
+func recordUsage(ctx context.Context, provider string, model string, promptTokens int, completionTokens int) {
+	usage, ok := ctx.Value(usageKey{}).(*LLMUsage)
+	if !ok {
+		return
+	}
+
+	usage.mu.Lock()
+	defer usage.mu.Unlock()
+
+	usage.Provider = provider
+	usage.Model = model
+	usage.Calls++
+	usage.PromptTokens += promptTokens
+	usage.CompletionTokens += completionTokens
+}
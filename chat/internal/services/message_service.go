@@ -17,7 +17,8 @@ func NewMessageService() *MessageService {
 }
 
 // SendMessage 发送消息
-func (s *MessageService) SendMessage(chatID uint, senderID *uint, messageType string, content *string, fileURL *string, fileName *string, fileSize *int64) (*models.Message, error) {
+// Operator 发送时 senderID 为 nil，operator 记录发送的 Operator；参与者发送时 operator 为 nil
+func (s *MessageService) SendMessage(chatID uint, senderID *uint, operator *models.MessageOperator, messageType string, content *string, fileURL *string, fileName *string, fileSize *int64) (*models.Message, error) {
 	// 开始事务
 	tx := database.DB.Begin()
 	defer func() {
@@ -38,6 +39,10 @@ func (s *MessageService) SendMessage(chatID uint, senderID *uint, messageType st
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
+	if operator != nil {
+		message.OperatorID = &operator.ID
+		message.OperatorName = &operator.Name
+	}
 
 	if err := tx.Create(message).Error; err != nil {
 		tx.Rollback()
@@ -45,11 +50,9 @@ func (s *MessageService) SendMessage(chatID uint, senderID *uint, messageType st
 	}
 
 	// 如果是文件消息，创建文件记录
-	if messageType == "document" || messageType == "image" {
-		fileType := "document"
-		if messageType == "image" {
-			fileType = "image"
-		}
+	if messageType == "document" || messageType == "image" || messageType == "video" {
+		// 文件类型与消息类型一一对应
+		fileType := messageType
 
 		chatFile := &models.ChatFile{
 			ChatID:     chatID,
@@ -58,10 +61,21 @@ func (s *MessageService) SendMessage(chatID uint, senderID *uint, messageType st
 			FileURL:    *fileURL,
 			FileName:   *fileName,
 			FileSize:   *fileSize,
-			UploadedBy: *senderID,
+			UploadedBy: senderID,
+			OperatorID: message.OperatorID,
+			ScanStatus: ScanStatusClean,
 			CreatedAt:  time.Now(),
 		}
 
+		// 去重存储的文件关联内容哈希，内容尚未确认安全时等待病毒扫描
+		var blob models.FileBlob
+		if err := tx.Where("file_url = ?", *fileURL).First(&blob).Error; err == nil {
+			chatFile.ContentHash = &blob.ContentHash
+			if blob.ScanStatus != ScanStatusClean {
+				chatFile.ScanStatus = ScanStatusPending
+			}
+		}
+
 		if err := tx.Create(chatFile).Error; err != nil {
 			tx.Rollback()
 			return nil, err
@@ -87,12 +101,56 @@ func (s *MessageService) SendMessage(chatID uint, senderID *uint, messageType st
 	return message, nil
 }
 
+// SendAIAnswer 保存参与者向 AI 助手提问的回答，问题和提问者记录在 ai_assistant 消息上
+func (s *MessageService) SendAIAnswer(chatID uint, askerID uint, question string, answer string) (*models.Message, error) {
+	message := &models.Message{
+		ChatID:    chatID,
+		Type:      "ai_assistant",
+		Content:   &answer,
+		AskedBy:   &askerID,
+		Question:  &question,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	tx := database.DB.Begin()
+	if err := tx.Create(message).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 更新聊天室的更新时间
+	if err := tx.Model(&models.Chat{}).Where("id = ?", chatID).Update("updated_at", time.Now()).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	// 预加载关联数据
+	if err := database.DB.Preload("Asker").First(message, message.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
 // GetChatMessages 获取聊天室消息
-func (s *MessageService) GetChatMessages(chatID uint, limit int, offset int) ([]models.Message, error) {
+// 尚未扫描通过的文件消息只对上传者本人可见（viewerIsOperator 时 viewerID 为 operator_id），文件消息带 scan_status
+func (s *MessageService) GetChatMessages(chatID uint, viewerID uint, viewerIsOperator bool, limit int, offset int) ([]models.Message, error) {
 	var messages []models.Message
 
+	visibleFiles := ScannedOrOwnFilesCondition
+	if viewerIsOperator {
+		visibleFiles = ScannedOrOperatorFilesCondition
+	}
+
 	query := database.DB.Where("chat_id = ? AND deleted_at IS NULL", chatID).
+		Where(visibleFiles, viewerID).
 		Preload("Sender").
+		Preload("Asker").
 		Order("created_at ASC").
 		Limit(limit)
 
@@ -100,7 +158,96 @@ func (s *MessageService) GetChatMessages(chatID uint, limit int, offset int) ([]
 		query = query.Offset(offset)
 	}
 
-	err := query.Find(&messages).Error
+	if err := query.Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	return messages, s.fillScanStatus(messages)
+}
+
+// fillScanStatus 填充文件消息的扫描状态
+func (s *MessageService) fillScanStatus(messages []models.Message) error {
+	var messageIDs []uint
+	for _, message := range messages {
+		if message.FileURL != nil {
+			messageIDs = append(messageIDs, message.ID)
+		}
+	}
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	var files []models.ChatFile
+	if err := database.DB.Select("message_id, scan_status").
+		Where("message_id IN ?", messageIDs).
+		Find(&files).Error; err != nil {
+		return err
+	}
+
+	statuses := make(map[uint]string, len(files))
+	for _, file := range files {
+		statuses[file.MessageID] = file.ScanStatus
+	}
+	for i := range messages {
+		if status, ok := statuses[messages[i].ID]; ok {
+			messages[i].ScanStatus = status
+		}
+	}
+
+	return nil
+}
+
+// GetRecentMessages 获取聊天室最近的消息，按时间从旧到新排列
+func (s *MessageService) GetRecentMessages(chatID uint, limit int) ([]models.Message, error) {
+	var messages []models.Message
+
+	err := database.DB.Where("chat_id = ? AND deleted_at IS NULL", chatID).
+		Where(ScannedFilesCondition).
+		Preload("Sender").
+		Preload("Asker").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 反转为从旧到新
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return messages, nil
+}
+
+// GetMessagesAfter 获取聊天室中ID大于 afterID 的消息，按从旧到新排列，最多 limit 条
+// 在第一条等待扫描（或扫描失败待重试）的文件消息之前停止，调用方推进的位置不会越过它，扫描通过后仍能读到
+func (s *MessageService) GetMessagesAfter(chatID uint, afterID uint, limit int) ([]models.Message, error) {
+	var messages []models.Message
+
+	var unscanned []uint
+	if err := database.DB.Model(&models.ChatFile{}).
+		Joins("JOIN messages ON messages.id = chat_files.message_id AND messages.deleted_at IS NULL").
+		Where("chat_files.chat_id = ? AND chat_files.message_id > ? AND chat_files.scan_status IN ?", chatID, afterID, []string{ScanStatusPending, ScanStatusFailed}).
+		Order("chat_files.message_id ASC").
+		Limit(1).
+		Pluck("chat_files.message_id", &unscanned).Error; err != nil {
+		return nil, err
+	}
+
+	query := database.DB.Where("chat_id = ? AND id > ? AND deleted_at IS NULL", chatID, afterID)
+	if len(unscanned) > 0 {
+		query = query.Where("id < ?", unscanned[0])
+	}
+
+	err := query.
+		Where(ScannedFilesCondition).
+		Preload("Sender").
+		Preload("Asker").
+		Order("id ASC").
+		Limit(limit).
+		Find(&messages).Error
+
 	return messages, err
 }
 
@@ -110,6 +257,7 @@ func (s *MessageService) GetMessageByID(messageID uint) (*models.Message, error)
 
 	err := database.DB.Where("id = ? AND deleted_at IS NULL", messageID).
 		Preload("Sender").
+		Preload("Asker").
 		First(&message).Error
 
 	return &message, err
@@ -129,13 +277,26 @@ func (s *MessageService) MarkAsRead(messageID uint, userID uint) error {
 			Status:    "read",
 			UpdatedAt: time.Now(),
 		}
-		return database.DB.Create(&status).Error
+		if err := database.DB.Create(&status).Error; err != nil {
+			return err
+		}
 	} else if err != nil {
 		return err
+	} else if err := database.DB.Model(&status).Update("status", "read").Error; err != nil {
+		// 更新现有状态
+		return err
 	}
 
-	// 更新现有状态
-	return database.DB.Model(&status).Update("status", "read").Error
+	return touchParticipantRead(database.DB.Where("chat_id = (SELECT chat_id FROM messages WHERE id = ?)", messageID), userID)
+}
+
+// touchParticipantRead 更新参与者的 last_read_at 和 updated_at，
+// 未读数变化后其他设备的增量同步（updated_since）能取到该聊天室
+func touchParticipantRead(query *gorm.DB, userID uint) error {
+	now := time.Now()
+	return query.Model(&models.ChatParticipant{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"last_read_at": now, "updated_at": now}).Error
 }
 
 // MarkChatAsRead 标记整个聊天室为已读
@@ -180,7 +341,7 @@ func (s *MessageService) MarkChatAsRead(chatID uint, userID uint) error {
 		}
 	}
 
-	return nil
+	return touchParticipantRead(database.DB.Where("chat_id = ?", chatID), userID)
 }
 
 // DeleteMessage 删除消息（软删除）
@@ -193,7 +354,17 @@ func (s *MessageService) DeleteMessage(messageID uint, userID uint) error {
 	}
 
 	// 软删除消息
-	return database.DB.Model(&message).Update("deleted_at", time.Now()).Error
+	if err := database.DB.Model(&message).Update("deleted_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	// 最后一条消息可能变化，更新聊天室的更新时间供增量同步
+	if err := database.DB.Model(&models.Chat{}).Where("id = ?", message.ChatID).Update("updated_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	// 释放文件引用，没有其他消息引用时删除文件
+	return NewFileService().ReleaseMessageFiles(message.ID)
 }
 
 // GetUnreadCount 获取用户未读消息数量
@@ -217,6 +388,7 @@ func (s *MessageService) GetUnreadCount(userID uint) (int64, error) {
 	// 计算未读消息数量
 	err = database.DB.Model(&models.Message{}).
 		Where("chat_id IN ? AND deleted_at IS NULL", chatIDs).
+		Where(ScannedFilesCondition).
 		Where("id NOT IN (SELECT message_id FROM message_status WHERE user_id = ? AND status = 'read')", userID).
 		Count(&count).Error
 
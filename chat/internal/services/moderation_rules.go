@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// moderationRule 关键词规则：匹配任一词干即标记
+type moderationRule struct {
+	category string
+	severity string
+	pattern  *regexp.Regexp
+}
+
+// newModerationRule 把词干编译成不区分大小写的正则
+// Go 的 \b 只识别 ASCII 字母，这里用 [^\p{L}] 作为词首边界，词干之后允许任意词尾
+func newModerationRule(category, severity string, stems ...string) moderationRule {
+	quoted := make([]string, len(stems))
+	for i, stem := range stems {
+		quoted[i] = strings.ReplaceAll(regexp.QuoteMeta(stem), " ", `\s+`)
+	}
+	return moderationRule{
+		category: category,
+		severity: severity,
+		pattern:  regexp.MustCompile(`(?i)(?:^|[^\p{L}])(` + strings.Join(quoted, "|") + `)`),
+	}
+}
+
+// moderationRules 俄语、哈萨克语和英语的规则，按严重程度从高到低排列
+var moderationRules = []moderationRule{
+	newModerationRule(FlagCategorySelfHarm, FlagSeverityHigh,
+		"покончить с собой", "покончу с собой", "убить себя", "убью себя", "наложить на себя руки", "наложу на себя руки",
+		"не хочу жить", "не хочется жить", "повешусь", "повеситься", "вскрою вены", "вскрыть вены", "суицид", "выпрыгну из окна",
+		"өзімді өлтір", "өмір сүргім келмейді", "асылып өл", "өзіме қол салам",
+		"kill myself", "end my life", "suicide", "don't want to live", "hurt myself"),
+	newModerationRule(FlagCategoryAbuse, FlagSeverityHigh,
+		"убью тебя", "убью вас", "убью его", "убью её", "урою", "прибью", "закопаю",
+		"сені өлтірем", "сендерді өлтірем",
+		"i will kill you", "i'll kill you"),
+	newModerationRule(FlagCategoryIllegal, FlagSeverityMedium,
+		"подделать", "сделать поддельн", "сделать липов", "дать взятку", "дать на лапу", "занести деньги",
+		"отмыть деньги", "обналичить", "скрыть имущество", "спрятать имущество", "уйти от налог", "уклониться от налог",
+		"купить диплом", "купить справку", "купить наркотик",
+		"жалған құжат жаса", "пара бер",
+		"make a fake", "forge a", "forge the", "bribe", "launder", "hide assets", "hide my assets", "evade tax"),
+	newModerationRule(FlagCategoryAbuse, FlagSeverityMedium,
+		"сука", "тварь", "мразь", "ублюд", "дебил", "идиот", "кретин", "козел", "козёл",
+		"ақымақ", "оңбаған", "итбалық",
+		"fuck", "bitch", "idiot", "moron", "asshole", "bastard"),
+}
+
+// ruleMatch 规则匹配结果
+type ruleMatch struct {
+	Category string
+	Severity string
+	Reason   string
+}
+
+// matchModerationRules 用规则检查文本，每个类别只返回严重程度最高的一次匹配
+func matchModerationRules(text string) []ruleMatch {
+	var matches []ruleMatch
+	seen := make(map[string]bool)
+	for _, rule := range moderationRules {
+		if seen[rule.category] {
+			continue
+		}
+		found := rule.pattern.FindStringSubmatch(text)
+		if found == nil {
+			continue
+		}
+		seen[rule.category] = true
+		matches = append(matches, ruleMatch{
+			Category: rule.category,
+			Severity: rule.severity,
+			Reason:   fmt.Sprintf("matched %q", strings.ToLower(found[1])),
+		})
+	}
+	return matches
+}
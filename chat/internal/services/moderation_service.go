@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"kelisim-chat/internal/config"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 风险类别
+const (
+	FlagCategorySelfHarm = "self_harm"
+	FlagCategoryIllegal  = "illegal"
+	FlagCategoryAbuse    = "abuse"
+)
+
+// 严重程度
+const (
+	FlagSeverityLow    = "low"
+	FlagSeverityMedium = "medium"
+	FlagSeverityHigh   = "high"
+)
+
+// 标记状态
+const (
+	FlagStatusOpen         = "open"
+	FlagStatusAcknowledged = "acknowledged"
+	FlagStatusDismissed    = "dismissed"
+)
+
+// 标记来源
+const (
+	FlagSourceRules = "rules"
+	FlagSourceLLM   = "llm"
+)
+
+var (
+	// ErrFlagNotFound 标记不存在
+	ErrFlagNotFound = errors.New("flag not found")
+	// ErrFlagDismissed 已驳回的标记不能再处理
+	ErrFlagDismissed = errors.New("flag already dismissed")
+)
+
+// FlagFilter 标记列表的过滤条件，空值表示不过滤
+type FlagFilter struct {
+	Status   string
+	ChatID   uint
+	Category string
+	Severity string
+}
+
+// ModerationService 消息风险审核服务
+type ModerationService struct {
+	llm    *LLMService
+	useLLM bool
+}
+
+// NewModerationService 创建审核服务，llm 为 nil 时只使用规则
+func NewModerationService(llm *LLMService) *ModerationService {
+	return &ModerationService{
+		llm:    llm,
+		useLLM: llm != nil && config.AppConfig.Moderation.UseLLM,
+	}
+}
+
+// Enabled 是否开启审核
+func (s *ModerationService) Enabled() bool {
+	return config.AppConfig.Moderation.Enabled
+}
+
+// Moderate 对一条文本消息分类并保存标记，返回本次新增的标记
+// 规则先匹配；开启 LLM 时再由模型分类，同一类别取较高的严重程度。LLM 失败时只使用规则的结果
+func (s *ModerationService) Moderate(ctx context.Context, message *models.Message) ([]models.MessageFlag, error) {
+	if message.Type != "text" || message.Content == nil || strings.TrimSpace(*message.Content) == "" {
+		return nil, nil
+	}
+	text := *message.Content
+
+	flags := make(map[string]*models.MessageFlag)
+	var order []string
+	add := func(category, severity, source, reason string) {
+		flag, ok := flags[category]
+		if !ok {
+			flags[category] = &models.MessageFlag{
+				MessageID: message.ID,
+				ChatID:    message.ChatID,
+				Category:  category,
+				Severity:  severity,
+				Source:    source,
+				Reason:    truncateReason(reason),
+				Status:    FlagStatusOpen,
+			}
+			order = append(order, category)
+			return
+		}
+		if !strings.Contains(flag.Source, source) {
+			flag.Source += "," + source
+		}
+		if severityRank(severity) > severityRank(flag.Severity) {
+			flag.Severity = severity
+			flag.Reason = truncateReason(reason)
+		}
+	}
+
+	for _, match := range matchModerationRules(text) {
+		add(match.Category, match.Severity, FlagSourceRules, match.Reason)
+	}
+
+	if s.useLLM {
+		verdict, err := s.llm.ModerateMessage(ctx, text)
+		if err != nil {
+			logrus.WithError(err).Warnf("LLM moderation failed for message %d, using rules only", message.ID)
+		} else {
+			for _, label := range verdict.Flags {
+				add(label.Category, label.Severity, FlagSourceLLM, label.Reason)
+			}
+		}
+	}
+
+	var created []models.MessageFlag
+	for _, category := range order {
+		flag := flags[category]
+		// 重复审核同一条消息时不覆盖已有的标记和处理状态
+		result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(flag)
+		if result.Error != nil {
+			return created, result.Error
+		}
+		if result.RowsAffected > 0 {
+			created = append(created, *flag)
+		}
+	}
+	return created, nil
+}
+
+// ListFlags 获取标记列表（含消息和发送者），按时间倒序
+func (s *ModerationService) ListFlags(filter FlagFilter, limit int, offset int) ([]models.MessageFlag, int64, error) {
+	query := database.DB.Model(&models.MessageFlag{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.ChatID > 0 {
+		query = query.Where("chat_id = ?", filter.ChatID)
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.Severity != "" {
+		query = query.Where("severity = ?", filter.Severity)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var flags []models.MessageFlag
+	err := query.Preload("Message.Sender").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&flags).Error
+
+	return flags, total, err
+}
+
+// ReviewFlag Operator 确认（acknowledged）或驳回（dismissed）标记
+func (s *ModerationService) ReviewFlag(flagID uint, operatorID uint, status string) (*models.MessageFlag, error) {
+	var flag models.MessageFlag
+	if err := database.DB.First(&flag, flagID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, err
+	}
+	if flag.Status == FlagStatusDismissed {
+		return nil, ErrFlagDismissed
+	}
+
+	now := time.Now()
+	err := database.DB.Model(&flag).Updates(map[string]interface{}{
+		"status":      status,
+		"reviewed_by": operatorID,
+		"reviewed_at": now,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	flag.Status = status
+	flag.ReviewedBy = &operatorID
+	flag.ReviewedAt = &now
+	return &flag, nil
+}
+
+// severityRank 严重程度排序，未知值最低
+func severityRank(severity string) int {
+	switch severity {
+	case FlagSeverityHigh:
+		return 3
+	case FlagSeverityMedium:
+		return 2
+	case FlagSeverityLow:
+		return 1
+	}
+	return 0
+}
+
+func truncateReason(reason string) string {
+	reason = strings.TrimSpace(reason)
+	if runes := []rune(reason); len(runes) > 500 {
+		reason = string(runes[:500])
+	}
+	return reason
+}
@@ -180,32 +180,8 @@ func (s *NotificationService) SendChatMessageNotification(message *models.Messag
 		return fmt.Errorf("message sender is nil")
 	}
 
-	senderName := message.Sender.GetFullName()
-	if senderName == "" {
-		// Email 是指针类型，需要检查并解引用
-		if message.Sender.Email != nil {
-			senderName = *message.Sender.Email
-		} else {
-			senderName = "未知用户"
-		}
-	}
-
-	title := fmt.Sprintf("来自 %s 的新消息", senderName)
-	body := ""
-
-	// 根据消息类型设置内容
-	switch message.Type {
-	case "text":
-		if message.Content != nil {
-			body = *message.Content
-		}
-	case "image":
-		body = "[图片]"
-	case "document":
-		body = "[文档]"
-	default:
-		body = "[消息]"
-	}
+	// 按聊天语言生成标题和内容
+	title, body := chatMessageNotificationContent(message)
 
 	// 构建数据
 	data := map[string]interface{}{
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"fmt"
+	"kelisim-chat/internal/models"
+)
+
+// notificationTemplate 聊天消息推送通知的文字
+type notificationTemplate struct {
+	Title       string // 标题，%s 为发送者名称
+	UnknownUser string
+	Image       string
+	Video       string
+	Document    string
+	Message     string
+}
+
+// notificationTemplates 按聊天语言选择的推送模板
+var notificationTemplates = map[string]notificationTemplate{
+	"zh": {Title: "来自 %s 的新消息", UnknownUser: "未知用户", Image: "[图片]", Video: "[视频]", Document: "[文档]", Message: "[消息]"},
+	"ru": {Title: "Новое сообщение от %s", UnknownUser: "Неизвестный пользователь", Image: "[Фото]", Video: "[Видео]", Document: "[Документ]", Message: "[Сообщение]"},
+	"kk": {Title: "%s жіберген жаңа хабарлама", UnknownUser: "Белгісіз пайдаланушы", Image: "[Сурет]", Video: "[Бейне]", Document: "[Құжат]", Message: "[Хабарлама]"},
+	"en": {Title: "New message from %s", UnknownUser: "Unknown user", Image: "[Image]", Video: "[Video]", Document: "[Document]", Message: "[Message]"},
+}
+
+// defaultNotificationLanguage 聊天语言未检测到时使用的模板
+const defaultNotificationLanguage = "zh"
+
+// chatMessageNotificationContent 生成聊天消息通知的标题和内容，使用聊天室检测到的语言
+func chatMessageNotificationContent(message *models.Message) (string, string) {
+	template, ok := notificationTemplates[NewChatService().GetLanguage(message.ChatID)]
+	if !ok {
+		template = notificationTemplates[defaultNotificationLanguage]
+	}
+
+	senderName := message.Sender.GetFullName()
+	if senderName == "" {
+		// Email 是指针类型，需要检查并解引用
+		if message.Sender.Email != nil {
+			senderName = *message.Sender.Email
+		} else {
+			senderName = template.UnknownUser
+		}
+	}
+
+	title := fmt.Sprintf(template.Title, senderName)
+	body := ""
+
+	// 根据消息类型设置内容
+	switch message.Type {
+	case "text":
+		if message.Content != nil {
+			body = *message.Content
+		}
+	case "image":
+		body = template.Image
+	case "video":
+		body = template.Video
+	case "document":
+		body = template.Document
+	default:
+		body = template.Message
+	}
+
+	return title, body
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// PIIType is a kind of personal data that is masked before chat content leaves for the LLM provider
+type PIIType string
+
+const (
+	PIIPhone PIIType = "phone"
+	PIIIIN   PIIType = "iin"
+	PIIEmail PIIType = "email"
+	PIICard  PIIType = "card"
+	PIIIBAN  PIIType = "iban"
+)
+
+// piiDetector finds one kind of personal data. Matches are validated and normalized
+// so the same value written differently gets the same placeholder.
+type piiDetector struct {
+	kind      PIIType
+	pattern   *regexp.Regexp
+	valid     func(match string) bool
+	normalize func(match string) string
+}
+
+// Detectors run in this order; IBANs and card numbers go first so their digits are
+// not picked up as phones or IINs.
+var piiDetectors = []piiDetector{
+	{
+		kind:      PIIIBAN,
+		pattern:   regexp.MustCompile(`(?i)\bKZ\d{2}(?:\s?[0-9A-Z]{4}){4}\b`),
+		valid:     validIBAN,
+		normalize: func(s string) string { return strings.ToUpper(stripSpaces(s)) },
+	},
+	{
+		kind:      PIIEmail,
+		pattern:   regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+		normalize: strings.ToLower,
+	},
+	{
+		kind:      PIICard,
+		pattern:   regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid:     func(s string) bool { return validLuhn(digitsOnly(s)) },
+		normalize: digitsOnly,
+	},
+	{
+		kind:      PIIIIN,
+		pattern:   regexp.MustCompile(`\b\d{12}\b`),
+		valid:     validIIN,
+		normalize: digitsOnly,
+	},
+	{
+		kind:    PIIPhone,
+		pattern: regexp.MustCompile(`(?:\+7|\b[78])[\s(-]*\d{3}[\s)-]*\d{3}[\s-]*\d{2}[\s-]*\d{2}\b`),
+		normalize: func(s string) string {
+			// 8 701 ... and +7 701 ... are the same number
+			return "7" + digitsOnly(s)[1:]
+		},
+	},
+}
+
+var piiPlaceholderPattern = regexp.MustCompile(`\[(PHONE|IIN|EMAIL|CARD|IBAN)_\d+\]`)
+
+// maxPlaceholderLen bounds how much streamed text is held back waiting for a placeholder to close
+const maxPlaceholderLen = 16
+
+// ParsePIITypes parses a comma separated list such as "phone,iin,email"; "none" or an
+// empty list disables redaction, "all" enables every type. Unknown names are ignored.
+func ParsePIITypes(list string) map[PIIType]bool {
+	types := make(map[PIIType]bool)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "all" {
+			for _, d := range piiDetectors {
+				types[d.kind] = true
+			}
+			continue
+		}
+		for _, d := range piiDetectors {
+			if string(d.kind) == item {
+				types[d.kind] = true
+			}
+		}
+	}
+	return types
+}
+
+// Redactor replaces personal data with placeholders such as [PHONE_1] and puts the
+// original values back into the model's answer. A Redactor keeps its mapping, so one
+// instance must be used for the request and its answer.
+type Redactor struct {
+	types    map[PIIType]bool
+	byValue  map[string]string // kind + normalized value -> placeholder
+	original map[string]string // placeholder -> value as first written
+	counts   map[PIIType]int
+}
+
+// NewRedactor creates a redactor for the given types
+func NewRedactor(types map[PIIType]bool) *Redactor {
+	return &Redactor{
+		types:    types,
+		byValue:  make(map[string]string),
+		original: make(map[string]string),
+		counts:   make(map[PIIType]int),
+	}
+}
+
+// Enabled reports whether the redactor masks anything at all
+func (r *Redactor) Enabled() bool {
+	return len(r.types) > 0
+}
+
+// Count returns how many distinct values have been replaced
+func (r *Redactor) Count() int {
+	return len(r.original)
+}
+
+// Redact replaces the personal data in text with placeholders
+func (r *Redactor) Redact(text string) string {
+	for _, d := range piiDetectors {
+		if !r.types[d.kind] {
+			continue
+		}
+		text = d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.valid != nil && !d.valid(match) {
+				return match
+			}
+			return r.placeholder(d.kind, d.normalize(match), match)
+		})
+	}
+	return text
+}
+
+// RedactMessages returns a copy of messages with the personal data replaced
+func (r *Redactor) RedactMessages(messages []ChatMessage) []ChatMessage {
+	redacted := make([]ChatMessage, len(messages))
+	for i, msg := range messages {
+		redacted[i] = ChatMessage{Role: msg.Role, Content: r.Redact(msg.Content)}
+	}
+	return redacted
+}
+
+// Restore puts the original values back in place of the placeholders. Placeholders
+// the redactor did not issue are left as they are.
+func (r *Redactor) Restore(text string) string {
+	if len(r.original) == 0 {
+		return text
+	}
+	return piiPlaceholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if value, ok := r.original[placeholder]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+func (r *Redactor) placeholder(kind PIIType, key, match string) string {
+	key = string(kind) + ":" + key
+	if placeholder, ok := r.byValue[key]; ok {
+		return placeholder
+	}
+	r.counts[kind]++
+	placeholder := fmt.Sprintf("[%s_%d]", strings.ToUpper(string(kind)), r.counts[kind])
+	r.byValue[key] = placeholder
+	r.original[placeholder] = match
+	return placeholder
+}
+
+// streamRestorer restores placeholders in a streamed answer. A placeholder can be split
+// between deltas, so text from an unclosed "[" is held back until it is complete.
+type streamRestorer struct {
+	redactor *Redactor
+	pending  string
+}
+
+// Write returns the part of the stream that can be sent to the client
+func (s *streamRestorer) Write(delta string) string {
+	s.pending += delta
+	if i := strings.LastIndex(s.pending, "["); i >= 0 && !strings.Contains(s.pending[i:], "]") && len(s.pending)-i < maxPlaceholderLen {
+		out := s.redactor.Restore(s.pending[:i])
+		s.pending = s.pending[i:]
+		return out
+	}
+	out := s.redactor.Restore(s.pending)
+	s.pending = ""
+	return out
+}
+
+// Flush returns whatever is still held back at the end of the stream
+func (s *streamRestorer) Flush() string {
+	out := s.redactor.Restore(s.pending)
+	s.pending = ""
+	return out
+}
+
+// validIIN checks the control digit of a Kazakh individual identification number.
+// The digit is the weighted sum of the first 11 digits modulo 11; when that gives 10
+// a second set of weights is used, and a second 10 means the number is invalid.
+func validIIN(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+	d := make([]int, 12)
+	for i, c := range s {
+		d[i] = int(c - '0')
+	}
+	// 7th digit encodes century and sex
+	if d[6] > 6 {
+		return false
+	}
+	control := func(weights [11]int) int {
+		sum := 0
+		for i, w := range weights {
+			sum += d[i] * w
+		}
+		return sum % 11
+	}
+	check := control([11]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11})
+	if check == 10 {
+		check = control([11]int{3, 4, 5, 6, 7, 8, 9, 10, 11, 1, 2})
+		if check == 10 {
+			return false
+		}
+	}
+	return check == d[11]
+}
+
+// validLuhn checks a payment card number
+func validLuhn(digits string) bool {
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		n := int(digits[i] - '0')
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validIBAN checks the ISO 13616 mod-97 checksum
+func validIBAN(s string) bool {
+	iban := strings.ToUpper(stripSpaces(s))
+	var numeric strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' && c <= 'Z' {
+			numeric.WriteString(fmt.Sprint(int(c-'A') + 10))
+		} else {
+			numeric.WriteRune(c)
+		}
+	}
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func stripSpaces(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
@@ -0,0 +1,116 @@
+package services
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name    string
+		types   string
+		input   string
+		want    string
+		restore string // expected Restore result when it differs from input
+	}{
+		{"phone with plus", "all", "звоните +7 (701) 123-45-67", "звоните [PHONE_1]", ""},
+		{"phone with 8", "all", "мой номер 87011234567.", "мой номер [PHONE_1].", ""},
+		{"same phone written twice", "all", "+77011234567 или 8 701 123 45 67", "[PHONE_1] или [PHONE_1]", "+77011234567 или +77011234567"},
+		{"two phones", "all", "87011234567, 87021234567", "[PHONE_1], [PHONE_2]", ""},
+		{"valid iin", "all", "ИИН 900101300017", "ИИН [IIN_1]", ""},
+		{"iin with wrong checksum", "all", "ИИН 900101300018", "ИИН 900101300018", ""},
+		{"email", "all", "пишите на Aigerim.N@mail.kz", "пишите на [EMAIL_1]", ""},
+		{"card with spaces", "all", "карта 4111 1111 1111 1111", "карта [CARD_1]", ""},
+		{"card failing luhn", "all", "номер 4111 1111 1111 1112", "номер 4111 1111 1111 1112", ""},
+		{"iban", "all", "счёт KZ86125KZT5004100100", "счёт [IBAN_1]", ""},
+		{"iban grouped", "all", "счёт KZ86 125K ZT50 0410 0100", "счёт [IBAN_1]", ""},
+		{"iban with wrong checksum", "all", "счёт KZ87125KZT5004100100", "счёт KZ87125KZT5004100100", ""},
+		{"only selected types", "email", "a@b.kz +77011234567", "[EMAIL_1] +77011234567", ""},
+		{"disabled", "none", "a@b.kz +77011234567", "a@b.kz +77011234567", ""},
+		{"plain numbers untouched", "all", "договор №12 от 2024 года, сумма 150000", "договор №12 от 2024 года, сумма 150000", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRedactor(ParsePIITypes(tt.types))
+			got := r.Redact(tt.input)
+			if got != tt.want {
+				t.Fatalf("Redact(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			want := tt.input
+			if tt.restore != "" {
+				want = tt.restore
+			}
+			if restored := r.Restore(got); restored != want {
+				t.Fatalf("Restore(%q) = %q, want %q", got, restored, want)
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	r := NewRedactor(ParsePIITypes("all"))
+	r.Redact("+7 701 123 45 67, a@b.kz")
+
+	tests := []struct {
+		name   string
+		answer string
+		want   string
+	}{
+		{"known placeholders", "Позвоните [PHONE_1] или напишите [EMAIL_1]", "Позвоните +7 701 123 45 67 или напишите a@b.kz"},
+		{"unknown placeholder", "см. [PHONE_2]", "см. [PHONE_2]"},
+		{"no placeholders", "Спасибо", "Спасибо"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Restore(tt.answer); got != tt.want {
+				t.Fatalf("Restore(%q) = %q, want %q", tt.answer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamRestorer(t *testing.T) {
+	r := NewRedactor(ParsePIITypes("all"))
+	r.Redact("+77011234567")
+
+	tests := []struct {
+		name   string
+		deltas []string
+		want   string
+	}{
+		{"whole placeholder", []string{"Номер: [PHONE_1]", "."}, "Номер: +77011234567."},
+		{"split placeholder", []string{"Номер: [PH", "ONE", "_1] ok"}, "Номер: +77011234567 ok"},
+		{"unclosed bracket at end", []string{"см. [при", "мечание"}, "см. [примечание"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &streamRestorer{redactor: r}
+			var got string
+			for _, d := range tt.deltas {
+				got += s.Write(d)
+			}
+			got += s.Flush()
+			if got != tt.want {
+				t.Fatalf("stream = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidIIN(t *testing.T) {
+	tests := []struct {
+		iin  string
+		want bool
+	}{
+		{"900101300017", true},
+		{"900101300018", false},
+		{"900101900017", false},
+		{"90010130001", false},
+	}
+
+	for _, tt := range tests {
+		if got := validIIN(tt.iin); got != tt.want {
+			t.Errorf("validIIN(%q) = %v, want %v", tt.iin, got, tt.want)
+		}
+	}
+}
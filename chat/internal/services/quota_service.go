@@ -0,0 +1,302 @@
+package services
+
+import (
+	"fmt"
+	"kelisim-chat/internal/config"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// storageReservationTTL 预留的有效期，超过后视为上传已放弃（例如进程异常退出）
+const storageReservationTTL = time.Hour
+
+// QuotaExceededError 存储配额超限
+type QuotaExceededError struct {
+	Scope     string // organization 或 chat
+	ScopeID   uint
+	Limit     int64
+	Used      int64
+	Requested int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s %d storage quota exceeded: used %d + requested %d > limit %d",
+		e.Scope, e.ScopeID, e.Used, e.Requested, e.Limit)
+}
+
+// StorageUsage 存储用量统计
+type StorageUsage struct {
+	OrganizationID uint   `json:"organization_id,omitempty"`
+	ChatID         uint   `json:"chat_id,omitempty"`
+	Title          string `json:"title,omitempty"`
+	UsedBytes      int64  `json:"used_bytes"`
+	FileCount      int64  `json:"file_count"`
+	LimitBytes     int64  `json:"limit_bytes"` // 0 表示不限制
+}
+
+// QuotaService 存储配额服务
+// 用量按未删除消息的 chat_files.file_size 统计（去重存储不影响配额）
+// 聊天室的用量计入创建者所在的组织；上传中的文件通过 storage_reservations 预留的空间也计入用量
+type QuotaService struct{}
+
+// NewQuotaService 创建存储配额服务
+func NewQuotaService() *QuotaService {
+	return &QuotaService{}
+}
+
+// CheckUpload 检查上传指定大小的文件后是否超过聊天室或组织配额（不预留，用于提前拒绝）
+// 实际写入文件前需要调用 ReserveUpload
+func (s *QuotaService) CheckUpload(chatID uint, size int64) error {
+	var orgIDs []uint
+	if config.AppConfig.Storage.OrgQuota > 0 {
+		ids, err := s.GetChatOrganizationIDs(chatID)
+		if err != nil {
+			return err
+		}
+		orgIDs = ids
+	}
+
+	return s.checkQuota(chatID, orgIDs, size)
+}
+
+// ReserveUpload 检查配额并为即将写入的文件预留空间，返回预留ID（未启用配额时为 0）
+// 检查和预留在锁定聊天室和组织配额行的事务中完成，并发上传依次检查，不会一起超出配额
+// 文件消息创建后（chat_files 已计入用量）或上传失败时调用 ReleaseReservation 释放
+func (s *QuotaService) ReserveUpload(chatID uint, size int64) (uint, error) {
+	if config.AppConfig.Storage.ChatQuota <= 0 && config.AppConfig.Storage.OrgQuota <= 0 {
+		return 0, nil
+	}
+
+	var orgIDs []uint
+	if config.AppConfig.Storage.OrgQuota > 0 {
+		ids, err := s.GetChatOrganizationIDs(chatID)
+		if err != nil {
+			return 0, err
+		}
+		orgIDs = ids
+		sort.Slice(orgIDs, func(i, j int) bool { return orgIDs[i] < orgIDs[j] })
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 按固定顺序（聊天室，然后按ID排序的组织）锁定配额行，避免死锁
+	locks := []models.StorageQuotaLock{{Scope: "chat", ScopeID: chatID}}
+	for _, orgID := range orgIDs {
+		locks = append(locks, models.StorageQuotaLock{Scope: "organization", ScopeID: orgID})
+	}
+	for _, lock := range locks {
+		if err := lockStorageQuota(tx, lock); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err := s.checkQuota(chatID, orgIDs, size); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	reservation := models.StorageReservation{
+		ChatID:    chatID,
+		Size:      size,
+		ExpiresAt: time.Now().Add(storageReservationTTL),
+	}
+	if err := tx.Create(&reservation).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+
+	return reservation.ID, nil
+}
+
+// ReleaseReservation 释放 ReserveUpload 预留的空间
+func (s *QuotaService) ReleaseReservation(reservationID uint) error {
+	if reservationID == 0 {
+		return nil
+	}
+	return database.DB.Delete(&models.StorageReservation{}, reservationID).Error
+}
+
+// CleanupExpiredReservations 删除已失效的预留（失效的预留已不计入用量）
+func (s *QuotaService) CleanupExpiredReservations() (int64, error) {
+	result := database.DB.Where("expires_at < ?", time.Now()).Delete(&models.StorageReservation{})
+	return result.RowsAffected, result.Error
+}
+
+// checkQuota 检查聊天室和组织的已用量（含有效的预留）加上 size 后是否超过配额
+func (s *QuotaService) checkQuota(chatID uint, orgIDs []uint, size int64) error {
+	if limit := config.AppConfig.Storage.ChatQuota; limit > 0 {
+		used, err := s.GetChatUsage(chatID)
+		if err != nil {
+			return err
+		}
+		reserved, err := s.reservedBytes(database.DB.Model(&models.StorageReservation{}).
+			Where("storage_reservations.chat_id = ?", chatID))
+		if err != nil {
+			return err
+		}
+		if used.UsedBytes+reserved+size > limit {
+			return &QuotaExceededError{Scope: "chat", ScopeID: chatID, Limit: limit, Used: used.UsedBytes + reserved, Requested: size}
+		}
+	}
+
+	if limit := config.AppConfig.Storage.OrgQuota; limit > 0 {
+		for _, orgID := range orgIDs {
+			used, err := s.GetOrganizationUsage(orgID)
+			if err != nil {
+				return err
+			}
+			reserved, err := s.reservedBytes(database.DB.Model(&models.StorageReservation{}).
+				Joins("JOIN chats ON chats.id = storage_reservations.chat_id").
+				Joins("JOIN organization_members ON organization_members.user_id = chats.created_by").
+				Where("organization_members.organization_id = ?", orgID))
+			if err != nil {
+				return err
+			}
+			if used.UsedBytes+reserved+size > limit {
+				return &QuotaExceededError{Scope: "organization", ScopeID: orgID, Limit: limit, Used: used.UsedBytes + reserved, Requested: size}
+			}
+		}
+	}
+
+	return nil
+}
+
+// reservedBytes 统计查询范围内尚未失效的预留字节数
+func (s *QuotaService) reservedBytes(query *gorm.DB) (int64, error) {
+	var reserved int64
+	err := query.
+		Select("COALESCE(SUM(storage_reservations.size), 0)").
+		Where("storage_reservations.expires_at > ?", time.Now()).
+		Row().Scan(&reserved)
+	return reserved, err
+}
+
+// lockStorageQuota 在事务中锁定配额行（不存在时先创建）
+func lockStorageQuota(tx *gorm.DB, lock models.StorageQuotaLock) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock).Error; err != nil {
+		return err
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("scope = ? AND scope_id = ?", lock.Scope, lock.ScopeID).
+		First(&models.StorageQuotaLock{}).Error
+}
+
+// GetChatOrganizationIDs 获取聊天室所属的组织（创建者所在的组织）
+func (s *QuotaService) GetChatOrganizationIDs(chatID uint) ([]uint, error) {
+	var orgIDs []uint
+	err := database.DB.Table("organization_members").
+		Joins("JOIN chats ON chats.created_by = organization_members.user_id").
+		Where("chats.id = ?", chatID).
+		Distinct().
+		Pluck("organization_members.organization_id", &orgIDs).Error
+	return orgIDs, err
+}
+
+// GetUserOrganizationIDs 获取用户所在的组织
+func (s *QuotaService) GetUserOrganizationIDs(userID uint) ([]uint, error) {
+	var orgIDs []uint
+	err := database.DB.Table("organization_members").
+		Where("user_id = ?", userID).
+		Distinct().
+		Pluck("organization_id", &orgIDs).Error
+	return orgIDs, err
+}
+
+// GetChatUsage 获取聊天室的存储用量
+func (s *QuotaService) GetChatUsage(chatID uint) (*StorageUsage, error) {
+	usage := &StorageUsage{ChatID: chatID, LimitBytes: config.AppConfig.Storage.ChatQuota}
+	err := s.usageQuery().
+		Where("chat_files.chat_id = ?", chatID).
+		Row().Scan(&usage.UsedBytes, &usage.FileCount)
+	return usage, err
+}
+
+// GetOrganizationUsage 获取组织的存储用量
+func (s *QuotaService) GetOrganizationUsage(orgID uint) (*StorageUsage, error) {
+	usage := &StorageUsage{OrganizationID: orgID, LimitBytes: config.AppConfig.Storage.OrgQuota}
+	err := s.usageQuery().
+		Joins("JOIN chats ON chats.id = chat_files.chat_id").
+		Joins("JOIN organization_members ON organization_members.user_id = chats.created_by").
+		Where("organization_members.organization_id = ?", orgID).
+		Row().Scan(&usage.UsedBytes, &usage.FileCount)
+	return usage, err
+}
+
+// GetOrganizationChatUsage 获取组织内各聊天室的用量（按用量降序）
+func (s *QuotaService) GetOrganizationChatUsage(orgID uint, limit int) ([]StorageUsage, error) {
+	var usages []StorageUsage
+	err := s.chatUsageQuery().
+		Joins("JOIN organization_members ON organization_members.user_id = chats.created_by").
+		Where("organization_members.organization_id = ?", orgID).
+		Limit(limit).
+		Scan(&usages).Error
+
+	for i := range usages {
+		usages[i].LimitBytes = config.AppConfig.Storage.ChatQuota
+	}
+	return usages, err
+}
+
+// GetTopOrganizations 获取用量最大的组织
+func (s *QuotaService) GetTopOrganizations(limit int) ([]StorageUsage, error) {
+	var usages []StorageUsage
+	err := database.DB.Model(&models.ChatFile{}).
+		Select("organization_members.organization_id, COALESCE(SUM(chat_files.file_size), 0) AS used_bytes, COUNT(chat_files.id) AS file_count").
+		Joins("JOIN messages ON messages.id = chat_files.message_id AND messages.deleted_at IS NULL").
+		Joins("JOIN chats ON chats.id = chat_files.chat_id").
+		Joins("JOIN organization_members ON organization_members.user_id = chats.created_by").
+		Group("organization_members.organization_id").
+		Order("used_bytes DESC").
+		Limit(limit).
+		Scan(&usages).Error
+
+	for i := range usages {
+		usages[i].LimitBytes = config.AppConfig.Storage.OrgQuota
+	}
+	return usages, err
+}
+
+// GetTopChats 获取用量最大的聊天室
+func (s *QuotaService) GetTopChats(limit int) ([]StorageUsage, error) {
+	var usages []StorageUsage
+	err := s.chatUsageQuery().
+		Limit(limit).
+		Scan(&usages).Error
+
+	for i := range usages {
+		usages[i].LimitBytes = config.AppConfig.Storage.ChatQuota
+	}
+	return usages, err
+}
+
+// usageQuery 统计未删除消息的文件大小和数量
+func (s *QuotaService) usageQuery() *gorm.DB {
+	return database.DB.Model(&models.ChatFile{}).
+		Select("COALESCE(SUM(chat_files.file_size), 0), COUNT(chat_files.id)").
+		Joins("JOIN messages ON messages.id = chat_files.message_id AND messages.deleted_at IS NULL")
+}
+
+// chatUsageQuery 按聊天室分组统计用量
+func (s *QuotaService) chatUsageQuery() *gorm.DB {
+	return database.DB.Model(&models.ChatFile{}).
+		Select("chat_files.chat_id, chats.title, COALESCE(SUM(chat_files.file_size), 0) AS used_bytes, COUNT(chat_files.id) AS file_count").
+		Joins("JOIN messages ON messages.id = chat_files.message_id AND messages.deleted_at IS NULL").
+		Joins("JOIN chats ON chats.id = chat_files.chat_id").
+		Group("chat_files.chat_id, chats.title").
+		Order("used_bytes DESC")
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"kelisim-chat/internal/config"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// ScanResult 病毒扫描结果
+type ScanResult struct {
+	Infected  bool
+	Signature string // 命中的病毒特征名称
+}
+
+// Scanner 文件病毒扫描接口
+type Scanner interface {
+	// Name 扫描器名称，用于日志
+	Name() string
+	// Scan 扫描本地文件
+	Scan(path string) (*ScanResult, error)
+}
+
+// NewScanner 根据配置创建扫描器
+func NewScanner() Scanner {
+	cfg := config.AppConfig.Scanner
+
+	switch cfg.Driver {
+	case "clamav":
+		return &ClamAVScanner{
+			Address: cfg.ClamAVAddress,
+			Timeout: time.Duration(cfg.Timeout) * time.Second,
+		}
+	default:
+		return &NoopScanner{}
+	}
+}
+
+// NoopScanner 不做扫描，所有文件视为安全
+type NoopScanner struct{}
+
+// Name 扫描器名称
+func (s *NoopScanner) Name() string {
+	return "noop"
+}
+
+// Scan 直接返回未感染
+func (s *NoopScanner) Scan(path string) (*ScanResult, error) {
+	return &ScanResult{}, nil
+}
+
+// clamdChunkSize INSTREAM 每次发送的数据块大小
+const clamdChunkSize = 64 * 1024
+
+// ClamAVScanner 通过 clamd 的 INSTREAM 命令扫描文件
+// Address 格式：tcp://127.0.0.1:3310 或 unix:///var/run/clamav/clamd.ctl
+type ClamAVScanner struct {
+	Address string
+	Timeout time.Duration
+}
+
+// Name 扫描器名称
+func (s *ClamAVScanner) Name() string {
+	return "clamav"
+}
+
+// Scan 将文件内容流式发送给 clamd 并解析结果
+func (s *ClamAVScanner) Scan(path string) (*ScanResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	network, address := s.parseAddress()
+	conn, err := net.DialTimeout(network, address, s.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if s.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.Timeout))
+	}
+
+	// z 前缀表示命令和响应都以 \0 结尾
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to send command: %w", err)
+	}
+
+	// 每个数据块前是 4 字节大端长度，长度为 0 表示结束
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, werr := conn.Write(size); werr != nil {
+				return nil, fmt.Errorf("failed to send chunk: %w", werr)
+			}
+			if _, werr := conn.Write(buf[:n]); werr != nil {
+				return nil, fmt.Errorf("failed to send chunk: %w", werr)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return nil, fmt.Errorf("failed to finish stream: %w", err)
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reply: %w", err)
+	}
+
+	return parseClamdReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseAddress 解析 clamd 地址，未指定协议时按 TCP 处理
+func (s *ClamAVScanner) parseAddress() (string, string) {
+	if strings.HasPrefix(s.Address, "unix://") {
+		return "unix", strings.TrimPrefix(s.Address, "unix://")
+	}
+	return "tcp", strings.TrimPrefix(s.Address, "tcp://")
+}
+
+// parseClamdReply 解析响应，例如 "stream: OK" 或 "stream: Eicar-Signature FOUND"
+func parseClamdReply(reply string) (*ScanResult, error) {
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return &ScanResult{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(reply, " FOUND")
+		if idx := strings.Index(signature, ": "); idx >= 0 {
+			signature = signature[idx+2:]
+		}
+		return &ScanResult{Infected: true, Signature: signature}, nil
+	default:
+		// 例如 "INSTREAM size limit exceeded. ERROR"
+		return nil, fmt.Errorf("clamd error: %s", reply)
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm/clause"
+)
+
+// translationConcurrency 批量翻译时同时进行的 LLM 请求数
+const translationConcurrency = 4
+
+// ErrNothingToTranslate 消息没有可翻译的文字
+var ErrNothingToTranslate = errors.New("message has no text to translate")
+
+// TranslationService 消息翻译服务，译文缓存在 message_translations 表
+type TranslationService struct {
+	llmService *LLMService
+}
+
+// NewTranslationService 创建消息翻译服务
+func NewTranslationService(llmService *LLMService) *TranslationService {
+	return &TranslationService{
+		llmService: llmService,
+	}
+}
+
+// TranslateMessage 获取消息的译文，没有缓存时调用 LLM 翻译并保存
+// 原文已经是目标语言时直接返回原文，不保存
+func (s *TranslationService) TranslateMessage(ctx context.Context, message *models.Message, language string) (*models.MessageTranslation, error) {
+	text := translatableText(message)
+	if text == "" {
+		return nil, ErrNothingToTranslate
+	}
+
+	var cached models.MessageTranslation
+	if err := database.DB.Where("message_id = ? AND language = ?", message.ID, language).First(&cached).Error; err == nil {
+		return &cached, nil
+	}
+
+	translation := &models.MessageTranslation{
+		MessageID:      message.ID,
+		Language:       language,
+		SourceLanguage: DetectLanguage(text),
+	}
+	if translation.SourceLanguage == language {
+		translation.Content = text
+		return translation, nil
+	}
+
+	content, err := s.llmService.Translate(ctx, text, language)
+	if err != nil {
+		return nil, err
+	}
+	translation.Content = content
+
+	// 并发翻译同一条消息时以先保存的为准
+	if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(translation).Error; err != nil {
+		return nil, err
+	}
+	return translation, nil
+}
+
+// AttachCachedTranslations 为消息附加已缓存的目标语言译文（自动翻译模式），不调用 LLM
+// 返回还需要翻译的消息（副本）：有文字、原文不是目标语言且没有缓存的译文
+func (s *TranslationService) AttachCachedTranslations(messages []models.Message, language string) []models.Message {
+	var ids []uint
+	for _, message := range messages {
+		if translatableText(&message) != "" {
+			ids = append(ids, message.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var cached []models.MessageTranslation
+	if err := database.DB.Where("message_id IN ? AND language = ?", ids, language).Find(&cached).Error; err != nil {
+		logrus.WithError(err).Warn("Failed to load cached translations")
+	}
+	byMessage := make(map[uint]*models.MessageTranslation, len(cached))
+	for i := range cached {
+		byMessage[cached[i].MessageID] = &cached[i]
+	}
+
+	var missing []models.Message
+	for i := range messages {
+		message := &messages[i]
+		if translatableText(message) == "" {
+			continue
+		}
+		if translation, ok := byMessage[message.ID]; ok {
+			message.Translation = translation
+			continue
+		}
+		if DetectLanguage(translatableText(message)) == language {
+			continue
+		}
+		missing = append(missing, *message)
+	}
+	return missing
+}
+
+// GetCachedTranslations 获取消息在指定语言中已缓存的译文，按语言索引
+func (s *TranslationService) GetCachedTranslations(messageID uint, languages []string) (map[string]*models.MessageTranslation, error) {
+	byLanguage := make(map[string]*models.MessageTranslation)
+	if len(languages) == 0 {
+		return byLanguage, nil
+	}
+
+	var cached []models.MessageTranslation
+	if err := database.DB.Where("message_id = ? AND language IN ?", messageID, languages).Find(&cached).Error; err != nil {
+		return nil, err
+	}
+	for i := range cached {
+		byLanguage[cached[i].Language] = &cached[i]
+	}
+	return byLanguage, nil
+}
+
+// TranslateMessages 并发翻译消息（最多 translationConcurrency 个 LLM 请求），每条译文完成后调用 onTranslated
+// 翻译失败的消息只记录日志
+func (s *TranslationService) TranslateMessages(ctx context.Context, messages []models.Message, language string, onTranslated func(message *models.Message, translation *models.MessageTranslation)) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, translationConcurrency)
+	for i := range messages {
+		message := &messages[i]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			translation, err := s.TranslateMessage(ctx, message, language)
+			if err != nil {
+				logrus.WithError(err).Warnf("Failed to translate message %d", message.ID)
+				return
+			}
+			onTranslated(message, translation)
+		}()
+	}
+	wg.Wait()
+}
+
+// translatableText 返回消息中需要翻译的文字（文本消息的内容或文件的说明），系统消息不翻译
+func translatableText(message *models.Message) string {
+	if message.Type == "system" || message.Content == nil {
+		return ""
+	}
+	return strings.TrimSpace(*message.Content)
+}
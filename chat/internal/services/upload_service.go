@@ -0,0 +1,409 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"kelisim-chat/internal/config"
+	"kelisim-chat/internal/database"
+	"kelisim-chat/internal/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 分片上传相关错误
+var (
+	ErrUploadNotFound         = errors.New("upload session not found")
+	ErrUploadExpired          = errors.New("upload session expired")
+	ErrUploadTooLarge         = errors.New("file size exceeds limit")
+	ErrUploadInvalidChecksum  = errors.New("checksum must be a hex-encoded SHA-256")
+	ErrUploadOffsetMismatch   = errors.New("chunk offset does not match received bytes")
+	ErrUploadChunkTooLarge    = errors.New("chunk exceeds allowed size")
+	ErrUploadIncomplete       = errors.New("upload is not complete")
+	ErrUploadChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// UploadService 分片上传服务
+type UploadService struct {
+	fileService    *FileService
+	messageService *MessageService
+	quotaService   *QuotaService
+}
+
+// NewUploadService 创建分片上传服务
+func NewUploadService() *UploadService {
+	return &UploadService{
+		fileService:    NewFileService(),
+		messageService: NewMessageService(),
+		quotaService:   NewQuotaService(),
+	}
+}
+
+// CreateSession 创建上传会话
+func (s *UploadService) CreateSession(chatID uint, userID uint, isOperator bool, fileName string, fileSize int64, checksum string) (*models.UploadSession, error) {
+	if fileSize <= 0 || fileSize > config.AppConfig.Storage.MaxUploadSize {
+		return nil, ErrUploadTooLarge
+	}
+
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		return nil, ErrUploadInvalidChecksum
+	}
+
+	if err := s.quotaService.CheckUpload(chatID, fileSize); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(s.tempDir(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	session := &models.UploadSession{
+		ID:         generateUploadID(),
+		ChatID:     chatID,
+		UserID:     userID,
+		IsOperator: isOperator,
+		FileName:   filepath.Base(fileName),
+		FileSize:   fileSize,
+		Checksum:   checksum,
+		Status:     "uploading",
+		ExpiresAt:  time.Now().Add(s.ttl()),
+	}
+
+	if err := database.DB.Create(session).Error; err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetSession 获取聊天室中的上传会话
+func (s *UploadService) GetSession(uploadID string, chatID uint) (*models.UploadSession, error) {
+	var session models.UploadSession
+	err := database.DB.Where("id = ? AND chat_id = ?", uploadID, chatID).First(&session).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Status == "uploading" && session.IsExpired() {
+		return nil, ErrUploadExpired
+	}
+
+	return &session, nil
+}
+
+// WriteChunk 在指定偏移量写入分片，偏移量必须等于已接收字节数
+// 分片先接收到单独的临时文件，再在锁定会话行（SELECT ... FOR UPDATE）的事务中追加到分片文件，
+// 同一会话的写入因此在多个实例之间也是串行的，且不会在接收请求体期间持有行锁
+func (s *UploadService) WriteChunk(session *models.UploadSession, offset int64, r io.Reader) (*models.UploadSession, error) {
+	if session.Status != "uploading" {
+		return session, nil
+	}
+	if offset != session.ReceivedBytes {
+		return session, ErrUploadOffsetMismatch
+	}
+
+	maxChunk := config.AppConfig.Storage.ChunkSize
+	if remaining := session.FileSize - offset; remaining < maxChunk {
+		maxChunk = remaining
+	}
+
+	chunkPath := filepath.Join(s.tempDir(), session.ID+"."+generateUploadID()+".chunk")
+	chunk, err := os.Create(chunkPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunk file: %w", err)
+	}
+	defer os.Remove(chunkPath)
+	defer chunk.Close()
+
+	n, err := io.Copy(chunk, io.LimitReader(r, maxChunk+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive chunk: %w", err)
+	}
+	if n > maxChunk {
+		return session, ErrUploadChunkTooLarge
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 锁定会话行后重新读取，确保 received_bytes 是最新的
+	if err := lockUploadSession(tx, session); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if session.Status != "uploading" {
+		tx.Rollback()
+		return session, nil
+	}
+
+	if offset != session.ReceivedBytes {
+		tx.Rollback()
+		return session, ErrUploadOffsetMismatch
+	}
+
+	if err := s.appendChunk(session.ID, offset, chunk); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	session.ReceivedBytes = offset + n
+	session.ExpiresAt = time.Now().Add(s.ttl())
+
+	if err := tx.Model(session).Updates(map[string]interface{}{
+		"received_bytes": session.ReceivedBytes,
+		"expires_at":     session.ExpiresAt,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// appendChunk 将已接收的分片写入分片文件的 offset 处
+func (s *UploadService) appendChunk(uploadID string, offset int64, chunk *os.File) error {
+	if _, err := chunk.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read chunk file: %w", err)
+	}
+
+	f, err := os.OpenFile(s.partPath(uploadID), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open part file: %w", err)
+	}
+	defer f.Close()
+
+	// 丢弃上次失败写入留下的多余数据
+	if err := f.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate part file: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek part file: %w", err)
+	}
+
+	if _, err := io.Copy(f, chunk); err != nil {
+		f.Truncate(offset)
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	return nil
+}
+
+// Finalize 校验并组装文件，然后通过 MessageService 创建文件消息
+// 已完成的会话直接返回之前创建的消息，便于客户端安全重试
+// 整个过程锁定会话行，并发的完成请求（包括其他实例上的）会等待并拿到同一条消息
+func (s *UploadService) Finalize(session *models.UploadSession, senderID *uint, operator *models.MessageOperator) (*models.Message, error) {
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := lockUploadSession(tx, session); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	message, err := s.finalizeLocked(tx, session, senderID, operator)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logrus.WithError(err).Warnf("Failed to mark upload session %s as completed", session.ID)
+	}
+
+	return message, nil
+}
+
+// finalizeLocked 组装文件并创建消息（调用方需在 tx 中锁定会话行）
+func (s *UploadService) finalizeLocked(tx *gorm.DB, session *models.UploadSession, senderID *uint, operator *models.MessageOperator) (*models.Message, error) {
+	if session.Status == "completed" && session.MessageID != nil {
+		return s.messageService.GetMessageByID(*session.MessageID)
+	}
+
+	if session.ReceivedBytes != session.FileSize {
+		return nil, ErrUploadIncomplete
+	}
+
+	partPath := s.partPath(session.ID)
+
+	// 校验 SHA-256
+	sum, err := sha256File(partPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash part file: %w", err)
+	}
+	if sum != session.Checksum {
+		return nil, ErrUploadChecksumMismatch
+	}
+
+	// 上传期间其他文件可能已占用配额，完成前检查并预留空间，文件消息创建（计入用量）或失败后释放
+	reservationID, err := s.quotaService.ReserveUpload(session.ChatID, session.FileSize)
+	if err != nil {
+		return nil, err
+	}
+	defer s.quotaService.ReleaseReservation(reservationID)
+
+	part, err := os.Open(partPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open part file: %w", err)
+	}
+	fileURL, _, err := s.fileService.StoreFile(part, session.FileName)
+	part.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	messageType := s.fileService.GetFileType(session.FileName)
+	fileName := session.FileName
+	fileSize := session.FileSize
+
+	message, err := s.messageService.SendMessage(session.ChatID, senderID, operator, messageType, nil, &fileURL, &fileName, &fileSize)
+	if err != nil {
+		s.fileService.DeleteFile(fileURL)
+		return nil, err
+	}
+
+	if err := tx.Model(session).Updates(map[string]interface{}{
+		"status":     "completed",
+		"message_id": message.ID,
+	}).Error; err != nil {
+		logrus.WithError(err).Warnf("Failed to mark upload session %s as completed", session.ID)
+	}
+
+	os.Remove(partPath)
+
+	return message, nil
+}
+
+// CleanupExpiredSessions 清理过期的上传会话及其临时文件
+// 每个会话在锁定行后重新确认已过期再删除，正在写入的分片会让会话续期而不被清理
+func (s *UploadService) CleanupExpiredSessions() (int, error) {
+	var ids []string
+	if err := database.DB.Model(&models.UploadSession{}).Where("expires_at < ?", time.Now()).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, id := range ids {
+		tx := database.DB.Begin()
+
+		var session models.UploadSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND expires_at < ?", id, time.Now()).
+			First(&session).Error; err != nil {
+			tx.Rollback()
+			continue
+		}
+
+		if err := tx.Delete(&models.UploadSession{}, "id = ?", id).Error; err != nil {
+			tx.Rollback()
+			logrus.WithError(err).Warnf("Failed to delete upload session %s", id)
+			continue
+		}
+		os.Remove(s.partPath(id))
+
+		if err := tx.Commit().Error; err != nil {
+			logrus.WithError(err).Warnf("Failed to delete upload session %s", id)
+			continue
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// StartUploadCleaner 定期清理过期的上传会话和失效的存储配额预留
+func StartUploadCleaner(interval time.Duration) {
+	service := NewUploadService()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			count, err := service.CleanupExpiredSessions()
+			if err != nil {
+				logrus.WithError(err).Error("Failed to clean up upload sessions")
+				continue
+			}
+			if count > 0 {
+				logrus.Infof("Cleaned up %d expired upload sessions", count)
+			}
+
+			if _, err := service.quotaService.CleanupExpiredReservations(); err != nil {
+				logrus.WithError(err).Error("Failed to clean up storage reservations")
+			}
+		}
+	}()
+}
+
+// tempDir 分片临时目录（不在静态文件目录下，避免被直接访问）
+func (s *UploadService) tempDir() string {
+	return config.AppConfig.Storage.TempPath
+}
+
+// partPath 会话对应的临时文件路径
+func (s *UploadService) partPath(uploadID string) string {
+	return filepath.Join(s.tempDir(), uploadID+".part")
+}
+
+// ttl 会话有效期
+func (s *UploadService) ttl() time.Duration {
+	return time.Duration(config.AppConfig.Storage.UploadSessionTTL) * time.Hour
+}
+
+// lockUploadSession 在事务中锁定会话行（SELECT ... FOR UPDATE）并重新读取
+func lockUploadSession(tx *gorm.DB, session *models.UploadSession) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(session, "id = ?", session.ID).Error
+	if err == gorm.ErrRecordNotFound {
+		return ErrUploadNotFound
+	}
+	return err
+}
+
+// generateUploadID 生成上传会话ID
+func generateUploadID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// sha256File 计算文件的 SHA-256
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// VideoMetadata 视频元数据
+type VideoMetadata struct {
+	Duration  *float64 // 时长(秒)
+	Width     *int     // 宽度(像素)
+	Height    *int     // 高度(像素)
+	PosterURL *string  // 封面URL
+}
+
+// errNoMoovBox 容器中没有 moov 盒子
+var errNoMoovBox = errors.New("moov box not found")
+
+// ProbeVideo 读取视频容器头部获取时长和分辨率，并尽可能生成封面
+// 封面与头部解析互不依赖：头部解析失败时仍返回已得到的部分元数据和封面，同时返回解析错误
+func (s *FileService) ProbeVideo(fileURL string) (*VideoMetadata, error) {
+	filePath := s.GetFilePath(fileURL)
+
+	meta := &VideoMetadata{}
+
+	// 目前只解析 ISO BMFF 容器 (mp4/mov)，其他格式 (webm/mkv 等) 只尝试生成封面
+	var probeErr error
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".mp4", ".mov", ".m4v":
+		probeErr = probeISOBMFF(filePath, meta)
+	}
+
+	if posterURL, err := s.generatePoster(filePath, fileURL); err == nil {
+		meta.PosterURL = &posterURL
+	}
+
+	return meta, probeErr
+}
+
+// generatePoster 使用 ffmpeg 截取一帧作为封面（ffmpeg 不可用时跳过）
+func (s *FileService) generatePoster(filePath string, fileURL string) (string, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return "", err
+	}
+
+	posterPath := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + "_poster.jpg"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ffmpeg,
+		"-y", "-loglevel", "error",
+		"-ss", "1", "-i", filePath,
+		"-frames:v", "1", "-vf", "scale='min(640,iw)':-2",
+		posterPath,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(posterPath)
+		return "", fmt.Errorf("ffmpeg failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return strings.TrimSuffix(fileURL, filepath.Ext(fileURL)) + "_poster.jpg", nil
+}
+
+// probeISOBMFF 解析 mp4/mov 的 moov 盒子
+func probeISOBMFF(filePath string, meta *VideoMetadata) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// moov 可能位于文件末尾（未做 faststart），按顶层盒子逐个跳过
+	offset := int64(0)
+	for offset < info.Size() {
+		boxType, headerSize, boxSize, err := readBoxHeader(f, offset, info.Size())
+		if err != nil {
+			return err
+		}
+
+		if boxType == "moov" {
+			payload := make([]byte, boxSize-headerSize)
+			if _, err := f.ReadAt(payload, offset+headerSize); err != nil {
+				return err
+			}
+			parseMoov(payload, meta)
+			return nil
+		}
+
+		offset += boxSize
+	}
+
+	return errNoMoovBox
+}
+
+// readBoxHeader 读取指定位置的盒子头
+func readBoxHeader(r io.ReaderAt, offset int64, fileSize int64) (string, int64, int64, error) {
+	header := make([]byte, 16)
+	n, err := r.ReadAt(header, offset)
+	if n < 8 {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", 0, 0, err
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+	boxType := string(header[4:8])
+	headerSize := int64(8)
+
+	switch size {
+	case 0:
+		// 盒子延伸到文件末尾
+		size = fileSize - offset
+	case 1:
+		// 64 位扩展长度
+		if n < 16 {
+			return "", 0, 0, io.ErrUnexpectedEOF
+		}
+		size = int64(binary.BigEndian.Uint64(header[8:16]))
+		headerSize = 16
+	}
+
+	if size < headerSize || offset+size > fileSize {
+		return "", 0, 0, fmt.Errorf("invalid %q box size %d", boxType, size)
+	}
+
+	return boxType, headerSize, size, nil
+}
+
+// parseMoov 遍历 moov 下的子盒子
+func parseMoov(data []byte, meta *VideoMetadata) {
+	walkBoxes(data, func(boxType string, payload []byte) {
+		switch boxType {
+		case "mvhd":
+			if duration, ok := parseMvhd(payload); ok {
+				meta.Duration = &duration
+			}
+		case "trak":
+			if meta.Width != nil {
+				return
+			}
+			walkBoxes(payload, func(childType string, childPayload []byte) {
+				if childType != "tkhd" {
+					return
+				}
+				// 音频轨道的宽高为 0，取第一个有画面的轨道
+				if width, height, ok := parseTkhd(childPayload); ok && width > 0 && height > 0 {
+					meta.Width = &width
+					meta.Height = &height
+				}
+			})
+		}
+	})
+}
+
+// walkBoxes 遍历内存中的盒子序列
+func walkBoxes(data []byte, fn func(boxType string, payload []byte)) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		boxType := string(data[4:8])
+		headerSize := uint64(8)
+
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		}
+
+		if size < headerSize || size > uint64(len(data)) {
+			return
+		}
+
+		fn(boxType, data[headerSize:size])
+		data = data[size:]
+	}
+}
+
+// parseMvhd 解析影片头，返回时长(秒)
+func parseMvhd(p []byte) (float64, bool) {
+	if len(p) < 1 {
+		return 0, false
+	}
+
+	var timescale uint32
+	var duration uint64
+
+	if p[0] == 1 {
+		// version 1: 64 位创建/修改时间和时长
+		if len(p) < 32 {
+			return 0, false
+		}
+		timescale = binary.BigEndian.Uint32(p[20:24])
+		duration = binary.BigEndian.Uint64(p[24:32])
+	} else {
+		if len(p) < 20 {
+			return 0, false
+		}
+		timescale = binary.BigEndian.Uint32(p[12:16])
+		duration = uint64(binary.BigEndian.Uint32(p[16:20]))
+	}
+
+	if timescale == 0 {
+		return 0, false
+	}
+
+	return float64(duration) / float64(timescale), true
+}
+
+// parseTkhd 解析轨道头，返回宽高（16.16 定点数取整数部分）
+func parseTkhd(p []byte) (int, int, bool) {
+	if len(p) < 1 {
+		return 0, 0, false
+	}
+
+	// version/flags(4) + 时间和轨道信息 + reserved(8) + layer/group/volume/reserved(8) + matrix(36)
+	offset := 4 + 20 + 8 + 8 + 36
+	if p[0] == 1 {
+		offset = 4 + 32 + 8 + 8 + 36
+	}
+
+	if len(p) < offset+8 {
+		return 0, 0, false
+	}
+
+	width := int(binary.BigEndian.Uint32(p[offset:offset+4]) >> 16)
+	height := int(binary.BigEndian.Uint32(p[offset+4:offset+8]) >> 16)
+
+	return width, height, true
+}
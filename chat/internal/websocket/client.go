@@ -16,8 +16,10 @@ type Client struct {
 	Conn             *websocket.Conn
 	Send             chan ServerMessage
 	Hub              *Hub
-	ActiveChats      map[uint]bool // 用户当前打开的聊天室（用于UI状态，如正在输入）
-	ParticipantChats map[uint]bool // 用户参与的所有聊天室（从数据库加载）
+	ActiveChats      map[uint]bool   // 用户当前打开的聊天室（用于UI状态，如正在输入）
+	ParticipantChats map[uint]bool   // 用户参与的所有聊天室（从数据库加载）
+	IsOperator       bool            // Operator 连接只接收推送（ID 为 operator_id）
+	Channels         map[string]bool // Operator 订阅的频道
 	Mutex            sync.RWMutex
 }
 
@@ -39,6 +41,20 @@ func NewClient(hub *Hub, conn *websocket.Conn, user *models.User) *Client {
 	return client
 }
 
+// NewOperatorClient 创建 Operator 客户端（不参与任何聊天室，只接收推送给自己的消息）
+func NewOperatorClient(hub *Hub, conn *websocket.Conn, operatorID uint) *Client {
+	return &Client{
+		ID:               operatorID,
+		Conn:             conn,
+		Send:             make(chan ServerMessage, 256),
+		Hub:              hub,
+		ActiveChats:      make(map[uint]bool),
+		ParticipantChats: make(map[uint]bool),
+		IsOperator:       true,
+		Channels:         make(map[string]bool),
+	}
+}
+
 // ReadPump 读取客户端消息
 func (c *Client) ReadPump() {
 	defer func() {
@@ -94,6 +110,18 @@ func (c *Client) WritePump() {
 
 // handleMessage 处理客户端消息
 func (c *Client) handleMessage(msg ClientMessage) {
+	if c.IsOperator {
+		switch msg.Type {
+		case Subscribe:
+			c.handleSubscribe(msg)
+		case Unsubscribe:
+			c.handleUnsubscribe(msg)
+		default:
+			c.sendError("Operator connections can only subscribe to channels")
+		}
+		return
+	}
+
 	switch msg.Type {
 	case SendMessage:
 		c.handleSendMessage(msg)
@@ -219,6 +247,37 @@ func (c *Client) handleReadMessage(msg ClientMessage) {
 	c.Send <- response
 }
 
+// handleSubscribe Operator 订阅频道
+func (c *Client) handleSubscribe(msg ClientMessage) {
+	if !operatorChannels[msg.Channel] {
+		c.sendError("Unknown channel")
+		return
+	}
+
+	c.Mutex.Lock()
+	c.Channels[msg.Channel] = true
+	c.Mutex.Unlock()
+
+	logrus.Infof("Operator %d subscribed to %s", c.ID, msg.Channel)
+	c.sendSuccess("Subscribed to " + msg.Channel)
+}
+
+// handleUnsubscribe Operator 取消订阅频道
+func (c *Client) handleUnsubscribe(msg ClientMessage) {
+	c.Mutex.Lock()
+	delete(c.Channels, msg.Channel)
+	c.Mutex.Unlock()
+
+	c.sendSuccess("Unsubscribed from " + msg.Channel)
+}
+
+// IsSubscribed 检查 Operator 是否订阅了频道
+func (c *Client) IsSubscribed(channel string) bool {
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
+	return c.Channels[channel]
+}
+
 // sendError 发送错误消息
 func (c *Client) sendError(message string) {
 	c.Send <- ServerMessage{
@@ -29,6 +29,12 @@ type Hub struct {
 	// 广播消息到指定聊天室
 	BroadcastToChatChan chan BroadcastToChatMessage
 
+	// 发送消息给指定用户
+	SendToUserChan chan SendToUserMessage
+
+	// 发布消息到 Operator 频道
+	PublishChan chan ChannelMessage
+
 	// 互斥锁
 	Mutex sync.RWMutex
 }
@@ -38,6 +44,21 @@ type BroadcastToChatMessage struct {
 	ChatID  uint
 	Message ServerMessage
 	Exclude uint // 排除的用户ID
+
+	ExcludeUsers map[uint]bool // 另外排除的参与者（例如已单独发送附带译文消息的用户）
+}
+
+// ChannelMessage 发布到 Operator 频道的消息
+type ChannelMessage struct {
+	Channel string
+	Message ServerMessage
+}
+
+// SendToUserMessage 发送给指定用户（或 Operator）的消息
+type SendToUserMessage struct {
+	UserID     uint
+	IsOperator bool
+	Message    ServerMessage
 }
 
 var upgrader = websocket.Upgrader{
@@ -56,6 +77,8 @@ func NewHub() *Hub {
 		Unregister:          make(chan *Client),
 		Broadcast:           make(chan ServerMessage),
 		BroadcastToChatChan: make(chan BroadcastToChatMessage),
+		SendToUserChan:      make(chan SendToUserMessage),
+		PublishChan:         make(chan ChannelMessage),
 	}
 }
 
@@ -93,7 +116,8 @@ func (h *Hub) Run() {
 		case broadcastMsg := <-h.BroadcastToChatChan:
 			h.Mutex.RLock()
 			for client := range h.Clients {
-				if client.IsInChat(broadcastMsg.ChatID) && client.ID != broadcastMsg.Exclude {
+				if client.IsInChat(broadcastMsg.ChatID) && client.ID != broadcastMsg.Exclude &&
+					!(broadcastMsg.ExcludeUsers[client.ID] && !client.IsOperator) {
 					select {
 					case client.Send <- broadcastMsg.Message:
 					default:
@@ -103,6 +127,34 @@ func (h *Hub) Run() {
 				}
 			}
 			h.Mutex.RUnlock()
+
+		case userMsg := <-h.SendToUserChan:
+			h.Mutex.RLock()
+			for client := range h.Clients {
+				if client.ID == userMsg.UserID && client.IsOperator == userMsg.IsOperator {
+					select {
+					case client.Send <- userMsg.Message:
+					default:
+						close(client.Send)
+						delete(h.Clients, client)
+					}
+				}
+			}
+			h.Mutex.RUnlock()
+
+		case channelMsg := <-h.PublishChan:
+			h.Mutex.RLock()
+			for client := range h.Clients {
+				if client.IsOperator && client.IsSubscribed(channelMsg.Channel) {
+					select {
+					case client.Send <- channelMsg.Message:
+					default:
+						close(client.Send)
+						delete(h.Clients, client)
+					}
+				}
+			}
+			h.Mutex.RUnlock()
 		}
 	}
 }
@@ -112,6 +164,22 @@ func (h *Hub) HandleWebSocket(c *gin.Context) {
 	// 使用可选认证中间件
 	middleware.OptionalAuthMiddleware()(c)
 
+	// Operator 连接：只接收推送给自己的消息（例如 AI 流式输出）
+	if operatorID, ok := middleware.GetOperatorIDFromContext(c); ok {
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			logrus.Errorf("WebSocket upgrade error: %v", err)
+			return
+		}
+
+		client := NewOperatorClient(h, conn, operatorID)
+		h.Register <- client
+
+		go client.WritePump()
+		go client.ReadPump()
+		return
+	}
+
 	// 获取用户信息
 	user, exists := middleware.GetUserFromContext(c)
 	if !exists {
@@ -146,6 +214,42 @@ func (h *Hub) BroadcastToChat(chatID uint, message ServerMessage, excludeUserID
 	}
 }
 
+// BroadcastToChatExcept 广播消息到指定聊天室，除 excludeUserID 外还排除 excludeUserIDs 中的参与者
+func (h *Hub) BroadcastToChatExcept(chatID uint, message ServerMessage, excludeUserID uint, excludeUserIDs map[uint]bool) {
+	h.BroadcastToChatChan <- BroadcastToChatMessage{
+		ChatID:       chatID,
+		Message:      message,
+		Exclude:      excludeUserID,
+		ExcludeUsers: excludeUserIDs,
+	}
+}
+
+// SendToUser 发送消息给指定用户的所有连接
+func (h *Hub) SendToUser(userID uint, message ServerMessage) {
+	h.SendToUserChan <- SendToUserMessage{
+		UserID:  userID,
+		Message: message,
+	}
+}
+
+// SendToOperator 发送消息给指定 Operator 的所有连接
+func (h *Hub) SendToOperator(operatorID uint, message ServerMessage) {
+	h.SendToUserChan <- SendToUserMessage{
+		UserID:     operatorID,
+		IsOperator: true,
+		Message:    message,
+	}
+}
+
+// Publish 发布消息给订阅了频道的所有 Operator 连接
+func (h *Hub) Publish(channel string, message ServerMessage) {
+	message.Channel = channel
+	h.PublishChan <- ChannelMessage{
+		Channel: channel,
+		Message: message,
+	}
+}
+
 // GetClientCount 获取客户端数量
 func (h *Hub) GetClientCount() int {
 	h.Mutex.RLock()
@@ -11,6 +11,8 @@ const (
 	Typing      MessageType = "typing"
 	StopTyping  MessageType = "stop_typing"
 	ReadMessage MessageType = "read_message"
+	Subscribe   MessageType = "subscribe"   // Operator 订阅频道
+	Unsubscribe MessageType = "unsubscribe" // Operator 取消订阅频道
 
 	// 服务器发送的消息类型
 	NewMessage        MessageType = "new_message"
@@ -22,8 +24,24 @@ const (
 	UserStopTyping    MessageType = "user_stop_typing"
 	Error             MessageType = "error"
 	Success           MessageType = "success"
+	FileScanCompleted MessageType = "file_scan_completed"
+	AIDelta           MessageType = "ai_delta"
+	MessageFlagged    MessageType = "message_flagged"
+	FlagUpdated       MessageType = "flag_updated"
+	MessageTranslated MessageType = "message_translated"
 )
 
+// Operator 频道
+const (
+	// FlagsChannel 消息风险标记（message_flagged、flag_updated）
+	FlagsChannel = "flags"
+)
+
+// operatorChannels Operator 可以订阅的频道
+var operatorChannels = map[string]bool{
+	FlagsChannel: true,
+}
+
 // ClientMessage 客户端发送的消息
 type ClientMessage struct {
 	Type        MessageType `json:"type"`
@@ -32,33 +50,79 @@ type ClientMessage struct {
 	MessageType string      `json:"message_type,omitempty"`
 	TempID      string      `json:"temp_id,omitempty"`
 	MessageID   uint        `json:"message_id,omitempty"`
+	Channel     string      `json:"channel,omitempty"` // subscribe / unsubscribe 的频道
 }
 
 // ServerMessage 服务器发送的消息
 type ServerMessage struct {
-	Type      MessageType `json:"type"`
-	Message   *Message    `json:"message,omitempty"`
-	ChatID    uint        `json:"chat_id,omitempty"`
-	User      *User       `json:"user,omitempty"`
-	Error     string      `json:"error,omitempty"`
-	Success   string      `json:"success,omitempty"`
-	TempID    string      `json:"temp_id,omitempty"`
-	MessageID uint        `json:"message_id,omitempty"`
-	Status    string      `json:"status,omitempty"`
+	Type        MessageType  `json:"type"`
+	Message     *Message     `json:"message,omitempty"`
+	ChatID      uint         `json:"chat_id,omitempty"`
+	User        *User        `json:"user,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	Success     string       `json:"success,omitempty"`
+	TempID      string       `json:"temp_id,omitempty"`
+	MessageID   uint         `json:"message_id,omitempty"`
+	Status      string       `json:"status,omitempty"`
+	StreamID    string       `json:"stream_id,omitempty"`   // AI 流式输出ID
+	Delta       string       `json:"delta,omitempty"`       // AI 流式输出的增量文本
+	Done        bool         `json:"done,omitempty"`        // AI 流式输出是否结束
+	Channel     string       `json:"channel,omitempty"`     // Operator 频道
+	Flag        *Flag        `json:"flag,omitempty"`        // 消息风险标记
+	Translation *Translation `json:"translation,omitempty"` // message_translated：接收者语言的译文
 }
 
 // Message 消息结构
 type Message struct {
-	ID        uint    `json:"id"`
-	ChatID    uint    `json:"chat_id"`
-	Sender    *User   `json:"sender,omitempty"`
-	Type      string  `json:"type"`
-	Content   *string `json:"content,omitempty"`
-	FileURL   *string `json:"file_url,omitempty"`
-	FileName  *string `json:"file_name,omitempty"`
-	FileSize  *int64  `json:"file_size,omitempty"`
-	CreatedAt string  `json:"created_at"`
-	Status    string  `json:"status,omitempty"`
+	ID          uint      `json:"id"`
+	ChatID      uint      `json:"chat_id"`
+	Sender      *User     `json:"sender,omitempty"`
+	Asker       *User     `json:"asker,omitempty"`    // ai_assistant 消息：提问的参与者
+	Question    *string   `json:"question,omitempty"` // ai_assistant 消息：参与者的问题
+	SenderType  string    `json:"sender_type"`        // user、operator、ai_assistant 或 system
+	Operator    *Operator `json:"operator,omitempty"` // Operator 发送、上传或发布的消息
+	Type        string    `json:"type"`
+	Content     *string   `json:"content,omitempty"`
+	FileURL     *string   `json:"file_url,omitempty"`
+	FileName    *string   `json:"file_name,omitempty"`
+	FileSize    *int64    `json:"file_size,omitempty"`
+	ContentHash *string   `json:"content_hash,omitempty"` // 文件 SHA-256
+	Duration    *float64  `json:"duration,omitempty"`     // 视频时长(秒)
+	Width       *int      `json:"width,omitempty"`        // 视频宽度
+	Height      *int      `json:"height,omitempty"`       // 视频高度
+	PosterURL   *string   `json:"poster_url,omitempty"`   // 视频封面
+	CreatedAt   string    `json:"created_at"`
+	Status      string    `json:"status,omitempty"`
+
+	Translation *Translation `json:"translation,omitempty"` // 自动翻译聊天室中接收者语言的已缓存译文
+}
+
+// Operator 发送消息的 Operator 信息
+type Operator struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// Translation 消息译文
+type Translation struct {
+	Language       string `json:"language"`
+	SourceLanguage string `json:"source_language"`
+	Content        string `json:"content"`
+}
+
+// Flag 消息风险标记
+type Flag struct {
+	ID         uint    `json:"id"`
+	MessageID  uint    `json:"message_id"`
+	ChatID     uint    `json:"chat_id"`
+	Category   string  `json:"category"`
+	Severity   string  `json:"severity"`
+	Source     string  `json:"source"`
+	Reason     string  `json:"reason"`
+	Status     string  `json:"status"`
+	ReviewedBy *uint   `json:"reviewed_by,omitempty"`
+	ReviewedAt *string `json:"reviewed_at,omitempty"`
+	CreatedAt  string  `json:"created_at"`
 }
 
 // User 用户结构 (WebSocket 消息中的简化用户信息)
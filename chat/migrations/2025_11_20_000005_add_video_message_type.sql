@@ -0,0 +1,11 @@
+-- Add video type to messages and media metadata to chat_files
+-- Videos were previously stored as documents
+
+ALTER TABLE messages
+MODIFY COLUMN type ENUM('text', 'document', 'image', 'video', 'system', 'ai_assistant') DEFAULT 'text';
+
+ALTER TABLE chat_files
+ADD COLUMN duration DECIMAL(10,3) NULL COMMENT '视频时长(秒)' AFTER file_size,
+ADD COLUMN width INT NULL COMMENT '视频宽度(像素)' AFTER duration,
+ADD COLUMN height INT NULL COMMENT '视频高度(像素)' AFTER width,
+ADD COLUMN poster_url VARCHAR(500) NULL COMMENT '视频封面URL' AFTER height;
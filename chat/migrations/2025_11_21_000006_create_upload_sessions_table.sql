@@ -0,0 +1,22 @@
+-- 分片上传会话表
+-- 用于大文件的断点续传，完成后会创建对应的消息
+
+CREATE TABLE IF NOT EXISTS `upload_sessions` (
+    `id` varchar(64) NOT NULL COMMENT '上传会话ID',
+    `chat_id` bigint(20) unsigned NOT NULL COMMENT '聊天室ID',
+    `user_id` bigint(20) unsigned NOT NULL COMMENT '上传者ID（Operator 时为 operator_id）',
+    `is_operator` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否由 Operator 上传',
+    `file_name` varchar(255) NOT NULL COMMENT '原始文件名',
+    `file_size` bigint(20) NOT NULL COMMENT '文件总大小(字节)',
+    `received_bytes` bigint(20) NOT NULL DEFAULT 0 COMMENT '已接收字节数',
+    `checksum` varchar(64) NOT NULL COMMENT 'SHA-256 校验值',
+    `status` enum('uploading','completed') NOT NULL DEFAULT 'uploading' COMMENT '会话状态',
+    `message_id` bigint(20) unsigned DEFAULT NULL COMMENT '完成后创建的消息ID',
+    `expires_at` timestamp NULL DEFAULT NULL COMMENT '过期时间',
+    `created_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_chat_id` (`chat_id`),
+    KEY `idx_expires_at` (`expires_at`),
+    CONSTRAINT `fk_upload_sessions_chat_id` FOREIGN KEY (`chat_id`) REFERENCES `chats` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='分片上传会话表';
@@ -0,0 +1,17 @@
+-- 文件去重：按 SHA-256 内容寻址存储，多个 chat_files 记录共享同一个文件实体
+
+CREATE TABLE IF NOT EXISTS `file_blobs` (
+    `content_hash` char(64) NOT NULL COMMENT 'SHA-256 内容哈希',
+    `file_path` varchar(500) NOT NULL COMMENT '相对存储目录的路径',
+    `file_url` varchar(500) NOT NULL COMMENT '文件URL',
+    `file_size` bigint(20) NOT NULL COMMENT '文件大小(字节)',
+    `ref_count` int NOT NULL DEFAULT 0 COMMENT '引用计数',
+    `created_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`content_hash`),
+    UNIQUE KEY `unique_file_url` (`file_url`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='文件内容表';
+
+ALTER TABLE chat_files
+ADD COLUMN content_hash char(64) NULL COMMENT 'SHA-256 内容哈希' AFTER file_size,
+ADD INDEX idx_content_hash (content_hash);
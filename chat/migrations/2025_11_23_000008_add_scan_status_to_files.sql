@@ -0,0 +1,9 @@
+-- 上传文件病毒扫描：扫描完成前文件处于 pending_scan 状态，只有 clean 的文件对其他参与者可见
+
+ALTER TABLE chat_files
+ADD COLUMN scan_status enum('pending_scan','clean','infected','scan_failed') NOT NULL DEFAULT 'clean' COMMENT '病毒扫描状态' AFTER poster_url,
+ADD INDEX idx_scan_status (scan_status);
+
+-- 已有内容未经扫描，再次上传时会重新扫描
+ALTER TABLE file_blobs
+ADD COLUMN scan_status enum('pending_scan','clean','infected') NOT NULL DEFAULT 'pending_scan' COMMENT '内容扫描结论' AFTER ref_count;
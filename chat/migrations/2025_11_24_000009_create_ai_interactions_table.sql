@@ -0,0 +1,24 @@
+-- Operator AI 调用记录表
+-- 记录每次 AI 调用的回答、模型和 token 用量，用于历史查询和成本统计
+
+CREATE TABLE IF NOT EXISTS `ai_interactions` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `operator_id` bigint(20) unsigned NOT NULL COMMENT 'Operator (admin_users.id)',
+    `chat_id` bigint(20) unsigned NOT NULL COMMENT '聊天室ID',
+    `endpoint` varchar(50) NOT NULL COMMENT '调用的接口：ask、summarize、analyze_files 等',
+    `prompt_hash` varchar(64) NOT NULL COMMENT '请求内容的 SHA-256',
+    `answer` mediumtext DEFAULT NULL COMMENT 'AI 回答，失败时为空',
+    `provider` varchar(50) DEFAULT NULL COMMENT '实际回答的提供方',
+    `model` varchar(100) DEFAULT NULL COMMENT '模型',
+    `prompt_tokens` int(11) NOT NULL DEFAULT 0,
+    `completion_tokens` int(11) NOT NULL DEFAULT 0,
+    `latency_ms` bigint(20) NOT NULL DEFAULT 0 COMMENT '耗时(毫秒)',
+    `status` enum('success','error') NOT NULL DEFAULT 'success',
+    `error` varchar(500) DEFAULT NULL COMMENT '失败原因',
+    `created_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_ai_interactions_operator_created` (`operator_id`, `created_at`),
+    KEY `idx_chat_id` (`chat_id`),
+    KEY `idx_prompt_hash` (`prompt_hash`),
+    KEY `idx_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Operator AI 调用记录表';
@@ -0,0 +1,19 @@
+-- 案件信息提取结果表
+-- 每个聊天室保留最新一次提取的当事人、日期、金额、问题分类和待确认问题
+
+CREATE TABLE IF NOT EXISTS `chat_case_extractions` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `chat_id` bigint(20) unsigned NOT NULL COMMENT '聊天室ID',
+    `issue_category` varchar(50) NOT NULL COMMENT '法律问题分类',
+    `data` mediumtext NOT NULL COMMENT '提取结果 JSON',
+    `last_message_id` bigint(20) unsigned NOT NULL COMMENT '提取时的最后一条消息ID',
+    `message_count` int(11) NOT NULL DEFAULT 0 COMMENT '参与提取的消息数',
+    `operator_id` bigint(20) unsigned NOT NULL COMMENT '最后一次刷新的 Operator (admin_users.id)',
+    `interaction_id` bigint(20) unsigned NOT NULL DEFAULT 0 COMMENT 'ai_interactions.id',
+    `created_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `unique_chat_id` (`chat_id`),
+    KEY `idx_issue_category` (`issue_category`),
+    CONSTRAINT `fk_chat_case_extractions_chat_id` FOREIGN KEY (`chat_id`) REFERENCES `chats` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='案件信息提取结果表';
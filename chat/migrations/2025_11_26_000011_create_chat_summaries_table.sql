@@ -0,0 +1,17 @@
+-- 聊天室滚动总结表
+-- 保存最新的总结和它覆盖到的最后一条消息，之后只需总结新消息
+
+CREATE TABLE IF NOT EXISTS `chat_summaries` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `chat_id` bigint(20) unsigned NOT NULL COMMENT '聊天室ID',
+    `language` varchar(10) NOT NULL COMMENT '总结语言',
+    `summary` mediumtext NOT NULL COMMENT '总结内容',
+    `last_message_id` bigint(20) unsigned NOT NULL DEFAULT 0 COMMENT '总结覆盖到的最后一条消息ID',
+    `message_count` int(11) NOT NULL DEFAULT 0 COMMENT '总结覆盖的消息数',
+    `created_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_chat_summaries_chat_language` (`chat_id`, `language`),
+    KEY `idx_updated_at` (`updated_at`),
+    CONSTRAINT `fk_chat_summaries_chat_id` FOREIGN KEY (`chat_id`) REFERENCES `chats` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='聊天室滚动总结表';
@@ -0,0 +1,16 @@
+-- 消息翻译：译文缓存表和聊天室自动翻译开关
+
+CREATE TABLE IF NOT EXISTS `message_translations` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `message_id` bigint(20) unsigned NOT NULL COMMENT '消息ID',
+    `language` varchar(10) NOT NULL COMMENT '译文语言',
+    `source_language` varchar(10) DEFAULT NULL COMMENT '检测到的原文语言',
+    `content` text NOT NULL COMMENT '译文',
+    `created_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_message_translations_message_language` (`message_id`, `language`),
+    CONSTRAINT `fk_message_translations_message_id` FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='消息译文缓存表';
+
+ALTER TABLE chats
+ADD COLUMN auto_translate tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否自动翻译为查看者的语言' AFTER created_by;
@@ -0,0 +1,4 @@
+-- 聊天语言：根据最近的消息自动检测，作为总结、回复建议和推送通知的默认语言
+
+ALTER TABLE chats
+ADD COLUMN language varchar(10) NOT NULL DEFAULT '' COMMENT '检测到的聊天语言：ru、kk、en、zh' AFTER auto_translate;
@@ -0,0 +1,4 @@
+-- 记录 AI 调用使用的提示词模板版本
+
+ALTER TABLE ai_interactions
+ADD COLUMN prompt_template varchar(100) DEFAULT NULL COMMENT '提示词模板版本，例如 operator_ask@v1' AFTER prompt_hash;
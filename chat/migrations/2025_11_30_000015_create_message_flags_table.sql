@@ -0,0 +1,24 @@
+-- 消息风险标记表
+-- 审核流程在消息发送后按规则（可选 LLM）分类，每条消息每个类别一条标记
+
+CREATE TABLE IF NOT EXISTS `message_flags` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `message_id` bigint(20) unsigned NOT NULL COMMENT '消息ID',
+    `chat_id` bigint(20) unsigned NOT NULL COMMENT '聊天室ID',
+    `category` varchar(20) NOT NULL COMMENT '类别：self_harm、illegal、abuse',
+    `severity` varchar(10) NOT NULL COMMENT '严重程度：low、medium、high',
+    `source` varchar(20) NOT NULL COMMENT '来源：rules、llm 或 rules,llm',
+    `reason` varchar(500) NOT NULL DEFAULT '' COMMENT '标记原因',
+    `status` varchar(20) NOT NULL DEFAULT 'open' COMMENT '状态：open、acknowledged、dismissed',
+    `reviewed_by` bigint(20) unsigned NULL DEFAULT NULL COMMENT '处理的 Operator ID',
+    `reviewed_at` timestamp NULL DEFAULT NULL,
+    `created_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_message_flags_message_category` (`message_id`, `category`),
+    KEY `idx_chat_id` (`chat_id`),
+    KEY `idx_status` (`status`),
+    KEY `idx_created_at` (`created_at`),
+    CONSTRAINT `fk_message_flags_message_id` FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_message_flags_chat_id` FOREIGN KEY (`chat_id`) REFERENCES `chats` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='消息风险标记表';
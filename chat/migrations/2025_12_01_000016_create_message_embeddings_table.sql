@@ -0,0 +1,23 @@
+-- 消息向量表
+-- 保存消息文本和文件文本片段的向量，用于 Operator 跨聊天检索
+
+CREATE TABLE IF NOT EXISTS `message_embeddings` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `chat_id` bigint(20) unsigned NOT NULL COMMENT '聊天室ID',
+    `message_id` bigint(20) unsigned NOT NULL COMMENT '消息ID',
+    `file_id` bigint(20) unsigned NULL DEFAULT NULL COMMENT '来源为文件时的 chat_files.id',
+    `source` varchar(10) NOT NULL COMMENT '来源：message 或 file',
+    `chunk_index` int(11) NOT NULL DEFAULT 0 COMMENT '文件文本的片段序号',
+    `model` varchar(100) NOT NULL COMMENT '向量模型',
+    `content` text NOT NULL COMMENT '被索引的文本',
+    `vector` mediumblob NULL COMMENT 'float32 小端序向量，无法提取文本的文件为空',
+    `dimensions` int(11) NOT NULL DEFAULT 0 COMMENT '向量维度，0 表示没有可索引的文本',
+    `message_created_at` timestamp NULL DEFAULT NULL COMMENT '消息发送时间，用于按时间过滤',
+    `created_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_message_embeddings_unique` (`message_id`, `source`, `chunk_index`, `model`),
+    KEY `idx_chat_id` (`chat_id`),
+    KEY `idx_message_embeddings_model_created` (`model`, `message_created_at`),
+    CONSTRAINT `fk_message_embeddings_message_id` FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_message_embeddings_chat_id` FOREIGN KEY (`chat_id`) REFERENCES `chats` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='消息向量表';
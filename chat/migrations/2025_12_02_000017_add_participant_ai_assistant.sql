@@ -0,0 +1,9 @@
+-- 参与者 AI 助手：按聊天室开启，回答消息记录提问者和问题（用于展示和每日配额统计）
+
+ALTER TABLE chats
+ADD COLUMN ai_enabled tinyint(1) NOT NULL DEFAULT 0 COMMENT '参与者可以向 AI 助手提问（由公司管理员开启）' AFTER language;
+
+ALTER TABLE messages
+ADD COLUMN asked_by int NULL COMMENT 'ai_assistant 消息：提问的参与者 (users.id)' AFTER operator_id,
+ADD COLUMN question text NULL COMMENT 'ai_assistant 消息：参与者的问题' AFTER asked_by,
+ADD INDEX idx_messages_asked_by_created (asked_by, created_at);
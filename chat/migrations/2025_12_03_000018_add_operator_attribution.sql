@@ -0,0 +1,8 @@
+-- Operator 署名：消息记录发送时的 Operator 名称，Operator 上传的文件不再需要 users 中的上传者
+
+ALTER TABLE messages
+ADD COLUMN operator_name varchar(255) NULL COMMENT '发送时的 Operator 名称' AFTER operator_id;
+
+ALTER TABLE chat_files
+MODIFY COLUMN uploaded_by bigint(20) unsigned NULL COMMENT '上传者ID，Operator 上传时为 NULL',
+ADD COLUMN operator_id int NULL COMMENT '上传的 Operator (admin_users.id)' AFTER uploaded_by;
@@ -0,0 +1,18 @@
+-- Operator 已读位置表
+-- Operator 不是聊天室参与者，按 Operator 和聊天室记录已读到的最后一条消息，用于 Operator 聊天列表的未读数
+
+CREATE TABLE IF NOT EXISTS `operator_chat_reads` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `operator_id` int NOT NULL COMMENT 'Operator (admin_users.id)',
+    `chat_id` bigint(20) unsigned NOT NULL COMMENT '聊天室ID',
+    `last_read_message_id` bigint(20) unsigned NOT NULL DEFAULT 0 COMMENT '已读到的最后一条消息ID',
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_operator_chat_reads_operator_chat` (`operator_id`, `chat_id`),
+    KEY `idx_chat_id` (`chat_id`),
+    CONSTRAINT `fk_operator_chat_reads_chat_id` FOREIGN KEY (`chat_id`) REFERENCES `chats` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Operator 已读位置表';
+
+-- 聊天列表按最后一条参与者消息和 Operator 消息判断是否已回复
+ALTER TABLE messages
+ADD INDEX idx_messages_chat_operator (chat_id, operator_id);
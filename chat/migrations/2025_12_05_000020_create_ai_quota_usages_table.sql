@@ -0,0 +1,20 @@
+-- 参与者 AI 助手每日配额表
+-- 提问前在当天的计数行上用 UPDATE ... WHERE used < quota 原子地预留一次，替代按已保存的 ai_assistant 消息计数
+
+CREATE TABLE IF NOT EXISTS `ai_quota_usages` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `user_id` bigint(20) unsigned NOT NULL COMMENT '提问的参与者',
+    `day` date NOT NULL COMMENT '服务器时区的自然日',
+    `used` int NOT NULL DEFAULT 0 COMMENT '已预留的次数',
+    `created_at` timestamp NULL DEFAULT NULL,
+    `updated_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_ai_quota_usages_user_day` (`user_id`, `day`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='AI 助手每日配额表';
+
+-- 当天已经获得回答的提问计入配额
+INSERT INTO ai_quota_usages (user_id, day, used, created_at, updated_at)
+SELECT asked_by, CURDATE(), COUNT(*), NOW(), NOW()
+FROM messages
+WHERE type = 'ai_assistant' AND asked_by IS NOT NULL AND created_at >= CURDATE() AND deleted_at IS NULL
+GROUP BY asked_by;
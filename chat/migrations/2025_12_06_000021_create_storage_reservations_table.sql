@@ -0,0 +1,20 @@
+-- 存储配额预留表
+-- 上传前在锁定的配额行上检查用量并预留文件大小，文件消息创建后释放，并发上传不会一起超出配额
+
+CREATE TABLE IF NOT EXISTS `storage_quota_locks` (
+    `scope` enum('chat','organization') NOT NULL COMMENT '配额范围',
+    `scope_id` bigint(20) unsigned NOT NULL COMMENT '聊天室ID或组织ID',
+    `created_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`scope`, `scope_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='存储配额行锁表';
+
+CREATE TABLE IF NOT EXISTS `storage_reservations` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+    `chat_id` bigint(20) unsigned NOT NULL COMMENT '上传到的聊天室',
+    `size` bigint NOT NULL COMMENT '预留的字节数',
+    `expires_at` timestamp NULL DEFAULT NULL COMMENT '预留失效时间',
+    `created_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_storage_reservations_chat_id` (`chat_id`),
+    KEY `idx_storage_reservations_expires_at` (`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='存储配额预留表';